@@ -0,0 +1,57 @@
+package apitypes
+
+import "time"
+
+// Scopes limit what an API key can do. User sessions are not scoped.
+const (
+	ScopeVideo = "video"  // Video tokens, rooms and compositions (/api/video)
+	ScopeCalls = "calls"  // Scheduled calls (/api/calls)
+	ScopeUser  = "user"   // Call history (/api/user)
+	ScopeAdmin = "admin"  // Admin API, except API key management
+	ScopeActAs = "act_as" // Act on behalf of a user with the ActAsHeader
+)
+
+// ActAsHeader names the user an API key with ScopeActAs acts for
+const ActAsHeader = "X-Awwdio-Act-As"
+
+// APIKey is a service account credential. Only a hash of the secret is
+// kept; the key itself is shown once, when created or rotated.
+type APIKey struct {
+	ID         string    `json:"id"`
+	Name       string    `json:"name"`
+	Prefix     string    `json:"prefix"` // awk_<id>, the part of the key that identifies it
+	Hash       string    `json:"hash,omitempty"`
+	Scopes     []string  `json:"scopes"`
+	CreatedBy  string    `json:"created_by"`
+	CreatedAt  time.Time `json:"created_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	RotatedAt  time.Time `json:"rotated_at,omitzero"`
+	LastUsedAt time.Time `json:"last_used_at,omitzero"`
+	RevokedAt  time.Time `json:"revoked_at,omitzero"`
+}
+
+// Subject is the identity requests authenticated with the key have
+func (k APIKey) Subject() string {
+	return "service:" + k.ID
+}
+
+// Active reports whether the key is neither revoked nor expired
+func (k APIKey) Active(now time.Time) bool {
+	return k.RevokedAt.IsZero() && now.Before(k.ExpiresAt)
+}
+
+type CreateKeyRequest struct {
+	Name      string    `json:"name" validate:"required,max=100"`
+	Scopes    []string  `json:"scopes" validate:"required,min=1"`
+	ExpiresAt time.Time `json:"expires_at" validate:"required"` // RFC 3339, at most a year ahead
+}
+
+// KeyResponse is a key with its secret form, shown only once
+type KeyResponse struct {
+	APIKey
+	Key string `json:"key"`
+}
+
+type ListKeysResponse struct {
+	Keys []APIKey `json:"keys"`
+}
@@ -0,0 +1,45 @@
+// Package apitypes holds the request and response bodies of the awwdio HTTP
+// API. The server and the client package share them. It depends on the
+// standard library only, so clients do not pull in the server.
+package apitypes
+
+// Code identifies the kind of error. Clients should branch on codes, not
+// messages, which may change.
+type Code string
+
+// Codes
+const (
+	CodeBadRequest           Code = "bad_request"
+	CodeValidation           Code = "validation_failed"
+	CodeUnauthorized         Code = "unauthorized"
+	CodeInvalidToken         Code = "invalid_token" // Missing, malformed or expired JWT
+	CodeInvalidOTP           Code = "invalid_otp"
+	CodeForbidden            Code = "forbidden"
+	CodeCSRF                 Code = "csrf_failed"
+	CodeNotFound             Code = "not_found"
+	CodeMethodNotAllowed     Code = "method_not_allowed"
+	CodeConflict             Code = "conflict"
+	CodePayloadTooLarge      Code = "payload_too_large"
+	CodeUnsupportedMediaType Code = "unsupported_media_type"
+	CodeInternal             Code = "internal_error"
+	CodeUpstream             Code = "upstream_error" // Twilio or another service failed
+)
+
+// FieldError describes one invalid input field
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Error is an API error response. Status is the HTTP status.
+type Error struct {
+	Status    int          `json:"-"`
+	Code      Code         `json:"code"`
+	Message   string       `json:"error"`
+	RequestID string       `json:"request_id,omitempty"`
+	Fields    []FieldError `json:"fields,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return string(e.Code) + ": " + e.Message
+}
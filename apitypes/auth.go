@@ -0,0 +1,22 @@
+package apitypes
+
+type SendOTPRequest struct {
+	Channel string `json:"channel" validate:"required,oneof=email sms"`
+	To      string `json:"to" validate:"required,email_if=Channel:email,e164_if=Channel:sms"` // Email address or phone number
+}
+
+type SendOTPResponse struct {
+	Success bool `json:"success"`
+}
+
+type VerifyOTPRequest struct {
+	Channel string `json:"channel" validate:"required,oneof=email sms"`
+	To      string `json:"to" validate:"required,email_if=Channel:email,e164_if=Channel:sms"` // Email address or phone number
+	OTP     string `json:"otp" validate:"required,digits,min=4,max=10"`
+}
+
+type VerifyOTPResponse struct {
+	Success   bool   `json:"success"`
+	Token     string `json:"token,omitempty"`      // Bearer mode only
+	CSRFToken string `json:"csrf_token,omitempty"` // Cookie mode only
+}
@@ -0,0 +1,31 @@
+package apitypes
+
+import "time"
+
+type CreateCallRequest struct {
+	Title    string    `json:"title"`
+	Start    time.Time `json:"start"`    // RFC 3339
+	Duration int       `json:"duration"` // minutes
+	Invitees []string  `json:"invitees"` // Email addresses or phone numbers
+}
+
+type CallResponse struct {
+	ID        string            `json:"id"`
+	Title     string            `json:"title"`
+	Organizer string            `json:"organizer"`
+	Start     time.Time         `json:"start"`
+	End       time.Time         `json:"end"`
+	Duration  int               `json:"duration"`
+	Room      string            `json:"room"`
+	JoinURL   string            `json:"join_url"`
+	Invitees  []InviteeResponse `json:"invitees"`
+}
+
+type InviteeResponse struct {
+	Contact string `json:"contact"`
+	ICSURL  string `json:"ics_url,omitempty"`
+}
+
+type ListCallsResponse struct {
+	Calls []CallResponse `json:"calls"`
+}
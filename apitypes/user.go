@@ -0,0 +1,28 @@
+package apitypes
+
+import "time"
+
+type CallHistoryEntry struct {
+	RoomSid      string               `json:"room_sid"`
+	RoomName     string               `json:"room_name"`
+	Status       string               `json:"status"`
+	StartedAt    time.Time            `json:"started_at"`
+	EndedAt      time.Time            `json:"ended_at,omitzero"`
+	JoinedAt     time.Time            `json:"joined_at"`        // First time the user joined
+	LeftAt       time.Time            `json:"left_at,omitzero"` // Last time the user left
+	Duration     int                  `json:"duration"`         // Seconds the user was connected
+	Participants []string             `json:"participants"`     // Other identities in the room
+	Recordings   []CallRecordingEntry `json:"recordings"`
+}
+
+type CallRecordingEntry struct {
+	CompositionSid string `json:"composition_sid"`
+	Status         string `json:"status"`
+	Duration       int    `json:"duration,omitempty"`
+	MediaURL       string `json:"media_url,omitempty"` // Set once the composition is completed
+}
+
+type CallHistoryResponse struct {
+	Calls      []CallHistoryEntry `json:"calls"`
+	NextCursor string             `json:"next_cursor,omitempty"`
+}
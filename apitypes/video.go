@@ -0,0 +1,49 @@
+package apitypes
+
+import "time"
+
+type TokenRequest struct {
+	Room string `json:"room" validate:"required,roomname"`
+}
+
+type TokenResponse struct {
+	Token string `json:"token"`
+}
+
+type CreateRoomRequest struct {
+	Name string `json:"name" validate:"required,roomname"`
+}
+
+// Room is a room as Twilio's REST API describes it, with the fields
+// clients use. The server passes Twilio's response through as is.
+type Room struct {
+	Sid             string    `json:"sid"`
+	UniqueName      string    `json:"unique_name"`
+	Status          string    `json:"status"` // "in-progress", "completed" or "failed"
+	Type            string    `json:"type,omitempty"`
+	AudioOnly       bool      `json:"audio_only,omitempty"`
+	MaxParticipants int       `json:"max_participants,omitempty"`
+	MediaRegion     string    `json:"media_region,omitempty"`
+	Duration        int       `json:"duration,omitempty"` // seconds, once completed
+	DateCreated     time.Time `json:"date_created,omitzero"`
+	DateUpdated     time.Time `json:"date_updated,omitzero"`
+	EndTime         time.Time `json:"end_time,omitzero"`
+	URL             string    `json:"url,omitempty"`
+}
+
+type ListRoomsResponse struct {
+	Rooms []Room `json:"rooms"`
+}
+
+// Composition is a mixed audio file produced from a room's recordings
+type Composition struct {
+	Sid         string    `json:"sid"`
+	RoomSid     string    `json:"room_sid"`
+	RoomName    string    `json:"room_name"`
+	Status      string    `json:"status"` // "enqueued", "processing", "completed", "failed" or "deleted"
+	Format      string    `json:"format"`
+	Duration    int       `json:"duration,omitempty"` // seconds
+	Size        int64     `json:"size,omitempty"`     // bytes
+	CreatedAt   time.Time `json:"created_at"`
+	CompletedAt time.Time `json:"completed_at,omitzero"`
+}
@@ -0,0 +1,58 @@
+package apitypes
+
+import (
+	"encoding/json"
+	"slices"
+	"time"
+)
+
+// Subscription is an endpoint that receives events
+type Subscription struct {
+	ID        string    `json:"id"`
+	URL       string    `json:"url"`
+	Secret    string    `json:"secret"`
+	Events    []string  `json:"events"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Wants reports whether the subscription receives events of type eventType
+func (s Subscription) Wants(eventType string) bool {
+	return slices.Contains(s.Events, "*") || slices.Contains(s.Events, eventType)
+}
+
+type CreateSubscriptionRequest struct {
+	URL    string   `json:"url"`
+	Secret string   `json:"secret,omitempty"` // Generated when empty
+	Events []string `json:"events"`           // Event types, or "*" for all
+}
+
+type ListSubscriptionsResponse struct {
+	Subscriptions []Subscription `json:"subscriptions"`
+}
+
+// Delivery statuses
+const (
+	StatusPending   = "pending"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+)
+
+// Delivery is one event sent to one subscription, with its outcome
+type Delivery struct {
+	ID             string          `json:"id"`
+	SubscriptionID string          `json:"subscription_id"`
+	EventID        string          `json:"event_id"`
+	EventType      string          `json:"event_type"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	ResponseCode   int             `json:"response_code,omitempty"`
+	LastError      string          `json:"last_error,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	NextAttemptAt  time.Time       `json:"next_attempt_at,omitzero"`
+	DeliveredAt    time.Time       `json:"delivered_at,omitzero"`
+}
+
+type ListDeliveriesResponse struct {
+	Deliveries []Delivery `json:"deliveries"`
+}
@@ -113,7 +113,7 @@ dev() {
     echo "Frontend: Run 'cd web && npm run dev' in another terminal for hot reload"
     echo ""
 
-    go run main.go
+    go run .
 }
 
 # Function to show help
@@ -0,0 +1,290 @@
+// Package client is a Go client for the Awwdio API.
+//
+//	c := client.New("https://awwdio.example.com", client.WithToken(jwt))
+//	room, err := c.CreateRoom(ctx, client.CreateRoomRequest{Name: "standup"})
+//
+// Requests and responses are the types the server uses. Idempotent
+// requests are retried on network errors and 429, 502, 503 and 504
+// responses. Tokens from a TokenSource are refreshed before they expire,
+// and once more when the server rejects one.
+//
+// Service accounts authenticate with API keys instead. A key with the
+// act_as scope can act for a user:
+//
+//	svc := client.New(url, client.WithToken(apiKey))
+//	token, err := svc.As("guest@example.com").VideoToken(ctx, req)
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kaustavdm/awwdio/apitypes"
+)
+
+const (
+	defaultTimeout = 30 * time.Second
+	defaultRetries = 3
+	// retryDelay is the base of the exponential backoff between retries
+	retryDelay    = 250 * time.Millisecond
+	maxRetryDelay = 10 * time.Second
+
+	actAsHeader = apitypes.ActAsHeader
+)
+
+// Client calls the API of one Awwdio server. It is safe for concurrent use.
+type Client struct {
+	baseURL string
+	http    *http.Client
+	retries int
+	actAs   string // User the requests are made for, if any
+	creds   *credentials
+}
+
+// credentials are the token state, shared by a client and the clients As
+// returns
+type credentials struct {
+	source TokenSource
+
+	mu     sync.Mutex
+	token  string
+	expiry time.Time // Zero if unknown
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithHTTPClient sets the HTTP client requests are sent with
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) { c.http = hc }
+}
+
+// WithRetries sets how many times idempotent requests are retried, 3 by
+// default. 0 disables retries.
+func WithRetries(n int) Option {
+	return func(c *Client) { c.retries = n }
+}
+
+// WithToken authenticates requests with a fixed token: a session JWT or
+// an API key
+func WithToken(token string) Option {
+	return func(c *Client) { c.creds.token, c.creds.expiry = token, tokenExpiry(token) }
+}
+
+// WithTokenSource authenticates requests with tokens from src, fetching a
+// new one shortly before the current one expires
+func WithTokenSource(src TokenSource) Option {
+	return func(c *Client) { c.creds.source = src }
+}
+
+// New returns a client for the server at baseURL, such as
+// https://awwdio.example.com
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		http:    &http.Client{Timeout: defaultTimeout},
+		retries: defaultRetries,
+		creds:   &credentials{},
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// As returns a client that makes its requests on behalf of subject, the
+// email address or phone number of a user. It needs an API key with the
+// act_as scope, and shares the token of c.
+func (c *Client) As(subject string) *Client {
+	as := *c
+	as.actAs = subject
+	return &as
+}
+
+// call describes an API request
+type call struct {
+	method string
+	path   string // Below /api
+	query  url.Values
+	body   any // JSON encoded unless nil
+
+	public     bool // Sent without a token
+	noRedirect bool // Return redirects instead of following them
+}
+
+// do sends c and decodes the JSON response into out, unless out is nil.
+// Error responses are returned as *Error.
+func (c *Client) do(ctx context.Context, cl call, out any) error {
+	resp, err := c.send(ctx, cl)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if out == nil {
+		io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("client: decoding %s %s response: %w", cl.method, cl.path, err)
+	}
+	return nil
+}
+
+// send sends cl, retrying idempotent requests, and returns a response with
+// a status below 400
+func (c *Client) send(ctx context.Context, cl call) (*http.Response, error) {
+	var body []byte
+	if cl.body != nil {
+		var err error
+		if body, err = json.Marshal(cl.body); err != nil {
+			return nil, fmt.Errorf("client: encoding %s %s request: %w", cl.method, cl.path, err)
+		}
+	}
+
+	u := c.baseURL + "/api" + cl.path
+	if len(cl.query) > 0 {
+		u += "?" + cl.query.Encode()
+	}
+
+	hc := c.http
+	if cl.noRedirect {
+		noRedirect := *c.http
+		noRedirect.CheckRedirect = func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		}
+		hc = &noRedirect
+	}
+
+	refreshed := false
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, cl.method, u, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Accept", "application/json")
+		if body != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		token := ""
+		if !cl.public {
+			if token, err = c.creds.authToken(ctx); err != nil {
+				return nil, err
+			}
+			if token != "" {
+				req.Header.Set("Authorization", "Bearer "+token)
+			}
+			if c.actAs != "" {
+				req.Header.Set(actAsHeader, c.actAs)
+			}
+		}
+
+		retry := attempt < c.retries && idempotent(cl.method)
+		resp, err := hc.Do(req)
+		if err != nil {
+			if retry && ctx.Err() == nil {
+				if err := wait(ctx, backoff(attempt)); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			return nil, err
+		}
+		if resp.StatusCode < 400 {
+			return resp, nil
+		}
+
+		apiErr := readError(resp)
+		switch {
+		case apiErr.Code == CodeInvalidToken && !cl.public && c.creds.source != nil && !refreshed:
+			// Expired or revoked early: get a new token and try once more
+			c.creds.invalidate(token)
+			refreshed = true
+			attempt--
+			continue
+		case retry && retryable(resp.StatusCode):
+			delay := backoff(attempt)
+			if after := retryAfter(resp); after > 0 {
+				delay = min(after, maxRetryDelay)
+			}
+			if err := wait(ctx, delay); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		return nil, apiErr
+	}
+}
+
+// readError reads an error response
+func readError(resp *http.Response) *Error {
+	defer resp.Body.Close()
+	e := &Error{}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err := json.Unmarshal(data, e); err != nil || e.Code == "" {
+		e = &Error{Message: http.StatusText(resp.StatusCode)}
+	}
+	e.Status = resp.StatusCode
+	return e
+}
+
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+func retryable(status int) bool {
+	switch status {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// backoff returns the delay before retry attempt+1: exponential, with full
+// jitter so that clients failing together do not retry together
+func backoff(attempt int) time.Duration {
+	d := min(retryDelay<<attempt, maxRetryDelay)
+	return rand.N(d) + 1
+}
+
+// retryAfter returns the delay a Retry-After header in seconds asks for
+func retryAfter(resp *http.Response) time.Duration {
+	secs, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	if err != nil || secs < 0 {
+		return 0
+	}
+	return time.Duration(secs) * time.Second
+}
+
+// wait sleeps for d, or until ctx is done
+func wait(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// IsCode reports whether err is an API error with the given code
+func IsCode(err error, code Code) bool {
+	var e *Error
+	return errors.As(err, &e) && e.Code == code
+}
@@ -0,0 +1,95 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kaustavdm/awwdio/internal/api/apierror"
+	"github.com/kaustavdm/awwdio/internal/api/auth"
+)
+
+const testSecret = "0123456789abcdef0123456789abcdef"
+
+func TestRetriesIdempotentRequests(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) < 3 {
+			apierror.Write(w, r, apierror.Upstream("Twilio is down"))
+			return
+		}
+		w.Write([]byte(`{"calls": [{"id": "abc"}]}`))
+	}))
+	defer srv.Close()
+
+	calls, err := New(srv.URL, WithToken("t")).ListCalls(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(calls) != 1 || calls[0].ID != "abc" || requests.Load() != 3 {
+		t.Errorf("got %v after %d requests, want call abc after 3", calls, requests.Load())
+	}
+}
+
+func TestDoesNotRetryPost(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		apierror.Write(w, r, apierror.Upstream("Twilio is down"))
+	}))
+	defer srv.Close()
+
+	_, err := New(srv.URL, WithToken("t")).CreateRoom(context.Background(), CreateRoomRequest{Name: "standup"})
+	if !IsCode(err, CodeUpstream) {
+		t.Errorf("err = %v, want %s", err, CodeUpstream)
+	}
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.Status != http.StatusBadGateway {
+		t.Errorf("err = %#v, want status 502", err)
+	}
+	if requests.Load() != 1 {
+		t.Errorf("%d requests, want 1", requests.Load())
+	}
+}
+
+func TestRefreshesTokens(t *testing.T) {
+	expiring, err := auth.GenerateJWT("svc@example.com", testSecret, 30*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The server rejects the first token it sees, as if it were revoked
+	var seen []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = append(seen, r.Header.Get("Authorization"))
+		if len(seen) == 1 {
+			apierror.Write(w, r, apierror.New(http.StatusUnauthorized, apierror.CodeInvalidToken, "Invalid token"))
+			return
+		}
+		w.Write([]byte(`{"token": "video"}`))
+	}))
+	defer srv.Close()
+
+	var issued atomic.Int32
+	src := TokenFunc(func(ctx context.Context) (string, error) {
+		return auth.GenerateJWT("svc@example.com"+strconv.Itoa(int(issued.Add(1))), testSecret, time.Hour)
+	})
+	c := New(srv.URL, WithToken(expiring), WithTokenSource(src))
+
+	resp, err := c.VideoToken(context.Background(), TokenRequest{Room: "standup"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Token != "video" {
+		t.Errorf("token = %q, want %q", resp.Token, "video")
+	}
+	// The expiring token is replaced before use, and the rejected one after
+	if issued.Load() != 2 || len(seen) != 2 || seen[0] == "Bearer "+expiring || seen[0] == seen[1] {
+		t.Errorf("%d tokens issued, %d requests; want 2 new tokens, each used once", issued.Load(), len(seen))
+	}
+}
@@ -0,0 +1,308 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/kaustavdm/awwdio/apitypes"
+)
+
+// SendOTP sends a one-time password to an email address or phone number
+func (c *Client) SendOTP(ctx context.Context, req SendOTPRequest) (*SendOTPResponse, error) {
+	var resp SendOTPResponse
+	err := c.do(ctx, call{method: http.MethodPost, path: "/auth/send-otp", body: req, public: true}, &resp)
+	if err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// VerifyOTP checks a one-time password. The session token it returns is
+// used for the client's later requests.
+func (c *Client) VerifyOTP(ctx context.Context, req VerifyOTPRequest) (*VerifyOTPResponse, error) {
+	var resp VerifyOTPResponse
+	err := c.do(ctx, call{method: http.MethodPost, path: "/auth/verify-otp", body: req, public: true}, &resp)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Token != "" {
+		c.SetToken(resp.Token)
+	}
+	return &resp, nil
+}
+
+// Logout ends the session and forgets the client's token
+func (c *Client) Logout(ctx context.Context) error {
+	err := c.do(ctx, call{method: http.MethodPost, path: "/auth/logout"}, nil)
+	c.SetToken("")
+	return err
+}
+
+// VideoToken issues a Twilio Video access token for a room
+func (c *Client) VideoToken(ctx context.Context, req TokenRequest) (*TokenResponse, error) {
+	var resp TokenResponse
+	if err := c.do(ctx, call{method: http.MethodPost, path: "/video/token", body: req}, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// GetRoom fetches a room by unique name or SID
+func (c *Client) GetRoom(ctx context.Context, name string) (*Room, error) {
+	var room Room
+	query := url.Values{"roomName": {name}}
+	if err := c.do(ctx, call{method: http.MethodGet, path: "/video/room", query: query}, &room); err != nil {
+		return nil, err
+	}
+	return &room, nil
+}
+
+// CreateRoom creates a room, or returns it if it is already in progress
+func (c *Client) CreateRoom(ctx context.Context, req CreateRoomRequest) (*Room, error) {
+	var room Room
+	if err := c.do(ctx, call{method: http.MethodPost, path: "/video/rooms", body: req}, &room); err != nil {
+		return nil, err
+	}
+	return &room, nil
+}
+
+// ListRooms returns the rooms in progress. Admin only.
+func (c *Client) ListRooms(ctx context.Context) ([]Room, error) {
+	var resp apitypes.ListRoomsResponse
+	if err := c.do(ctx, call{method: http.MethodGet, path: "/admin/rooms"}, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Rooms, nil
+}
+
+// EndRoom ends a room in progress, disconnecting its participants. Admin only.
+func (c *Client) EndRoom(ctx context.Context, name string) (*Room, error) {
+	var room Room
+	path := "/admin/rooms/" + url.PathEscape(name) + "/end"
+	if err := c.do(ctx, call{method: http.MethodPost, path: path}, &room); err != nil {
+		return nil, err
+	}
+	return &room, nil
+}
+
+// GetComposition returns a recording composition
+func (c *Client) GetComposition(ctx context.Context, sid string) (*Composition, error) {
+	var comp Composition
+	if err := c.do(ctx, call{method: http.MethodGet, path: "/video/compositions/" + url.PathEscape(sid)}, &comp); err != nil {
+		return nil, err
+	}
+	return &comp, nil
+}
+
+// CompositionMediaURL returns a short-lived download link for a completed
+// composition
+func (c *Client) CompositionMediaURL(ctx context.Context, sid string) (string, error) {
+	path := "/video/compositions/" + url.PathEscape(sid) + "/media"
+	resp, err := c.send(ctx, call{method: http.MethodGet, path: path, noRedirect: true})
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	location := resp.Header.Get("Location")
+	if location == "" {
+		return "", errors.New("client: media response has no Location")
+	}
+	return location, nil
+}
+
+// CallHistoryQuery selects a page of call history. Zero fields are not
+// sent.
+type CallHistoryQuery struct {
+	From   time.Time
+	To     time.Time // Exclusive
+	Limit  int
+	Cursor string // NextCursor of the previous page
+}
+
+// MyCalls returns a page of the calls the user took part in, newest first
+func (c *Client) MyCalls(ctx context.Context, q CallHistoryQuery) (*CallHistory, error) {
+	query := url.Values{}
+	setTime(query, "from", q.From)
+	setTime(query, "to", q.To)
+	setInt(query, "limit", q.Limit)
+	set(query, "cursor", q.Cursor)
+
+	var resp CallHistory
+	if err := c.do(ctx, call{method: http.MethodGet, path: "/user/me/calls", query: query}, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// CreateCall schedules a call and invites its participants
+func (c *Client) CreateCall(ctx context.Context, req CreateCallRequest) (*Call, error) {
+	var resp Call
+	if err := c.do(ctx, call{method: http.MethodPost, path: "/calls", body: req}, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// ListCalls returns the user's upcoming and in-progress calls
+func (c *Client) ListCalls(ctx context.Context) ([]Call, error) {
+	var resp apitypes.ListCallsResponse
+	if err := c.do(ctx, call{method: http.MethodGet, path: "/calls"}, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Calls, nil
+}
+
+// GetCall returns a call the user organized or was invited to
+func (c *Client) GetCall(ctx context.Context, id string) (*Call, error) {
+	var resp Call
+	if err := c.do(ctx, call{method: http.MethodGet, path: "/calls/" + url.PathEscape(id)}, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Invitation downloads the iCalendar invitation with the given invitee
+// token. It needs no session.
+func (c *Client) Invitation(ctx context.Context, token string) ([]byte, error) {
+	resp, err := c.send(ctx, call{method: http.MethodGet, path: "/calls/invites/" + url.PathEscape(token) + ".ics", public: true})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
+}
+
+// CreateWebhook adds a webhook endpoint. The returned secret is not shown
+// again. Admin only.
+func (c *Client) CreateWebhook(ctx context.Context, req CreateWebhookRequest) (*Webhook, error) {
+	var resp Webhook
+	if err := c.do(ctx, call{method: http.MethodPost, path: "/admin/webhooks", body: req}, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// ListWebhooks returns the webhook endpoints, without their secrets. Admin only.
+func (c *Client) ListWebhooks(ctx context.Context) ([]Webhook, error) {
+	var resp apitypes.ListSubscriptionsResponse
+	if err := c.do(ctx, call{method: http.MethodGet, path: "/admin/webhooks"}, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Subscriptions, nil
+}
+
+// DeleteWebhook removes a webhook endpoint. Admin only.
+func (c *Client) DeleteWebhook(ctx context.Context, id string) error {
+	return c.do(ctx, call{method: http.MethodDelete, path: "/admin/webhooks/" + url.PathEscape(id)}, nil)
+}
+
+// WebhookDeliveries returns the deliveries to a webhook endpoint, newest
+// first. Admin only.
+func (c *Client) WebhookDeliveries(ctx context.Context, id string) ([]WebhookDelivery, error) {
+	var resp apitypes.ListDeliveriesResponse
+	path := "/admin/webhooks/" + url.PathEscape(id) + "/deliveries"
+	if err := c.do(ctx, call{method: http.MethodGet, path: path}, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Deliveries, nil
+}
+
+// RedeliverWebhook sends a delivery again right away. Admin only.
+func (c *Client) RedeliverWebhook(ctx context.Context, deliveryID string) (*WebhookDelivery, error) {
+	var resp WebhookDelivery
+	path := "/admin/webhooks/deliveries/" + url.PathEscape(deliveryID) + "/redeliver"
+	if err := c.do(ctx, call{method: http.MethodPost, path: path}, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// AuditQuery selects a page of audit entries. Zero fields are not sent.
+type AuditQuery struct {
+	Actor   string
+	Action  string // Exact, or a prefix ending in "." such as "auth."
+	Target  string
+	Outcome string
+	From    time.Time
+	To      time.Time // Exclusive
+	Limit   int
+	Cursor  string // NextCursor of the previous page
+}
+
+// AuditEntries returns a page of audit entries, newest first. Admin only.
+func (c *Client) AuditEntries(ctx context.Context, q AuditQuery) (*AuditPage, error) {
+	query := url.Values{}
+	set(query, "actor", q.Actor)
+	set(query, "action", q.Action)
+	set(query, "target", q.Target)
+	set(query, "outcome", q.Outcome)
+	setTime(query, "from", q.From)
+	setTime(query, "to", q.To)
+	setInt(query, "limit", q.Limit)
+	set(query, "cursor", q.Cursor)
+
+	var resp AuditPage
+	if err := c.do(ctx, call{method: http.MethodGet, path: "/admin/audit", query: query}, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// CreateAPIKey adds an API key. The returned key is not shown again.
+// Admin sessions only.
+func (c *Client) CreateAPIKey(ctx context.Context, req CreateAPIKeyRequest) (*APIKeyWithSecret, error) {
+	var resp APIKeyWithSecret
+	if err := c.do(ctx, call{method: http.MethodPost, path: "/admin/keys", body: req}, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// ListAPIKeys returns the API keys, without their secrets. Admin only.
+func (c *Client) ListAPIKeys(ctx context.Context) ([]APIKey, error) {
+	var resp apitypes.ListKeysResponse
+	if err := c.do(ctx, call{method: http.MethodGet, path: "/admin/keys"}, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Keys, nil
+}
+
+// RotateAPIKey replaces the secret of an API key. Admin sessions only.
+func (c *Client) RotateAPIKey(ctx context.Context, id string) (*APIKeyWithSecret, error) {
+	var resp APIKeyWithSecret
+	path := "/admin/keys/" + url.PathEscape(id) + "/rotate"
+	if err := c.do(ctx, call{method: http.MethodPost, path: path}, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// RevokeAPIKey disables an API key for good. Admin sessions only.
+func (c *Client) RevokeAPIKey(ctx context.Context, id string) error {
+	return c.do(ctx, call{method: http.MethodDelete, path: "/admin/keys/" + url.PathEscape(id)}, nil)
+}
+
+func set(q url.Values, key, v string) {
+	if v != "" {
+		q.Set(key, v)
+	}
+}
+
+func setInt(q url.Values, key string, v int) {
+	if v != 0 {
+		q.Set(key, strconv.Itoa(v))
+	}
+}
+
+func setTime(q url.Values, key string, t time.Time) {
+	if !t.IsZero() {
+		q.Set(key, t.Format(time.RFC3339))
+	}
+}
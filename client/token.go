@@ -0,0 +1,82 @@
+package client
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// refreshMargin is how long before expiry a token is replaced, so that it
+// does not expire in flight
+const refreshMargin = time.Minute
+
+// TokenSource supplies the bearer tokens requests are authenticated with
+type TokenSource interface {
+	Token(ctx context.Context) (string, error)
+}
+
+// TokenFunc adapts a function to a TokenSource
+type TokenFunc func(ctx context.Context) (string, error)
+
+func (f TokenFunc) Token(ctx context.Context) (string, error) {
+	return f(ctx)
+}
+
+// SetToken replaces the token requests are authenticated with. VerifyOTP
+// calls it with the session token it gets.
+func (c *Client) SetToken(token string) {
+	c.creds.mu.Lock()
+	defer c.creds.mu.Unlock()
+	c.creds.token, c.creds.expiry = token, tokenExpiry(token)
+}
+
+// authToken returns the token to send, fetching a new one from the token
+// source if there is none or it is about to expire
+func (c *credentials) authToken(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	fresh := c.token != "" && (c.expiry.IsZero() || time.Until(c.expiry) > refreshMargin)
+	if fresh || c.source == nil {
+		return c.token, nil
+	}
+
+	token, err := c.source.Token(ctx)
+	if err != nil {
+		return "", err
+	}
+	c.token, c.expiry = token, tokenExpiry(token)
+	return token, nil
+}
+
+// invalidate drops token, if still current, so the next request fetches a
+// new one
+func (c *credentials) invalidate(token string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.token == token {
+		c.token, c.expiry = "", time.Time{}
+	}
+}
+
+// tokenExpiry reads the exp claim of a JWT, without verifying it. It
+// returns the zero time for tokens that are not JWTs or do not expire.
+func tokenExpiry(token string) time.Time {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return time.Time{}
+	}
+	var claims struct {
+		Exp int64 `json:"exp"`
+	}
+	if json.Unmarshal(payload, &claims) != nil || claims.Exp == 0 {
+		return time.Time{}
+	}
+	return time.Unix(claims.Exp, 0)
+}
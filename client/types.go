@@ -0,0 +1,72 @@
+package client
+
+import "github.com/kaustavdm/awwdio/apitypes"
+
+// Requests and responses, shared with the server
+type (
+	SendOTPRequest    = apitypes.SendOTPRequest
+	SendOTPResponse   = apitypes.SendOTPResponse
+	VerifyOTPRequest  = apitypes.VerifyOTPRequest
+	VerifyOTPResponse = apitypes.VerifyOTPResponse
+
+	TokenRequest      = apitypes.TokenRequest
+	TokenResponse     = apitypes.TokenResponse
+	CreateRoomRequest = apitypes.CreateRoomRequest
+	Room              = apitypes.Room
+	Composition       = apitypes.Composition
+
+	CallHistory        = apitypes.CallHistoryResponse
+	CallHistoryEntry   = apitypes.CallHistoryEntry
+	CallRecordingEntry = apitypes.CallRecordingEntry
+
+	CreateCallRequest = apitypes.CreateCallRequest
+	Call              = apitypes.CallResponse
+	Invitee           = apitypes.InviteeResponse
+
+	CreateWebhookRequest = apitypes.CreateSubscriptionRequest
+	Webhook              = apitypes.Subscription
+	WebhookDelivery      = apitypes.Delivery
+
+	AuditPage  = apitypes.ListAuditEntriesResponse
+	AuditEntry = apitypes.AuditEntry
+
+	CreateAPIKeyRequest = apitypes.CreateKeyRequest
+	APIKey              = apitypes.APIKey
+	APIKeyWithSecret    = apitypes.KeyResponse
+)
+
+// API key scopes
+const (
+	ScopeVideo = apitypes.ScopeVideo
+	ScopeCalls = apitypes.ScopeCalls
+	ScopeUser  = apitypes.ScopeUser
+	ScopeAdmin = apitypes.ScopeAdmin
+	ScopeActAs = apitypes.ScopeActAs
+)
+
+// Error is an error response from the API. Its Status is the HTTP status.
+type Error = apitypes.Error
+
+// FieldError describes one invalid input field of a request
+type FieldError = apitypes.FieldError
+
+// Code identifies the kind of an Error
+type Code = apitypes.Code
+
+// Codes
+const (
+	CodeBadRequest           = apitypes.CodeBadRequest
+	CodeValidation           = apitypes.CodeValidation
+	CodeUnauthorized         = apitypes.CodeUnauthorized
+	CodeInvalidToken         = apitypes.CodeInvalidToken
+	CodeInvalidOTP           = apitypes.CodeInvalidOTP
+	CodeForbidden            = apitypes.CodeForbidden
+	CodeCSRF                 = apitypes.CodeCSRF
+	CodeNotFound             = apitypes.CodeNotFound
+	CodeMethodNotAllowed     = apitypes.CodeMethodNotAllowed
+	CodeConflict             = apitypes.CodeConflict
+	CodePayloadTooLarge      = apitypes.CodePayloadTooLarge
+	CodeUnsupportedMediaType = apitypes.CodeUnsupportedMediaType
+	CodeInternal             = apitypes.CodeInternal
+	CodeUpstream             = apitypes.CodeUpstream
+)
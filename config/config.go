@@ -1,9 +1,17 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"io"
+	"net/url"
 	"os"
+	"path/filepath"
+	"slices"
 	"strconv"
+	"strings"
+
+	"github.com/kaustavdm/awwdio/internal/normalize"
 )
 
 type Config struct {
@@ -19,57 +27,554 @@ type Config struct {
 	TwilioVerifyServiceSID string
 	// JWT Secret for signing authentication tokens
 	JWTSecret string
+	// Twilio Auth Token, used to validate signatures on Twilio callbacks (optional)
+	TwilioAuthToken string
+	// Public base URL of this server, used to build Twilio callback URLs (optional)
+	PublicURL string
+	// Record calls and produce a mixed audio composition when each room ends
+	RecordCalls bool
+	// Directory for persisted data. Empty keeps data in memory only.
+	DataDir string
+	// Append-only audit log file. Defaults to audit.jsonl in DataDir.
+	AuditLogFile string
+	// Key of the HMAC chaining audit entries. Required with AuditLogFile.
+	AuditHMACKey string
+	// SMTP server for email notifications (optional)
+	SMTPHost     string
+	SMTPPort     string
+	SMTPUsername string
+	SMTPPassword string
+	// Sender address for email notifications
+	SMTPFrom string
+	// Twilio phone number or Messaging Service SID for SMS notifications (optional)
+	TwilioMessagingFrom string
+	// Users (email or phone) allowed to use the admin API
+	AdminUsers []string
+	// Calling code assumed for phone numbers entered without one, e.g. "1"
+	DefaultCountryCode string
+	// TLS certificate and key files. Both are reloaded when they change.
+	TLSCertFile string
+	TLSKeyFile  string
+	// Domains to obtain certificates for with ACME (Let's Encrypt)
+	ACMEDomains []string
+	// Contact email for the ACME account (optional)
+	ACMEEmail string
+	// Directory where ACME certificates are cached
+	ACMECacheDir string
+	// Port of a plain HTTP listener that redirects to HTTPS (optional)
+	HTTPRedirectPort string
+	// Port of a separate listener for /metrics. When empty, metrics are
+	// not served.
+	MetricsPort string
+	// OTLP/HTTP endpoint to export traces to. Tracing is off when empty.
+	OTLPEndpoint string
+	// Headers sent with trace exports, e.g. collector credentials
+	OTLPHeaders map[string]string
+	// Service name reported with traces
+	ServiceName string
+	// Origins allowed to call the API from a browser. "*" allows any.
+	CORSAllowedOrigins []string
+	// Let allowed origins send cookies and read responses to credentialed requests
+	CORSAllowCredentials bool
+	// How long browsers may cache preflight results, in seconds
+	CORSMaxAge int
+	// How browsers hold the session: "bearer" returns the JWT for the
+	// frontend to send in the Authorization header, "cookie" keeps it in an
+	// HttpOnly cookie instead. Bearer tokens are accepted in both modes.
+	AuthMode string
+	// Attributes of the session and CSRF cookies
+	SessionCookieSecure   bool
+	SessionCookieSameSite string // "lax", "strict" or "none"
+	// Extend HSTS to every subdomain. Off by default: it also forces HTTPS
+	// on sibling sites of the domain.
+	HSTSIncludeSubdomains bool
 }
 
-// LoadConfig loads the configuration from environment variables
-func LoadConfig() (*Config, error) {
-	cfg := &Config{
-		Port: "8080", // Default port
+// Authentication modes
+const (
+	AuthModeBearer = "bearer"
+	AuthModeCookie = "cookie"
+)
+
+// minJWTSecretLength is the shortest accepted JWT_SECRET, in bytes.
+// HS256 keys should be at least as long as the hash output.
+const minJWTSecretLength = 32
+
+// minAuditKeyLength is the shortest accepted AUDIT_HMAC_KEY
+const minAuditKeyLength = 32
+
+// setting describes one configuration key. The key is the environment
+// variable name; config files use the same names, in any case.
+type setting struct {
+	key      string
+	required bool
+	secret   bool
+	get      func(c *Config) string
+	set      func(c *Config, v string) error
+}
+
+// portSetting is a TCP port setting; field returns where it is stored
+func portSetting(key string, field func(c *Config) *string) setting {
+	return setting{
+		key: key,
+		get: func(c *Config) string { return *field(c) },
+		set: func(c *Config, v string) error {
+			if port, err := strconv.Atoi(v); err != nil || port <= 0 || port > 65535 {
+				return fmt.Errorf("invalid port %q", v)
+			}
+			*field(c) = v
+			return nil
+		},
+	}
+}
+
+// boolSetting is a boolean setting; field returns where it is stored
+func boolSetting(key string, field func(c *Config) *bool) setting {
+	return setting{
+		key: key,
+		get: func(c *Config) string { return strconv.FormatBool(*field(c)) },
+		set: func(c *Config, v string) error {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("invalid boolean %q", v)
+			}
+			*field(c) = b
+			return nil
+		},
 	}
+}
+
+// settings lists every configuration key, in the order they are printed
+var settings = []setting{
+	portSetting("PORT", func(c *Config) *string { return &c.Port }),
+	{
+		key:      "TWILIO_ACCOUNT_SID",
+		required: true,
+		get:      func(c *Config) string { return c.TwilioAccountSID },
+		set:      func(c *Config, v string) error { c.TwilioAccountSID = v; return checkSID(v, "AC") },
+	},
+	{
+		key:      "TWILIO_API_KEY",
+		required: true,
+		get:      func(c *Config) string { return c.TwilioApiKey },
+		set:      func(c *Config, v string) error { c.TwilioApiKey = v; return checkSID(v, "SK") },
+	},
+	{
+		key:      "TWILIO_API_SECRET",
+		required: true,
+		secret:   true,
+		get:      func(c *Config) string { return c.TwilioApiSecret },
+		set:      func(c *Config, v string) error { c.TwilioApiSecret = v; return nil },
+	},
+	{
+		key:      "TWILIO_VERIFY_SERVICE_SID",
+		required: true,
+		get:      func(c *Config) string { return c.TwilioVerifyServiceSID },
+		set:      func(c *Config, v string) error { c.TwilioVerifyServiceSID = v; return checkSID(v, "VA") },
+	},
+	{
+		key:      "JWT_SECRET",
+		required: true,
+		secret:   true,
+		get:      func(c *Config) string { return c.JWTSecret },
+		set: func(c *Config, v string) error {
+			c.JWTSecret = v
+			if len(v) < minJWTSecretLength {
+				return fmt.Errorf("must be at least %d bytes, got %d", minJWTSecretLength, len(v))
+			}
+			return nil
+		},
+	},
+	{
+		key:    "TWILIO_AUTH_TOKEN",
+		secret: true,
+		get:    func(c *Config) string { return c.TwilioAuthToken },
+		set:    func(c *Config, v string) error { c.TwilioAuthToken = v; return nil },
+	},
+	{
+		key: "PUBLIC_URL",
+		get: func(c *Config) string { return c.PublicURL },
+		set: func(c *Config, v string) error {
+			u, err := url.Parse(v)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return fmt.Errorf("must be an absolute http or https URL")
+			}
+			c.PublicURL = strings.TrimRight(v, "/")
+			return nil
+		},
+	},
+	boolSetting("RECORD_CALLS", func(c *Config) *bool { return &c.RecordCalls }),
+	{
+		key: "DATA_DIR",
+		get: func(c *Config) string { return c.DataDir },
+		set: func(c *Config, v string) error { c.DataDir = v; return nil },
+	},
+	{
+		key: "AUDIT_LOG_FILE",
+		get: func(c *Config) string { return c.AuditLogFile },
+		set: func(c *Config, v string) error { c.AuditLogFile = v; return nil },
+	},
+	{
+		key:    "AUDIT_HMAC_KEY",
+		secret: true,
+		get:    func(c *Config) string { return c.AuditHMACKey },
+		set: func(c *Config, v string) error {
+			c.AuditHMACKey = v
+			if len(v) < minAuditKeyLength {
+				return fmt.Errorf("must be at least %d bytes, got %d", minAuditKeyLength, len(v))
+			}
+			return nil
+		},
+	},
+	{
+		key: "SMTP_HOST",
+		get: func(c *Config) string { return c.SMTPHost },
+		set: func(c *Config, v string) error { c.SMTPHost = v; return nil },
+	},
+	portSetting("SMTP_PORT", func(c *Config) *string { return &c.SMTPPort }),
+	{
+		key: "SMTP_USERNAME",
+		get: func(c *Config) string { return c.SMTPUsername },
+		set: func(c *Config, v string) error { c.SMTPUsername = v; return nil },
+	},
+	{
+		key:    "SMTP_PASSWORD",
+		secret: true,
+		get:    func(c *Config) string { return c.SMTPPassword },
+		set:    func(c *Config, v string) error { c.SMTPPassword = v; return nil },
+	},
+	{
+		key: "SMTP_FROM",
+		get: func(c *Config) string { return c.SMTPFrom },
+		set: func(c *Config, v string) error { c.SMTPFrom = v; return nil },
+	},
+	{
+		key: "TWILIO_MESSAGING_FROM",
+		get: func(c *Config) string { return c.TwilioMessagingFrom },
+		set: func(c *Config, v string) error { c.TwilioMessagingFrom = v; return nil },
+	},
+	{
+		key: "ADMIN_USERS",
+		get: func(c *Config) string { return strings.Join(c.AdminUsers, ",") },
+		set: func(c *Config, v string) error { c.AdminUsers = splitList(v); return nil },
+	},
+	{
+		key: "DEFAULT_COUNTRY_CODE",
+		get: func(c *Config) string { return c.DefaultCountryCode },
+		set: func(c *Config, v string) error {
+			v = strings.TrimPrefix(v, "+")
+			if len(v) < 1 || len(v) > 3 || strings.Trim(v, "0123456789") != "" || v[0] == '0' {
+				return fmt.Errorf("must be a calling code such as 1 or 44")
+			}
+			c.DefaultCountryCode = v
+			return nil
+		},
+	},
+	{
+		key: "TLS_CERT_FILE",
+		get: func(c *Config) string { return c.TLSCertFile },
+		set: func(c *Config, v string) error { c.TLSCertFile = v; return nil },
+	},
+	{
+		key: "TLS_KEY_FILE",
+		get: func(c *Config) string { return c.TLSKeyFile },
+		set: func(c *Config, v string) error { c.TLSKeyFile = v; return nil },
+	},
+	{
+		key: "ACME_DOMAINS",
+		get: func(c *Config) string { return strings.Join(c.ACMEDomains, ",") },
+		set: func(c *Config, v string) error { c.ACMEDomains = splitList(v); return nil },
+	},
+	{
+		key: "ACME_EMAIL",
+		get: func(c *Config) string { return c.ACMEEmail },
+		set: func(c *Config, v string) error { c.ACMEEmail = v; return nil },
+	},
+	{
+		key: "ACME_CACHE_DIR",
+		get: func(c *Config) string { return c.ACMECacheDir },
+		set: func(c *Config, v string) error { c.ACMECacheDir = v; return nil },
+	},
+	portSetting("HTTP_REDIRECT_PORT", func(c *Config) *string { return &c.HTTPRedirectPort }),
+	portSetting("METRICS_PORT", func(c *Config) *string { return &c.MetricsPort }),
+	// Tracing uses the standard OpenTelemetry variable names
+	{
+		key: "OTEL_EXPORTER_OTLP_ENDPOINT",
+		get: func(c *Config) string { return c.OTLPEndpoint },
+		set: func(c *Config, v string) error {
+			u, err := url.Parse(v)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return fmt.Errorf("must be an absolute http or https URL")
+			}
+			c.OTLPEndpoint = v
+			return nil
+		},
+	},
+	{
+		key:    "OTEL_EXPORTER_OTLP_HEADERS",
+		secret: true,
+		get: func(c *Config) string {
+			pairs := make([]string, 0, len(c.OTLPHeaders))
+			for k, v := range c.OTLPHeaders {
+				pairs = append(pairs, k+"="+url.QueryEscape(v))
+			}
+			slices.Sort(pairs)
+			return strings.Join(pairs, ",")
+		},
+		set: func(c *Config, v string) error {
+			c.OTLPHeaders = map[string]string{}
+			for _, pair := range splitList(v) {
+				k, val, ok := strings.Cut(pair, "=")
+				if !ok || strings.TrimSpace(k) == "" {
+					return fmt.Errorf("expected comma separated key=value pairs")
+				}
+				// Values are URL encoded, as in the OpenTelemetry specification
+				decoded, err := url.QueryUnescape(strings.TrimSpace(val))
+				if err != nil {
+					return fmt.Errorf("header %s: %w", k, err)
+				}
+				c.OTLPHeaders[strings.TrimSpace(k)] = decoded
+			}
+			return nil
+		},
+	},
+	{
+		key: "OTEL_SERVICE_NAME",
+		get: func(c *Config) string { return c.ServiceName },
+		set: func(c *Config, v string) error { c.ServiceName = v; return nil },
+	},
+	{
+		key: "CORS_ALLOWED_ORIGINS",
+		get: func(c *Config) string { return strings.Join(c.CORSAllowedOrigins, ",") },
+		set: func(c *Config, v string) error {
+			c.CORSAllowedOrigins = nil
+			for _, origin := range splitList(v) {
+				if origin != "*" {
+					u, err := url.Parse(origin)
+					if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" ||
+						strings.TrimRight(u.Path, "/") != "" || u.RawQuery != "" {
+						return fmt.Errorf("invalid origin %q, expected scheme://host[:port] or *", origin)
+					}
+					origin = u.Scheme + "://" + strings.ToLower(u.Host)
+				}
+				c.CORSAllowedOrigins = append(c.CORSAllowedOrigins, origin)
+			}
+			return nil
+		},
+	},
+	boolSetting("CORS_ALLOW_CREDENTIALS", func(c *Config) *bool { return &c.CORSAllowCredentials }),
+	{
+		key: "CORS_MAX_AGE",
+		get: func(c *Config) string { return strconv.Itoa(c.CORSMaxAge) },
+		set: func(c *Config, v string) error {
+			seconds, err := strconv.Atoi(v)
+			if err != nil || seconds < 0 || seconds > 86400 {
+				return fmt.Errorf("must be a number of seconds between 0 and 86400")
+			}
+			c.CORSMaxAge = seconds
+			return nil
+		},
+	},
+	{
+		key: "AUTH_MODE",
+		get: func(c *Config) string { return c.AuthMode },
+		set: func(c *Config, v string) error {
+			v = strings.ToLower(v)
+			if v != AuthModeBearer && v != AuthModeCookie {
+				return fmt.Errorf("must be %q or %q", AuthModeBearer, AuthModeCookie)
+			}
+			c.AuthMode = v
+			return nil
+		},
+	},
+	boolSetting("SESSION_COOKIE_SECURE", func(c *Config) *bool { return &c.SessionCookieSecure }),
+	{
+		key: "SESSION_COOKIE_SAMESITE",
+		get: func(c *Config) string { return c.SessionCookieSameSite },
+		set: func(c *Config, v string) error {
+			v = strings.ToLower(v)
+			if v != "lax" && v != "strict" && v != "none" {
+				return fmt.Errorf("must be lax, strict or none")
+			}
+			c.SessionCookieSameSite = v
+			return nil
+		},
+	},
+	boolSetting("HSTS_INCLUDE_SUBDOMAINS", func(c *Config) *bool { return &c.HSTSIncludeSubdomains }),
+}
 
-	// Lookup PORT and validate it
-	if port, ok := os.LookupEnv("PORT"); ok {
-		if portInt, err := strconv.Atoi(port); err == nil && portInt > 0 && portInt <= 65535 {
-			cfg.Port = port
-		} else {
-			return nil, fmt.Errorf("invalid PORT value: %s", port)
+// splitList splits a comma separated value, dropping empty items
+func splitList(v string) []string {
+	var items []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
 		}
 	}
+	return items
+}
 
-	// Lookup TWILIO_ACCOUNT_SID
-	if accountSid, ok := os.LookupEnv("TWILIO_ACCOUNT_SID"); ok {
-		cfg.TwilioAccountSID = accountSid
-	} else {
-		return nil, fmt.Errorf("TWILIO_ACCOUNT_SID not set")
+// checkSID validates the two letter prefix of a Twilio SID
+func checkSID(v, prefix string) error {
+	if !strings.HasPrefix(v, prefix) {
+		return fmt.Errorf("must start with %q", prefix)
 	}
+	return nil
+}
 
-	// Lookup TWILIO_API_KEY
-	if apiKey, ok := os.LookupEnv("TWILIO_API_KEY"); ok {
-		cfg.TwilioApiKey = apiKey
-	} else {
-		return nil, fmt.Errorf("TWILIO_API_KEY not set")
+// Load loads the configuration from an optional file, overridden by
+// environment variables. Any key can also be read from a file named by
+// <KEY>_FILE, which suits Docker and Kubernetes secret mounts.
+//
+// All problems are reported together in the returned error.
+func Load(path string) (*Config, error) {
+	var errs []error
+
+	fileValues := map[string]string{}
+	if path != "" {
+		var err error
+		if fileValues, err = readConfigFile(path); err != nil {
+			return nil, err
+		}
+		for key := range fileValues {
+			if !knownKey(key) {
+				errs = append(errs, fmt.Errorf("%s: unknown key %q", path, key))
+			}
+		}
 	}
 
-	// Lookup TWILIO_API_SECRET
-	if apiSecret, ok := os.LookupEnv("TWILIO_API_SECRET"); ok {
-		cfg.TwilioApiSecret = apiSecret
-	} else {
-		return nil, fmt.Errorf("TWILIO_API_SECRET not set")
+	cfg := &Config{
+		Port:        "8080",   // Default port
+		SMTPPort:    "587",    // Default SMTP submission port
+		ServiceName: "awwdio", // Default OpenTelemetry service name
+		CORSMaxAge:  600,      // Cache CORS preflights for 10 minutes
+
+		AuthMode:              AuthModeBearer,
+		SessionCookieSecure:   true, // Browsers accept Secure cookies on http://localhost too
+		SessionCookieSameSite: "lax",
 	}
 
-	// Lookup TWILIO_VERIFY_SERVICE_SID
-	if verifyServiceSid, ok := os.LookupEnv("TWILIO_VERIFY_SERVICE_SID"); ok {
-		cfg.TwilioVerifyServiceSID = verifyServiceSid
-	} else {
-		return nil, fmt.Errorf("TWILIO_VERIFY_SERVICE_SID not set")
+	for _, s := range settings {
+		// Environment variables win over the config file
+		v, ok, err := lookup(s.key, os.LookupEnv)
+		if err == nil && !ok {
+			v, ok, err = lookup(s.key, func(k string) (string, bool) {
+				v, ok := fileValues[k]
+				return v, ok
+			})
+		}
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if !ok || v == "" {
+			if s.required {
+				errs = append(errs, fmt.Errorf("%s not set", s.key))
+			}
+			continue
+		}
+		if err := s.set(cfg, v); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", s.key, err))
+		}
 	}
 
-	// Lookup JWT_SECRET (required for authentication)
-	if jwtSecret, ok := os.LookupEnv("JWT_SECRET"); ok {
-		cfg.JWTSecret = jwtSecret
-	} else {
-		return nil, fmt.Errorf("JWT_SECRET not set")
+	// Cross-field checks
+	if cfg.RecordCalls && cfg.PublicURL == "" {
+		errs = append(errs, fmt.Errorf("RECORD_CALLS requires PUBLIC_URL to be set"))
+	}
+	// Callbacks are only sent when PUBLIC_URL is set, and must be signed
+	if (cfg.PublicURL != "" || cfg.RecordCalls) && cfg.TwilioAuthToken == "" {
+		errs = append(errs, fmt.Errorf("TWILIO_AUTH_TOKEN must be set when PUBLIC_URL or RECORD_CALLS is set, to verify Twilio callbacks"))
+	}
+	if cfg.SMTPHost != "" && cfg.SMTPFrom == "" {
+		errs = append(errs, fmt.Errorf("SMTP_FROM must be set when SMTP_HOST is set"))
+	}
+	if (cfg.TLSCertFile == "") != (cfg.TLSKeyFile == "") {
+		errs = append(errs, fmt.Errorf("TLS_CERT_FILE and TLS_KEY_FILE must be set together"))
+	}
+	if cfg.TLSCertFile != "" && len(cfg.ACMEDomains) > 0 {
+		errs = append(errs, fmt.Errorf("TLS_CERT_FILE and ACME_DOMAINS cannot both be set"))
+	}
+	if cfg.HTTPRedirectPort != "" && cfg.TLSCertFile == "" && len(cfg.ACMEDomains) == 0 {
+		errs = append(errs, fmt.Errorf("HTTP_REDIRECT_PORT requires TLS_CERT_FILE or ACME_DOMAINS"))
+	}
+	if cfg.HTTPRedirectPort != "" && cfg.HTTPRedirectPort == cfg.Port {
+		errs = append(errs, fmt.Errorf("HTTP_REDIRECT_PORT must differ from PORT"))
+	}
+	if cfg.MetricsPort != "" && (cfg.MetricsPort == cfg.Port || cfg.MetricsPort == cfg.HTTPRedirectPort) {
+		errs = append(errs, fmt.Errorf("METRICS_PORT must differ from PORT and HTTP_REDIRECT_PORT"))
+	}
+	if cfg.CORSAllowCredentials && slices.Contains(cfg.CORSAllowedOrigins, "*") {
+		// Browsers reject credentialed responses to a wildcard origin
+		errs = append(errs, fmt.Errorf("CORS_ALLOW_CREDENTIALS cannot be used with CORS_ALLOWED_ORIGINS=*"))
+	}
+	if cfg.SessionCookieSameSite == "none" && !cfg.SessionCookieSecure {
+		errs = append(errs, fmt.Errorf("SESSION_COOKIE_SAMESITE=none requires SESSION_COOKIE_SECURE"))
+	}
+	if len(cfg.ACMEDomains) > 0 && cfg.ACMECacheDir == "" {
+		// Certificates must survive restarts to stay within ACME rate limits
+		cfg.ACMECacheDir = "acme-cache"
+		if cfg.DataDir != "" {
+			cfg.ACMECacheDir = filepath.Join(cfg.DataDir, "acme")
+		}
+	}
+	// Admins are matched against normalized subjects
+	for i, user := range cfg.AdminUsers {
+		normalized, err := normalize.Subject(user, cfg.DefaultCountryCode)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("ADMIN_USERS: %q: %w", user, err))
+			continue
+		}
+		cfg.AdminUsers[i] = normalized
+	}
+	if cfg.AuditLogFile == "" && cfg.DataDir != "" {
+		cfg.AuditLogFile = filepath.Join(cfg.DataDir, "audit.jsonl")
+	}
+	if cfg.AuditLogFile != "" && cfg.AuditHMACKey == "" {
+		errs = append(errs, fmt.Errorf("AUDIT_HMAC_KEY must be set when AUDIT_LOG_FILE or DATA_DIR is set"))
 	}
 
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
 	return cfg, nil
 }
+
+// lookup reads key, or the file named by key_FILE, from a source
+func lookup(key string, source func(string) (string, bool)) (string, bool, error) {
+	if v, ok := source(key); ok {
+		return v, true, nil
+	}
+	path, ok := source(key + "_FILE")
+	if !ok {
+		return "", false, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", false, fmt.Errorf("%s_FILE: %w", key, err)
+	}
+	return strings.TrimRight(string(data), "\r\n"), true, nil
+}
+
+// knownKey reports whether a config file key names a setting
+func knownKey(key string) bool {
+	key = strings.TrimSuffix(key, "_FILE")
+	for _, s := range settings {
+		if s.key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteRedacted prints the effective configuration as KEY=value lines,
+// with secrets masked
+func (c *Config) WriteRedacted(w io.Writer) {
+	for _, s := range settings {
+		v := s.get(c)
+		if s.secret && v != "" {
+			v = "[redacted]"
+		}
+		fmt.Fprintf(w, "%s=%s\n", s.key, v)
+	}
+}
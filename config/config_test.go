@@ -0,0 +1,108 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// clearEnv unsets every setting and its _FILE variant for the test
+func clearEnv(t *testing.T) {
+	t.Helper()
+	for _, s := range settings {
+		for _, key := range []string{s.key, s.key + "_FILE", "CONFIG_FILE"} {
+			t.Setenv(key, "") // Restores the variable after the test
+			os.Unsetenv(key)
+		}
+	}
+}
+
+func writeFile(t *testing.T, name, data string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+const requiredYAML = `twilio_account_sid: AC1
+twilio_api_key: SK1
+twilio_api_secret: secret
+twilio_verify_service_sid: VA1
+jwt_secret: 0123456789abcdef0123456789abcdef
+`
+
+func TestLoad(t *testing.T) {
+	clearEnv(t)
+	secretFile := writeFile(t, "secret", "from-secret-file-0123456789abcdef\n")
+	path := writeFile(t, "awwdio.yaml", requiredYAML+"port: 9000\njwt_secret_file: "+secretFile+"\nrecord_calls: false\n")
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Port != "9000" || cfg.TwilioAccountSID != "AC1" || cfg.SMTPPort != "587" {
+		t.Errorf("file values and defaults: %+v", cfg)
+	}
+	// A key set directly wins over its _FILE variant
+	if cfg.JWTSecret != "0123456789abcdef0123456789abcdef" {
+		t.Errorf("JWT_SECRET %q, want the file's value", cfg.JWTSecret)
+	}
+
+	// The environment wins over the file, _FILE variants included
+	t.Setenv("PORT", "9001")
+	t.Setenv("JWT_SECRET_FILE", secretFile)
+	if cfg, err = Load(path); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Port != "9001" || cfg.JWTSecret != "from-secret-file-0123456789abcdef" {
+		t.Errorf("environment: PORT %q, JWT_SECRET %q", cfg.Port, cfg.JWTSecret)
+	}
+}
+
+// Every problem is reported at once, not just the first
+func TestLoadReportsAllErrors(t *testing.T) {
+	clearEnv(t)
+	path := writeFile(t, "awwdio.yaml", `twilio_account_sid: XX1
+port: 99999
+record_calls: maybe
+metrics_port: 8080
+smtp_host: smtp.example.com
+tls_cert_file: cert.pem
+unknown_key: 1
+jwt_secret_file: /nonexistent/secret
+`)
+	t.Setenv("PORT", "8080")
+	_, err := Load(path)
+	if err == nil {
+		t.Fatal("no error")
+	}
+	for _, want := range []string{
+		`unknown key "UNKNOWN_KEY"`,
+		"TWILIO_ACCOUNT_SID: must start with",
+		"TWILIO_API_KEY not set",
+		"JWT_SECRET_FILE: open /nonexistent/secret",
+		`RECORD_CALLS: invalid boolean "maybe"`,
+		"SMTP_FROM must be set when SMTP_HOST is set",
+		"TLS_CERT_FILE and TLS_KEY_FILE must be set together",
+		"METRICS_PORT must differ from PORT",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error does not mention %q:\n%v", want, err)
+		}
+	}
+	// The environment overrode the invalid port in the file
+	if strings.Contains(err.Error(), "99999") {
+		t.Errorf("reported the overridden PORT:\n%v", err)
+	}
+}
+
+func TestLoadRejectsTOMLTables(t *testing.T) {
+	clearEnv(t)
+	path := writeFile(t, "awwdio.toml", "port = 8080\n\n[twilio]\naccount_sid = \"AC1\"\n")
+	if _, err := Load(path); err == nil || !strings.Contains(err.Error(), "line 3: tables are not supported") {
+		t.Errorf("got %v", err)
+	}
+}
@@ -0,0 +1,181 @@
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// readConfigFile reads a flat config file into upper-cased keys. The
+// format is chosen by extension: .json, .yaml/.yml or .toml.
+//
+// To stay free of third-party parsers, YAML and TOML support the flat subset
+// the configuration needs: one "key: value" or "key = value" per line,
+// quoted or bare scalars, inline [a, b] lists, and # comments. YAML block
+// lists ("- item" lines under a key) are accepted too.
+func readConfigFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var values map[string]string
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".json":
+		values, err = parseJSON(data)
+	case ".yaml", ".yml":
+		values, err = parseFlat(data, ":")
+	case ".toml":
+		values, err = parseFlat(data, "=")
+	default:
+		return nil, fmt.Errorf("unsupported config file format %q", ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return values, nil
+}
+
+// parseJSON reads a JSON object of scalars and arrays of scalars
+func parseJSON(data []byte) (map[string]string, error) {
+	var raw map[string]any
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&raw); err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]string, len(raw))
+	for k, v := range raw {
+		list, ok := v.([]any)
+		if !ok {
+			list = []any{v}
+		}
+		items := make([]string, 0, len(list))
+		for _, item := range list {
+			s, err := jsonScalar(item)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", k, err)
+			}
+			items = append(items, s)
+		}
+		values[strings.ToUpper(k)] = strings.Join(items, ",")
+	}
+	return values, nil
+}
+
+func jsonScalar(v any) (string, error) {
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	default:
+		return "", fmt.Errorf("unsupported value type %T", v)
+	}
+}
+
+// parseFlat reads the flat YAML or TOML subset, with sep separating keys from values
+func parseFlat(data []byte, sep string) (map[string]string, error) {
+	values := map[string]string{}
+	listKey := "" // YAML key whose block list is being read
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(stripComment(scanner.Text()))
+		if line == "" || line == "---" {
+			continue
+		}
+
+		if item, ok := strings.CutPrefix(line, "- "); ok && listKey != "" {
+			if values[listKey] != "" {
+				values[listKey] += ","
+			}
+			values[listKey] += unquote(strings.TrimSpace(item))
+			continue
+		}
+		listKey = ""
+
+		if strings.HasPrefix(line, "[") {
+			return nil, fmt.Errorf("line %d: tables are not supported, use top-level keys", n)
+		}
+		key, value, ok := strings.Cut(line, sep)
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected key%svalue", n, sep)
+		}
+		key = strings.ToUpper(strings.TrimSpace(unquote(strings.TrimSpace(key))))
+		value = strings.TrimSpace(value)
+
+		switch {
+		case value == "" && sep == ":":
+			// Either empty or followed by a block list
+			listKey = key
+			values[key] = ""
+		case strings.HasPrefix(value, "[") && strings.HasSuffix(value, "]"):
+			var items []string
+			for _, item := range strings.Split(value[1:len(value)-1], ",") {
+				if item = unquote(strings.TrimSpace(item)); item != "" {
+					items = append(items, item)
+				}
+			}
+			values[key] = strings.Join(items, ",")
+		default:
+			values[key] = unquote(value)
+		}
+	}
+	return values, scanner.Err()
+}
+
+// stripComment removes a # comment. As in YAML, # only starts a comment
+// at the start of the line or after whitespace, outside quotes, so values
+// such as secrets and URL fragments may contain it. Quotes only count at
+// the start of a value, so apostrophes in bare values are plain text.
+func stripComment(line string) string {
+	var quote rune
+	escaped := false // The previous rune was a backslash in a double-quoted value
+	prev := ' '
+	for i, r := range line {
+		switch {
+		case escaped:
+			escaped = false
+		case quote == '"' && r == '\\':
+			escaped = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case (r == '"' || r == '\'') && startsValue(prev):
+			quote = r
+		case r == '#' && unicode.IsSpace(prev):
+			return line[:i]
+		}
+		prev = r
+	}
+	return line
+}
+
+// startsValue reports whether a quote following prev opens a value
+func startsValue(prev rune) bool {
+	return unicode.IsSpace(prev) || strings.ContainsRune(":=[,-", prev)
+}
+
+// unquote removes matching single or double quotes around a value
+func unquote(v string) string {
+	if len(v) >= 2 && (v[0] == '"' || v[0] == '\'') && v[len(v)-1] == v[0] {
+		if v[0] == '"' {
+			if s, err := strconv.Unquote(v); err == nil {
+				return s
+			}
+		}
+		return v[1 : len(v)-1]
+	}
+	return v
+}
@@ -0,0 +1,134 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseFlat(t *testing.T) {
+	tests := []struct {
+		name string
+		sep  string
+		data string
+		want map[string]string
+		err  string // Part of the error, "" if it parses
+	}{
+		{
+			name: "bare and quoted",
+			sep:  ":",
+			data: "port: 8080\njwt_secret: \"a b\"\nservice_name: 'awwdio'\n",
+			want: map[string]string{"PORT": "8080", "JWT_SECRET": "a b", "SERVICE_NAME": "awwdio"},
+		},
+		{
+			name: "escapes in double quotes",
+			sep:  ":",
+			data: `a: "tab\there \"quoted\" # not a comment"` + "\n" + `b: "back\\" # comment`,
+			want: map[string]string{"A": "tab\there \"quoted\" # not a comment", "B": `back\`},
+		},
+		{
+			name: "comments",
+			sep:  ":",
+			data: "# heading\nport: 8080 # the port\n   # indented\n---\n",
+			want: map[string]string{"PORT": "8080"},
+		},
+		{
+			name: "hash inside bare values",
+			sep:  ":",
+			data: "jwt_secret: ab#cd#ef\npublic_url: https://x.example.com/#/ # app\n",
+			want: map[string]string{"JWT_SECRET": "ab#cd#ef", "PUBLIC_URL": "https://x.example.com/#/"},
+		},
+		{
+			name: "apostrophe in a bare value",
+			sep:  ":",
+			data: "smtp_from: Bob's calls <calls@example.com> # sender\n",
+			want: map[string]string{"SMTP_FROM": "Bob's calls <calls@example.com>"},
+		},
+		{
+			name: "hash in quotes",
+			sep:  ":",
+			data: "a: 'x # y' # z\nb: \"x # y\"\n",
+			want: map[string]string{"A": "x # y", "B": "x # y"},
+		},
+		{
+			name: "inline list",
+			sep:  ":",
+			data: "admin_users: [alice@example.com, \"bob@example.com\", ]\n",
+			want: map[string]string{"ADMIN_USERS": "alice@example.com,bob@example.com"},
+		},
+		{
+			name: "block list",
+			sep:  ":",
+			data: "admin_users:\n  - alice@example.com # first\n  - 'bob@example.com'\nport: 8080\n",
+			want: map[string]string{"ADMIN_USERS": "alice@example.com,bob@example.com", "PORT": "8080"},
+		},
+		{
+			name: "empty value",
+			sep:  ":",
+			data: "public_url:\n",
+			want: map[string]string{"PUBLIC_URL": ""},
+		},
+		{
+			name: "toml",
+			sep:  "=",
+			data: "# awwdio\nport = 8080\njwt_secret = \"s#cret\" # comment\nadmin_users = [\"alice@example.com\", \"bob@example.com\"]\n",
+			want: map[string]string{"PORT": "8080", "JWT_SECRET": "s#cret", "ADMIN_USERS": "alice@example.com,bob@example.com"},
+		},
+		{
+			name: "toml table",
+			sep:  "=",
+			data: "port = 8080\n[twilio]\naccount_sid = \"AC1\"\n",
+			err:  "line 2: tables are not supported",
+		},
+		{
+			name: "missing separator",
+			sep:  ":",
+			data: "port 8080\n",
+			err:  "line 1: expected key:value",
+		},
+	}
+	for _, tt := range tests {
+		got, err := parseFlat([]byte(tt.data), tt.sep)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%s: got %v, want %q", tt.name, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+		for k, v := range tt.want {
+			if got[k] != v {
+				t.Errorf("%s: %s = %q, want %q", tt.name, k, got[k], v)
+			}
+		}
+	}
+}
+
+func TestReadConfigFileJSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "awwdio.json")
+	data := `{"port": 8080, "record_calls": true, "admin_users": ["alice@example.com", "bob@example.com"]}`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	got, err := readConfigFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got["PORT"] != "8080" || got["RECORD_CALLS"] != "true" || got["ADMIN_USERS"] != "alice@example.com,bob@example.com" {
+		t.Errorf("got %q", got)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"twilio": {"account_sid": "AC1"}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readConfigFile(path); err == nil || !strings.Contains(err.Error(), "unsupported value type") {
+		t.Errorf("nested object: got %v", err)
+	}
+}
@@ -0,0 +1,133 @@
+package config
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// pollInterval is how often the config file is checked for changes
+const pollInterval = 5 * time.Second
+
+// Provider publishes the current configuration. Handlers call Get once per
+// request and use that snapshot throughout, so a reload never mixes old and
+// new values within a request.
+type Provider struct {
+	path    string
+	current atomic.Pointer[Config]
+	mu      sync.Mutex // serializes reloads
+}
+
+// NewProvider publishes cfg, which was loaded from path (empty if no file was used)
+func NewProvider(cfg *Config, path string) *Provider {
+	p := &Provider{path: path}
+	p.current.Store(cfg)
+	return p
+}
+
+// Static returns a provider that always returns cfg
+func Static(cfg *Config) *Provider {
+	return NewProvider(cfg, "")
+}
+
+// Get returns the current configuration. It must not be modified.
+func (p *Provider) Get() *Config {
+	return p.current.Load()
+}
+
+// Reload loads the configuration again and publishes it. On failure the
+// previous configuration stays in effect.
+func (p *Provider) Reload() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	next, err := Load(p.path)
+	if err != nil {
+		slog.Error("Failed to reload configuration, keeping previous configuration", slog.String("error", err.Error()))
+		return err
+	}
+
+	if keepRestartOnly(next, p.Get()) {
+		slog.Warn("Listener, TLS, tracing, audit and DATA_DIR changes take effect after a restart")
+	}
+
+	p.current.Store(next)
+	slog.Info("Configuration reloaded")
+	return nil
+}
+
+// keepRestartOnly copies the settings that are only read at startup from
+// prev to next, so that next describes what the process uses. It reports
+// whether any of them changed.
+func keepRestartOnly(next, prev *Config) bool {
+	changed := false
+	keep := func(n, p *string) {
+		changed = changed || *n != *p
+		*n = *p
+	}
+	keep(&next.Port, &prev.Port)
+	keep(&next.HTTPRedirectPort, &prev.HTTPRedirectPort)
+	keep(&next.MetricsPort, &prev.MetricsPort)
+	keep(&next.DataDir, &prev.DataDir)
+	keep(&next.AuditLogFile, &prev.AuditLogFile)
+	keep(&next.AuditHMACKey, &prev.AuditHMACKey)
+	keep(&next.OTLPEndpoint, &prev.OTLPEndpoint)
+	keep(&next.ServiceName, &prev.ServiceName)
+	keep(&next.TLSCertFile, &prev.TLSCertFile)
+	keep(&next.TLSKeyFile, &prev.TLSKeyFile)
+	keep(&next.ACMECacheDir, &prev.ACMECacheDir)
+	keep(&next.ACMEEmail, &prev.ACMEEmail)
+	if !slices.Equal(next.ACMEDomains, prev.ACMEDomains) {
+		changed = true
+		next.ACMEDomains = prev.ACMEDomains
+	}
+	return changed
+}
+
+// Watch reloads the configuration on SIGHUP and when the config file
+// changes, until ctx is done
+func (p *Provider) Watch(ctx context.Context) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	lastMod := p.modTime()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			slog.Info("Received SIGHUP, reloading configuration")
+			p.Reload()
+			lastMod = p.modTime()
+		case <-ticker.C:
+			if mod := p.modTime(); !mod.Equal(lastMod) {
+				slog.Info("Config file changed, reloading configuration", slog.String("path", p.path))
+				lastMod = mod
+				p.Reload()
+			}
+		}
+	}
+}
+
+// modTime returns the config file modification time, or the zero time
+// when there is no file
+func (p *Provider) modTime() time.Time {
+	if p.path == "" {
+		return time.Time{}
+	}
+	info, err := os.Stat(p.path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
@@ -0,0 +1,39 @@
+package config
+
+import (
+	"os"
+	"testing"
+)
+
+func TestReload(t *testing.T) {
+	clearEnv(t)
+	path := writeFile(t, "awwdio.yaml", requiredYAML+"port: 9000\npublic_url: https://a.example.com\ntwilio_auth_token: t\n")
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := NewProvider(cfg, path)
+
+	// A bad file keeps the previous snapshot
+	if err := os.WriteFile(path, []byte(requiredYAML+"port: nope\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Reload(); err == nil {
+		t.Error("reloaded an invalid file")
+	}
+	if p.Get() != cfg {
+		t.Error("failed reload replaced the configuration")
+	}
+
+	// Restart-only settings keep the values in use
+	data := requiredYAML + "port: 9001\npublic_url: https://b.example.com\ntwilio_auth_token: t\n"
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if got := p.Get(); got.PublicURL != "https://b.example.com" || got.Port != "9000" {
+		t.Errorf("PUBLIC_URL %q, PORT %q; want the new URL and the old port", got.PublicURL, got.Port)
+	}
+}
@@ -2,11 +2,16 @@ module github.com/kaustavdm/awwdio
 
 go 1.24.1
 
-require github.com/twilio/twilio-go v1.25.1
+require (
+	github.com/twilio/twilio-go v1.25.1
+	golang.org/x/crypto v0.40.0
+)
 
 require (
 	github.com/golang-jwt/jwt v3.2.2+incompatible // indirect
 	github.com/golang/mock v1.6.0 // indirect
 	github.com/pkg/errors v0.9.1 // indirect
 	github.com/stretchr/testify v1.10.0 // indirect
+	golang.org/x/net v0.41.0 // indirect
+	golang.org/x/text v0.27.0 // indirect
 )
@@ -1,41 +1,190 @@
 package api
 
 import (
+	"context"
+	"log/slog"
 	"net/http"
+	"sync"
 
 	"github.com/kaustavdm/awwdio/config"
+	"github.com/kaustavdm/awwdio/internal/api/apikeys"
 	"github.com/kaustavdm/awwdio/internal/api/auth"
+	"github.com/kaustavdm/awwdio/internal/api/calls"
 	"github.com/kaustavdm/awwdio/internal/api/middleware"
+	"github.com/kaustavdm/awwdio/internal/api/openapi"
+	"github.com/kaustavdm/awwdio/internal/api/user"
 	"github.com/kaustavdm/awwdio/internal/api/video"
+	"github.com/kaustavdm/awwdio/internal/api/webhooks"
+	"github.com/kaustavdm/awwdio/internal/audit"
+	"github.com/kaustavdm/awwdio/internal/metrics"
+	"github.com/kaustavdm/awwdio/internal/notify"
+	"github.com/kaustavdm/awwdio/internal/store"
 )
 
 type API struct {
-	config *config.Config
+	config *config.Provider
 
 	// Load sub-APIs
-	authHandler  *auth.Handler
-	videoHandler *video.Handler
+	authHandler     *auth.Handler
+	videoHandler    *video.Handler
+	callsHandler    *calls.Handler
+	userHandler     *user.Handler
+	webhooksHandler *webhooks.Handler
+	auditHandler    *audit.Handler
+	apiKeysHandler  *apikeys.Handler
+
+	audit   *audit.Log
+	apiKeys *auth.APIKeys
+	docs    *openapi.Document
+
+	queue   *notify.Queue
+	workers sync.WaitGroup
+}
+
+// New sets up the API. Its background workers run until ctx is done;
+// Shutdown then waits for them.
+func New(ctx context.Context, cfg *config.Provider, st *store.Store) (*API, error) {
+	// Security-relevant events go to a hash-chained, append-only log
+	auditLog, err := audit.Open(cfg.Get().AuditLogFile, []byte(cfg.Get().AuditHMACKey))
+	if err != nil {
+		return nil, err
+	}
+
+	// Outbound webhooks are delivered in the background with retries
+	webhooksH, err := webhooks.NewHandler(st)
+	if err != nil {
+		return nil, err
+	}
+
+	authH, err := auth.NewHandler(cfg, st, webhooksH, auditLog)
+	if err != nil {
+		return nil, err
+	}
+	// Service accounts authenticate with API keys instead of OTP
+	apiKeys, err := auth.NewAPIKeys(st)
+	if err != nil {
+		return nil, err
+	}
+	videoH, err := video.NewHandler(cfg, st, webhooksH, auditLog)
+	if err != nil {
+		return nil, err
+	}
+	// Notifications are sent in the background with retries
+	queue := notify.NewQueue(notify.New(cfg))
+	callsH, err := calls.NewHandler(cfg, st, queue)
+	if err != nil {
+		return nil, err
+	}
+	// Scheduled call rooms are only open to invitees during the call window
+	videoH.AddRoomGuard(callsH.CheckJoin)
+
+	a := &API{
+		config:          cfg,
+		authHandler:     authH,
+		videoHandler:    videoH,
+		callsHandler:    callsH,
+		userHandler:     user.NewHandler(videoH),
+		webhooksHandler: webhooksH,
+		auditHandler:    audit.NewHandler(auditLog),
+		apiKeysHandler:  apikeys.NewHandler(apiKeys, auditLog),
+		audit:           auditLog,
+		apiKeys:         apiKeys,
+		queue:           queue,
+	}
+	a.run(func() { webhooksH.Run(ctx) })
+	a.run(func() { callsH.RunReminders(ctx) })
+	return a, nil
+}
+
+// run runs a background worker that Shutdown waits for
+func (a *API) run(worker func()) {
+	a.workers.Add(1)
+	go func() {
+		defer a.workers.Done()
+		worker()
+	}()
 }
 
-func New(cfg *config.Config) *API {
-	authH := auth.NewHandler(cfg)
-	videoH := video.NewHandler(cfg)
-	return &API{
-		config:       cfg,
-		authHandler:  authH,
-		videoHandler: videoH,
+// Shutdown waits for the background workers, which stop when the context
+// given to New is done, then sends the notifications still queued. It
+// gives up on both when ctx is done.
+func (a *API) Shutdown(ctx context.Context) {
+	stopped := make(chan struct{})
+	go func() {
+		a.workers.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		slog.Error("API workers did not stop in time")
 	}
+	a.queue.Drain(ctx)
 }
 
 func (a *API) Register(mux *http.ServeMux) {
+	// Routes are described as modules register them, and the description
+	// is served as an OpenAPI document
+	docs := openapi.New("awwdio API", "1.0.0")
+	docs.SessionCookie = auth.SessionCookie
+	mux.Handle("GET /openapi.json", docs)
+	a.docs = docs
+
+	// Module muxes are wrapped in JSONNotFound, so that unknown paths and
+	// methods get JSON errors like the rest of the API. API keys also need
+	// the scope of the mux.
+	routes := func(m *openapi.Mux) http.Handler {
+		h := metrics.Routes(m.Prefix(), middleware.JSONNotFound(m.ServeMux))
+		if m.Scope() != "" {
+			h = middleware.RequireScope(m.Scope())(h)
+		}
+		return h
+	}
+
 	// Register auth mux
-	authMux := http.NewServeMux()
+	authMux := docs.Mux("/api/auth", openapi.AuthNone, "")
 	a.authHandler.Register(authMux)
-	mux.Handle("/auth/", http.StripPrefix("/auth", authMux))
+	mux.Handle("/auth/", http.StripPrefix("/auth", routes(authMux)))
 
 	// Register video mux with auth middleware
-	videoMux := http.NewServeMux()
+	videoMux := docs.Mux("/api/video", openapi.AuthUser, auth.ScopeVideo)
 	a.videoHandler.Register(videoMux)
-	authMiddleware := middleware.RequireAuth(a.config.JWTSecret)
-	mux.Handle("/video/", http.StripPrefix("/video", authMiddleware(videoMux)))
+	authMiddleware := middleware.RequireAuth(a.config, a.apiKeys, a.authHandler.Users())
+	mux.Handle("/video/", http.StripPrefix("/video", authMiddleware(routes(videoMux))))
+
+	// Register Twilio callbacks, which are validated by signature instead of JWT
+	callbackMux := docs.Mux("/api/video/callbacks", openapi.AuthTwilio, "")
+	a.videoHandler.RegisterCallbacks(callbackMux)
+	mux.Handle("/video/callbacks/", http.StripPrefix("/video/callbacks", routes(callbackMux)))
+
+	// Register user mux with auth middleware
+	userMux := docs.Mux("/api/user", openapi.AuthUser, auth.ScopeUser)
+	a.userHandler.Register(userMux)
+	mux.Handle("/user/", http.StripPrefix("/user", authMiddleware(routes(userMux))))
+
+	// Register calls mux with auth middleware. Patterns include the /calls
+	// prefix so that POST /calls works without a trailing slash.
+	callsMux := docs.Mux("/api", openapi.AuthUser, auth.ScopeCalls)
+	a.callsHandler.Register(callsMux)
+	callsRoutes := authMiddleware(routes(callsMux))
+	mux.Handle("/calls", callsRoutes)
+	mux.Handle("/calls/", callsRoutes)
+
+	// Calendar invitations are fetched by calendar clients without a session
+	invitesMux := docs.Mux("/api", openapi.AuthNone, "")
+	a.callsHandler.RegisterPublic(invitesMux)
+	mux.Handle("/calls/invites/", routes(invitesMux))
+
+	// Register admin mux with auth and admin middleware
+	adminMux := docs.Mux("/api/admin", openapi.AuthAdmin, auth.ScopeAdmin)
+	a.webhooksHandler.Register(adminMux)
+	a.auditHandler.Register(adminMux)
+	a.videoHandler.RegisterAdmin(adminMux)
+	a.apiKeysHandler.Register(adminMux)
+	adminMiddleware := middleware.RequireAdmin(a.config)
+	// Admin requests are audited, including those denied by the admin check
+	auditAdmin := a.audit.AdminRequests(func(r *http.Request) string {
+		return middleware.GetUser(r).Actor()
+	})
+	mux.Handle("/admin/", http.StripPrefix("/admin", authMiddleware(auditAdmin(adminMiddleware(routes(adminMux))))))
 }
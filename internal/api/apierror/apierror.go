@@ -0,0 +1,143 @@
+// Package apierror writes API errors in one shape: a machine-readable code,
+// a human message, the request ID for support, and per-field details for
+// invalid input. Clients that accept application/problem+json get an
+// RFC 9457 problem document instead.
+package apierror
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/kaustavdm/awwdio/apitypes"
+	"github.com/kaustavdm/awwdio/internal/logging"
+)
+
+// Code identifies the kind of error. Clients should branch on codes, not
+// messages, which may change.
+type Code = apitypes.Code
+
+// Codes
+const (
+	CodeBadRequest           = apitypes.CodeBadRequest
+	CodeValidation           = apitypes.CodeValidation
+	CodeUnauthorized         = apitypes.CodeUnauthorized
+	CodeInvalidToken         = apitypes.CodeInvalidToken
+	CodeInvalidOTP           = apitypes.CodeInvalidOTP
+	CodeForbidden            = apitypes.CodeForbidden
+	CodeCSRF                 = apitypes.CodeCSRF
+	CodeNotFound             = apitypes.CodeNotFound
+	CodeMethodNotAllowed     = apitypes.CodeMethodNotAllowed
+	CodeConflict             = apitypes.CodeConflict
+	CodePayloadTooLarge      = apitypes.CodePayloadTooLarge
+	CodeUnsupportedMediaType = apitypes.CodeUnsupportedMediaType
+	CodeInternal             = apitypes.CodeInternal
+	CodeUpstream             = apitypes.CodeUpstream
+)
+
+// ProblemContentType is the RFC 9457 media type
+const ProblemContentType = "application/problem+json"
+
+// problemTypePrefix makes codes into the URIs problem documents identify
+// their type with
+const problemTypePrefix = "urn:awwdio:error:"
+
+// FieldError describes one invalid input field
+type FieldError = apitypes.FieldError
+
+// Error is an API error response
+type Error = apitypes.Error
+
+// New returns an error with the given status, code and message
+func New(status int, code Code, message string) *Error {
+	return &Error{Status: status, Code: code, Message: message}
+}
+
+func BadRequest(message string) *Error {
+	return New(http.StatusBadRequest, CodeBadRequest, message)
+}
+
+// Validation reports invalid input, field by field
+func Validation(message string, fields ...FieldError) *Error {
+	e := New(http.StatusBadRequest, CodeValidation, message)
+	e.Fields = fields
+	return e
+}
+
+func Unauthorized(message string) *Error {
+	return New(http.StatusUnauthorized, CodeUnauthorized, message)
+}
+
+func Forbidden(message string) *Error {
+	return New(http.StatusForbidden, CodeForbidden, message)
+}
+
+func NotFound(message string) *Error {
+	return New(http.StatusNotFound, CodeNotFound, message)
+}
+
+func Conflict(message string) *Error {
+	return New(http.StatusConflict, CodeConflict, message)
+}
+
+// Internal reports a failure on our side. Details belong in the logs, not
+// in message.
+func Internal(message string) *Error {
+	return New(http.StatusInternalServerError, CodeInternal, message)
+}
+
+// Upstream reports a failed call to Twilio or another service
+func Upstream(message string) *Error {
+	return New(http.StatusBadGateway, CodeUpstream, message)
+}
+
+// problem is the RFC 9457 form of an Error, with its code, request ID and
+// field errors as extension members
+type problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail"`
+	Instance  string       `json:"instance,omitempty"`
+	Code      Code         `json:"code"`
+	RequestID string       `json:"request_id,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+}
+
+// Write sends e as the response to r, adding the request ID. The body is
+// JSON, or a problem document if the client asked for one.
+func Write(w http.ResponseWriter, r *http.Request, e *Error) {
+	e.RequestID = logging.RequestID(r.Context())
+
+	if wantsProblem(r) {
+		w.Header().Set("Content-Type", ProblemContentType)
+		w.WriteHeader(e.Status)
+		json.NewEncoder(w).Encode(problem{
+			Type:      problemTypePrefix + string(e.Code),
+			Title:     http.StatusText(e.Status),
+			Status:    e.Status,
+			Detail:    e.Message,
+			Instance:  instance(r),
+			Code:      e.Code,
+			RequestID: e.RequestID,
+			Errors:    e.Fields,
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(e.Status)
+	json.NewEncoder(w).Encode(e)
+}
+
+// instance is the path the client requested. r.URL may have lost its
+// prefix to http.StripPrefix, and the query may hold personal data.
+func instance(r *http.Request) string {
+	path, _, _ := strings.Cut(r.RequestURI, "?")
+	return path
+}
+
+// wantsProblem reports whether the Accept header asks for problem+json
+func wantsProblem(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), ProblemContentType)
+}
@@ -0,0 +1,80 @@
+package apierror
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/kaustavdm/awwdio/internal/logging"
+)
+
+func TestWrite(t *testing.T) {
+	invalid := func() *Error {
+		return Validation("Invalid call",
+			FieldError{Field: "title", Message: "is required"},
+			FieldError{Field: "start", Message: "must be in the future"})
+	}
+	tests := []struct {
+		name      string
+		err       *Error
+		accept    string
+		requestID string
+
+		wantStatus int
+		wantType   string
+		wantBody   string
+	}{
+		{
+			name: "JSON", err: NotFound("Call not found"),
+			wantStatus: http.StatusNotFound, wantType: "application/json",
+			wantBody: `{"code":"not_found","error":"Call not found"}`,
+		},
+		{
+			name: "JSON with request ID and fields", err: invalid(), accept: "application/json", requestID: "req-1",
+			wantStatus: http.StatusBadRequest, wantType: "application/json",
+			wantBody: `{"code":"validation_failed","error":"Invalid call","request_id":"req-1",` +
+				`"fields":[{"field":"title","message":"is required"},{"field":"start","message":"must be in the future"}]}`,
+		},
+		{
+			name: "problem", err: NotFound("Call not found"), accept: ProblemContentType,
+			wantStatus: http.StatusNotFound, wantType: ProblemContentType,
+			wantBody: `{"type":"urn:awwdio:error:not_found","title":"Not Found","status":404,"detail":"Call not found",` +
+				`"instance":"/api/calls/42","code":"not_found"}`,
+		},
+		{
+			name: "problem among other types, with request ID and fields", err: invalid(),
+			accept: "application/json;q=0.5, application/problem+json", requestID: "req-1",
+			wantStatus: http.StatusBadRequest, wantType: ProblemContentType,
+			wantBody: `{"type":"urn:awwdio:error:validation_failed","title":"Bad Request","status":400,"detail":"Invalid call",` +
+				`"instance":"/api/calls/42","code":"validation_failed","request_id":"req-1",` +
+				`"errors":[{"field":"title","message":"is required"},{"field":"start","message":"must be in the future"}]}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The query may hold personal data, so it is not the instance
+			req := httptest.NewRequest(http.MethodPost, "/api/calls/42?to=alice@example.com", nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			if tt.requestID != "" {
+				req = req.WithContext(logging.WithRequestID(req.Context(), tt.requestID))
+			}
+			rec := httptest.NewRecorder()
+			Write(rec, req, tt.err)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if got := rec.Header().Get("Content-Type"); got != tt.wantType {
+				t.Errorf("Content-Type = %q, want %q", got, tt.wantType)
+			}
+			if got := rec.Body.String(); got != tt.wantBody+"\n" {
+				t.Errorf("body = %s\nwant   %s", got, tt.wantBody)
+			}
+			if tt.err.RequestID != tt.requestID {
+				t.Errorf("RequestID = %q, want %q", tt.err.RequestID, tt.requestID)
+			}
+		})
+	}
+}
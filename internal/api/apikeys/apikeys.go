@@ -0,0 +1,194 @@
+// Package apikeys is the admin API for service account API keys.
+package apikeys
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kaustavdm/awwdio/apitypes"
+	"github.com/kaustavdm/awwdio/internal/api/apierror"
+	"github.com/kaustavdm/awwdio/internal/api/auth"
+	"github.com/kaustavdm/awwdio/internal/api/middleware"
+	"github.com/kaustavdm/awwdio/internal/api/openapi"
+	"github.com/kaustavdm/awwdio/internal/api/request"
+	"github.com/kaustavdm/awwdio/internal/audit"
+	"github.com/kaustavdm/awwdio/internal/logging"
+)
+
+type Handler struct {
+	keys  *auth.APIKeys
+	audit *audit.Log
+}
+
+func NewHandler(keys *auth.APIKeys, auditLog *audit.Log) *Handler {
+	return &Handler{keys: keys, audit: auditLog}
+}
+
+// Register registers the API key management routes. They are meant to be
+// mounted behind admin authentication.
+func (h *Handler) Register(mux *openapi.Mux) {
+	mux.HandleFunc("POST /keys", h.createKey, openapi.Operation{
+		ID:       "createAPIKey",
+		Summary:  "Create an API key; the response is the only one to include the key",
+		Request:  CreateKeyRequest{},
+		Response: KeyResponse{},
+		Status:   http.StatusCreated,
+	})
+	mux.HandleFunc("GET /keys", h.listKeys, openapi.Operation{
+		ID:       "listAPIKeys",
+		Summary:  "List API keys, revoked and expired ones included",
+		Response: ListKeysResponse{},
+	})
+	mux.HandleFunc("POST /keys/{id}/rotate", h.rotateKey, openapi.Operation{
+		ID:       "rotateAPIKey",
+		Summary:  "Replace the secret of an API key; the old one stops working",
+		Response: KeyResponse{},
+	})
+	mux.HandleFunc("DELETE /keys/{id}", h.revokeKey, openapi.Operation{
+		ID:      "revokeAPIKey",
+		Summary: "Revoke an API key",
+		Status:  http.StatusNoContent,
+	})
+}
+
+type CreateKeyRequest = apitypes.CreateKeyRequest
+
+// KeyResponse is a key with its secret form, shown only once
+type KeyResponse = apitypes.KeyResponse
+
+type ListKeysResponse = apitypes.ListKeysResponse
+
+// sessionOnly rejects requests authenticated with API keys, so that a
+// leaked key cannot mint more keys
+func sessionOnly(w http.ResponseWriter, r *http.Request) (*middleware.UserClaims, bool) {
+	user := middleware.GetUser(r)
+	if user.KeyID != "" {
+		apierror.Write(w, r, apierror.Forbidden("API keys cannot manage API keys"))
+		return nil, false
+	}
+	return user, true
+}
+
+// createKey adds an API key
+func (h *Handler) createKey(w http.ResponseWriter, r *http.Request) {
+	log := logging.FromContext(r.Context())
+	w.Header().Set("Content-Type", "application/json")
+
+	user, ok := sessionOnly(w, r)
+	if !ok {
+		return
+	}
+
+	var req CreateKeyRequest
+	if err := request.Decode(w, r, &req); err != nil {
+		log.Debug("Invalid request body", "error", err)
+		apierror.Write(w, r, err)
+		return
+	}
+	if err := request.Validate(&req); err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	for _, s := range req.Scopes {
+		if !slices.Contains(auth.Scopes, s) {
+			apierror.Write(w, r, apierror.BadRequest("Unknown scope: "+s+" (one of "+strings.Join(auth.Scopes, ", ")+")"))
+			return
+		}
+	}
+	now := time.Now()
+	if !req.ExpiresAt.After(now) || req.ExpiresAt.After(now.Add(auth.MaxAPIKeyLifetime)) {
+		apierror.Write(w, r, apierror.BadRequest("Expiry must be in the future and at most a year away"))
+		return
+	}
+
+	key, secret, err := h.keys.Create(req.Name, slices.Compact(slices.Sorted(slices.Values(req.Scopes))), req.ExpiresAt, user.Subject)
+	if err != nil {
+		log.Error("Failed to create API key", "error", err)
+		apierror.Write(w, r, apierror.Internal("Failed to create API key"))
+		return
+	}
+
+	log.Info("API key created", "key_id", key.ID, "name", key.Name, "scopes", key.Scopes, "expires_at", key.ExpiresAt)
+	h.audit.Record(r, audit.Entry{Actor: user.Actor(), Action: audit.ActionAPIKeyCreated, Target: key.ID,
+		Outcome: audit.OutcomeSuccess, Details: map[string]string{"scopes": strings.Join(key.Scopes, ",")}})
+
+	key.Hash = ""
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(KeyResponse{APIKey: key, Key: secret})
+}
+
+// listKeys returns all API keys, newest first, without secrets
+func (h *Handler) listKeys(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	resp := ListKeysResponse{Keys: h.keys.List()}
+	if resp.Keys == nil {
+		resp.Keys = []auth.APIKey{}
+	}
+	sort.Slice(resp.Keys, func(i, j int) bool { return resp.Keys[i].CreatedAt.After(resp.Keys[j].CreatedAt) })
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// rotateKey gives an active API key a new secret
+func (h *Handler) rotateKey(w http.ResponseWriter, r *http.Request) {
+	log := logging.FromContext(r.Context())
+	w.Header().Set("Content-Type", "application/json")
+
+	user, ok := sessionOnly(w, r)
+	if !ok {
+		return
+	}
+
+	id := r.PathValue("id")
+	key, secret, err := h.keys.Rotate(id)
+	if errors.Is(err, auth.ErrAPIKeyNotFound) {
+		apierror.Write(w, r, apierror.NotFound("Active API key not found"))
+		return
+	}
+	if err != nil {
+		log.Error("Failed to rotate API key", "error", err, "key_id", id)
+		apierror.Write(w, r, apierror.Internal("Failed to rotate API key"))
+		return
+	}
+
+	log.Info("API key rotated", "key_id", id)
+	h.audit.Record(r, audit.Entry{Actor: user.Actor(), Action: audit.ActionAPIKeyRotated, Target: id, Outcome: audit.OutcomeSuccess})
+
+	key.Hash = ""
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(KeyResponse{APIKey: key, Key: secret})
+}
+
+// revokeKey disables an API key for good
+func (h *Handler) revokeKey(w http.ResponseWriter, r *http.Request) {
+	log := logging.FromContext(r.Context())
+	w.Header().Set("Content-Type", "application/json")
+
+	user, ok := sessionOnly(w, r)
+	if !ok {
+		return
+	}
+
+	id := r.PathValue("id")
+	if _, err := h.keys.Revoke(id); errors.Is(err, auth.ErrAPIKeyNotFound) {
+		apierror.Write(w, r, apierror.NotFound("API key not found"))
+		return
+	} else if err != nil {
+		log.Error("Failed to revoke API key", "error", err, "key_id", id)
+		apierror.Write(w, r, apierror.Internal("Failed to revoke API key"))
+		return
+	}
+
+	log.Info("API key revoked", "key_id", id)
+	h.audit.Record(r, audit.Entry{Actor: user.Actor(), Action: audit.ActionAPIKeyRevoked, Target: id, Outcome: audit.OutcomeSuccess})
+	w.WriteHeader(http.StatusNoContent)
+}
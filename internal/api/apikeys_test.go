@@ -0,0 +1,107 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kaustavdm/awwdio/internal/api/apikeys"
+	"github.com/kaustavdm/awwdio/internal/api/auth"
+	"github.com/kaustavdm/awwdio/internal/audit"
+)
+
+func TestAPIKeys(t *testing.T) {
+	a, handler := newTestServer(t)
+	admin, err := auth.GenerateJWT("admin@example.com", testSecret, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	send := func(method, path, token, actAs string, body io.Reader) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, path, body)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		if actAs != "" {
+			req.Header.Set(auth.ActAsHeader, actAs)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+	create := func(scopes string) apikeys.KeyResponse {
+		t.Helper()
+		body := `{"name": "scheduler", "scopes": ` + scopes + `, "expires_at": "` +
+			time.Now().Add(24*time.Hour).Format(time.RFC3339) + `"}`
+		rec := send("POST", "/api/admin/keys", admin, "", strings.NewReader(body))
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("creating key: status %d: %s", rec.Code, rec.Body)
+		}
+		var key apikeys.KeyResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &key); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(key.Key, key.Prefix+"_") || key.Hash != "" {
+			t.Fatalf("key %q with prefix %q and hash %q", key.Key, key.Prefix, key.Hash)
+		}
+		return key
+	}
+
+	calls := create(`["calls"]`)
+	actAs := create(`["calls", "act_as", "admin"]`)
+
+	tests := []struct {
+		name, method, path, token, actAs string
+		want                             int
+	}{
+		{"scoped", "GET", "/api/calls", calls.Key, "", http.StatusOK},
+		{"missing scope", "GET", "/api/user/me/calls", calls.Key, "", http.StatusForbidden},
+		{"not admin", "GET", "/api/admin/audit", calls.Key, "", http.StatusForbidden},
+		{"act as without scope", "GET", "/api/calls", calls.Key, "guest@example.com", http.StatusForbidden},
+		{"act as", "GET", "/api/calls", actAs.Key, "Guest@Example.com", http.StatusOK},
+		{"act as invalid user", "GET", "/api/calls", actAs.Key, "nobody", http.StatusBadRequest},
+		{"admin scope", "GET", "/api/admin/audit", actAs.Key, "", http.StatusOK},
+		{"list keys", "GET", "/api/admin/keys", actAs.Key, "", http.StatusOK},
+		{"rotate with key", "POST", "/api/admin/keys/" + calls.ID + "/rotate", actAs.Key, "", http.StatusForbidden},
+		{"wrong secret", "GET", "/api/calls", calls.Prefix + "_wrong", "", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		if rec := send(tt.method, tt.path, tt.token, tt.actAs, nil); rec.Code != tt.want {
+			t.Errorf("%s: status %d, want %d: %s", tt.name, rec.Code, tt.want, rec.Body)
+		}
+	}
+
+	// Audit entries name the key, and the user it acted for
+	video := create(`["video", "act_as"]`)
+	if rec := send("POST", "/api/video/token", video.Key, "guest@example.com", strings.NewReader(`{"room": "standup"}`)); rec.Code != http.StatusOK {
+		t.Fatalf("video token: status %d: %s", rec.Code, rec.Body)
+	}
+	entries := a.audit.Query(audit.Filter{Action: audit.ActionTokenIssued}, 1)
+	if len(entries) != 1 || entries[0].Actor != "service:"+video.ID || entries[0].Details["act_as"] != "guest@example.com" {
+		t.Errorf("audit entries %+v, want actor service:%s acting as guest@example.com", entries, video.ID)
+	}
+
+	// Rotating replaces the secret, revoking disables the key
+	rec := send("POST", "/api/admin/keys/"+calls.ID+"/rotate", admin, "", nil)
+	var rotated apikeys.KeyResponse
+	json.Unmarshal(rec.Body.Bytes(), &rotated)
+	if rec.Code != http.StatusOK || rotated.ID != calls.ID || rotated.Key == calls.Key {
+		t.Fatalf("rotating key: status %d: %s", rec.Code, rec.Body)
+	}
+	if rec := send("GET", "/api/calls", calls.Key, "", nil); rec.Code != http.StatusUnauthorized {
+		t.Errorf("old secret after rotation: status %d, want 401", rec.Code)
+	}
+	if rec := send("GET", "/api/calls", rotated.Key, "", nil); rec.Code != http.StatusOK {
+		t.Errorf("new secret after rotation: status %d, want 200", rec.Code)
+	}
+	if rec := send("DELETE", "/api/admin/keys/"+calls.ID, admin, "", nil); rec.Code != http.StatusNoContent {
+		t.Fatalf("revoking key: status %d: %s", rec.Code, rec.Body)
+	}
+	if rec := send("GET", "/api/calls", rotated.Key, "", nil); rec.Code != http.StatusUnauthorized {
+		t.Errorf("revoked key: status %d, want 401", rec.Code)
+	}
+}
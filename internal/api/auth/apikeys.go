@@ -0,0 +1,178 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/kaustavdm/awwdio/apitypes"
+	"github.com/kaustavdm/awwdio/internal/store"
+)
+
+// APIKeyPrefix starts every API key, so that keys are recognizable in
+// code, logs and secret scanners
+const APIKeyPrefix = "awk_"
+
+// MaxAPIKeyLifetime bounds how far in the future keys may expire
+const MaxAPIKeyLifetime = 365 * 24 * time.Hour
+
+// Scopes limit what an API key can do. User sessions are not scoped.
+const (
+	ScopeVideo = apitypes.ScopeVideo
+	ScopeCalls = apitypes.ScopeCalls
+	ScopeUser  = apitypes.ScopeUser
+	ScopeAdmin = apitypes.ScopeAdmin
+	ScopeActAs = apitypes.ScopeActAs
+)
+
+// Scopes lists every scope
+var Scopes = []string{ScopeVideo, ScopeCalls, ScopeUser, ScopeAdmin, ScopeActAs}
+
+// ActAsHeader names the user an API key with ScopeActAs acts for
+const ActAsHeader = apitypes.ActAsHeader
+
+// lastUsedInterval limits how often last use is written to the store
+const lastUsedInterval = time.Minute
+
+var (
+	ErrInvalidAPIKey  = errors.New("invalid API key")
+	ErrAPIKeyNotFound = errors.New("API key not found")
+)
+
+// APIKey is a service account credential. Only a hash of the secret is
+// kept; the key itself is shown once, when created or rotated.
+type APIKey = apitypes.APIKey
+
+// APIKeys manages API keys in the store
+type APIKeys struct {
+	keys *store.Collection[APIKey]
+}
+
+func NewAPIKeys(st *store.Store) (*APIKeys, error) {
+	keys, err := store.NewCollection[APIKey](st, "api_keys")
+	if err != nil {
+		return nil, err
+	}
+	return &APIKeys{keys: keys}, nil
+}
+
+// Create adds a key and returns it with the secret key, which is not
+// stored
+func (a *APIKeys) Create(name string, scopes []string, expiresAt time.Time, createdBy string) (APIKey, string, error) {
+	idBytes := make([]byte, 8)
+	if _, err := rand.Read(idBytes); err != nil {
+		return APIKey{}, "", err
+	}
+	id := hex.EncodeToString(idBytes)
+	secret, hash, err := newSecret()
+	if err != nil {
+		return APIKey{}, "", err
+	}
+
+	key := APIKey{
+		ID:        id,
+		Name:      name,
+		Prefix:    APIKeyPrefix + id,
+		Hash:      hash,
+		Scopes:    scopes,
+		CreatedBy: createdBy,
+		CreatedAt: time.Now().UTC(),
+		ExpiresAt: expiresAt.UTC(),
+	}
+	if err := a.keys.Put(id, key); err != nil {
+		return APIKey{}, "", err
+	}
+	return key, key.Prefix + "_" + secret, nil
+}
+
+// Rotate replaces the secret of an active key, keeping its ID, scopes and
+// expiry. The old secret stops working right away.
+func (a *APIKeys) Rotate(id string) (APIKey, string, error) {
+	secret, hash, err := newSecret()
+	if err != nil {
+		return APIKey{}, "", err
+	}
+	key, err := a.keys.Update(id, func(k APIKey, exists bool) (APIKey, error) {
+		if !exists || !k.Active(time.Now()) {
+			return k, ErrAPIKeyNotFound
+		}
+		k.Hash = hash
+		k.RotatedAt = time.Now().UTC()
+		return k, nil
+	})
+	if err != nil {
+		return APIKey{}, "", err
+	}
+	return key, key.Prefix + "_" + secret, nil
+}
+
+// Revoke disables a key for good. The record is kept for auditing.
+func (a *APIKeys) Revoke(id string) (APIKey, error) {
+	return a.keys.Update(id, func(k APIKey, exists bool) (APIKey, error) {
+		if !exists {
+			return k, ErrAPIKeyNotFound
+		}
+		if k.RevokedAt.IsZero() {
+			k.RevokedAt = time.Now().UTC()
+		}
+		return k, nil
+	})
+}
+
+// List returns all keys, without their hashes
+func (a *APIKeys) List() []APIKey {
+	keys := a.keys.List()
+	for i := range keys {
+		keys[i].Hash = ""
+	}
+	return keys
+}
+
+// Verify returns the active key token is, and records its use
+func (a *APIKeys) Verify(token string) (APIKey, error) {
+	id, secret, ok := strings.Cut(strings.TrimPrefix(token, APIKeyPrefix), "_")
+	if !ok || !strings.HasPrefix(token, APIKeyPrefix) {
+		return APIKey{}, ErrInvalidAPIKey
+	}
+	key, exists := a.keys.Get(id)
+	if !exists || !key.Active(time.Now()) {
+		return APIKey{}, ErrInvalidAPIKey
+	}
+	if subtle.ConstantTimeCompare([]byte(hashSecret(secret)), []byte(key.Hash)) != 1 {
+		return APIKey{}, ErrInvalidAPIKey
+	}
+
+	if now := time.Now(); now.Sub(key.LastUsedAt) > lastUsedInterval {
+		// Best effort: a failed write must not fail the request
+		a.keys.Update(id, func(k APIKey, exists bool) (APIKey, error) {
+			if !exists {
+				return k, ErrAPIKeyNotFound
+			}
+			k.LastUsedAt = now.UTC()
+			return k, nil
+		})
+	}
+	return key, nil
+}
+
+// newSecret returns a random secret and its hash
+func newSecret() (secret, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	secret = base64.RawURLEncoding.EncodeToString(b)
+	return secret, hashSecret(secret), nil
+}
+
+// hashSecret hashes a key secret. Secrets are 256 random bits, so a fast
+// unsalted hash is enough: there is nothing to brute-force.
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
@@ -2,82 +2,108 @@ package auth
 
 import (
 	"encoding/json"
-	"log/slog"
 	"net/http"
-	"time"
 
+	"github.com/kaustavdm/awwdio/apitypes"
 	"github.com/kaustavdm/awwdio/config"
-	"github.com/twilio/twilio-go"
+	"github.com/kaustavdm/awwdio/internal/api/apierror"
+	"github.com/kaustavdm/awwdio/internal/api/openapi"
+	"github.com/kaustavdm/awwdio/internal/api/request"
+	"github.com/kaustavdm/awwdio/internal/api/webhooks"
+	"github.com/kaustavdm/awwdio/internal/audit"
+	"github.com/kaustavdm/awwdio/internal/logging"
+	"github.com/kaustavdm/awwdio/internal/metrics"
+	"github.com/kaustavdm/awwdio/internal/normalize"
+	"github.com/kaustavdm/awwdio/internal/store"
+	"github.com/kaustavdm/awwdio/internal/twilioclient"
 	verify "github.com/twilio/twilio-go/rest/verify/v2"
 )
 
-type Handler struct {
-	config       *config.Config
-	twilioClient *twilio.RestClient
-}
-
-func NewHandler(cfg *config.Config) *Handler {
-	return &Handler{
-		config: cfg,
-		twilioClient: twilio.NewRestClientWithParams(twilio.ClientParams{
-			Username:   cfg.TwilioApiKey,
-			Password:   cfg.TwilioApiSecret,
-			AccountSid: cfg.TwilioAccountSID,
-		}),
-	}
-}
+var (
+	otpSends = metrics.NewCounter("awwdio_otp_sends_total",
+		"OTP send attempts, by channel and outcome (sent or error).",
+		"channel", "outcome")
+	otpChecks = metrics.NewCounter("awwdio_otp_checks_total",
+		"OTP checks, by channel and outcome (approved, rejected or error).",
+		"channel", "outcome")
+)
 
-func (h *Handler) Register(mux *http.ServeMux) {
-	mux.HandleFunc("POST /send-otp", h.sendOTPHandler)
-	mux.HandleFunc("POST /verify-otp", h.verifyOTPHandler)
-}
+type Handler struct {
+	config *config.Provider
 
-type SendOTPRequest struct {
-	Channel string `json:"channel"` // "email" or "sms"
-	To      string `json:"to"`      // Email address or phone number
+	users    *store.Collection[User]
+	webhooks *webhooks.Handler
+	audit    *audit.Log
 }
 
-type SendOTPResponse struct {
-	Success bool `json:"success"`
+func NewHandler(cfg *config.Provider, st *store.Store, hooks *webhooks.Handler, auditLog *audit.Log) (*Handler, error) {
+	users, err := NewUserCollection(st)
+	if err != nil {
+		return nil, err
+	}
+	return &Handler{
+		config:   cfg,
+		users:    users,
+		webhooks: hooks,
+		audit:    auditLog,
+	}, nil
 }
 
-type VerifyOTPRequest struct {
-	Channel string `json:"channel"` // "email" or "sms"
-	To      string `json:"to"`      // Email address or phone number
-	OTP     string `json:"otp"`
+// Users returns the users collection, shared with the auth middleware
+func (h *Handler) Users() *store.Collection[User] {
+	return h.users
 }
 
-type VerifyOTPResponse struct {
-	Success bool   `json:"success"`
-	Token   string `json:"token,omitempty"`
+func (h *Handler) Register(mux *openapi.Mux) {
+	mux.HandleFunc("POST /send-otp", h.sendOTPHandler, openapi.Operation{
+		ID:       "sendOTP",
+		Summary:  "Send a one-time password by email or SMS",
+		Request:  SendOTPRequest{},
+		Response: SendOTPResponse{},
+	})
+	mux.HandleFunc("POST /verify-otp", h.verifyOTPHandler, openapi.Operation{
+		ID:       "verifyOTP",
+		Summary:  "Check a one-time password and start a session",
+		Request:  VerifyOTPRequest{},
+		Response: VerifyOTPResponse{},
+	})
+	mux.HandleFunc("POST /logout", h.logoutHandler, openapi.Operation{
+		ID:      "logout",
+		Summary: "End a cookie session",
+		Status:  http.StatusNoContent,
+	})
+	mux.HandleFunc("GET /csrf", h.csrfHandler, openapi.Operation{
+		ID:       "getCSRFToken",
+		Summary:  "Issue a CSRF token for cookie sessions",
+		Response: CSRFResponse{},
+	})
 }
 
-type ErrorResponse struct {
-	Error string `json:"error"`
-}
+type (
+	SendOTPRequest    = apitypes.SendOTPRequest
+	SendOTPResponse   = apitypes.SendOTPResponse
+	VerifyOTPRequest  = apitypes.VerifyOTPRequest
+	VerifyOTPResponse = apitypes.VerifyOTPResponse
+)
 
 // sendOTPHandler sends an OTP via email or SMS using Twilio Verify
 func (h *Handler) sendOTPHandler(w http.ResponseWriter, r *http.Request) {
+	log := logging.FromContext(r.Context())
+	cfg := h.config.Get()
 	var req SendOTPRequest
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		slog.Error("Failed to decode request", "error", err)
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(ErrorResponse{Error: "Invalid request body"})
+	if err := request.Decode(w, r, &req); err != nil {
+		log.Debug("Invalid request body", "error", err)
+		apierror.Write(w, r, err)
 		return
 	}
-
-	// Validate channel
-	if req.Channel != "email" && req.Channel != "sms" {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(ErrorResponse{Error: "Channel must be 'email' or 'sms'"})
-		return
+	// One identity per person, however they type their contact. Whatever
+	// cannot be normalized is left for validation to reject.
+	if to, err := normalize.Contact(req.Channel, req.To, cfg.DefaultCountryCode); err == nil {
+		req.To = to
 	}
-
-	// Validate contact information
-	if req.To == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(ErrorResponse{Error: "Contact information (to) is required"})
+	if err := request.Validate(&req); err != nil {
+		apierror.Write(w, r, err)
 		return
 	}
 
@@ -86,15 +112,16 @@ func (h *Handler) sendOTPHandler(w http.ResponseWriter, r *http.Request) {
 	params.SetTo(req.To)
 	params.SetChannel(req.Channel)
 
-	resp, err := h.twilioClient.VerifyV2.CreateVerification(h.config.TwilioVerifyServiceSID, params)
+	resp, err := twilioclient.New(r.Context(), cfg).VerifyV2.CreateVerification(cfg.TwilioVerifyServiceSID, params)
 	if err != nil {
-		slog.Error("Failed to send OTP", "error", err, "channel", req.Channel)
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(ErrorResponse{Error: "Failed to send OTP"})
+		log.Error("Failed to send OTP", "error", err, "channel", req.Channel)
+		otpSends.Inc(req.Channel, "error")
+		apierror.Write(w, r, apierror.Upstream("Failed to send OTP"))
 		return
 	}
 
-	slog.Info("OTP sent", "channel", req.Channel, "to", req.To, "status", *resp.Status)
+	log.Info("OTP sent", "channel", req.Channel, "to", req.To, "status", *resp.Status)
+	otpSends.Inc(req.Channel, "sent")
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(SendOTPResponse{Success: true})
@@ -102,26 +129,22 @@ func (h *Handler) sendOTPHandler(w http.ResponseWriter, r *http.Request) {
 
 // verifyOTPHandler verifies the OTP code via email or SMS
 func (h *Handler) verifyOTPHandler(w http.ResponseWriter, r *http.Request) {
+	log := logging.FromContext(r.Context())
+	cfg := h.config.Get()
 	var req VerifyOTPRequest
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		slog.Error("Failed to decode request", "error", err)
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(ErrorResponse{Error: "Invalid request body"})
+	if err := request.Decode(w, r, &req); err != nil {
+		log.Debug("Invalid request body", "error", err)
+		apierror.Write(w, r, err)
 		return
 	}
-
-	// Validate channel
-	if req.Channel != "email" && req.Channel != "sms" {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(ErrorResponse{Error: "Channel must be 'email' or 'sms'"})
-		return
+	// One identity per person, however they type their contact. Whatever
+	// cannot be normalized is left for validation to reject.
+	if to, err := normalize.Contact(req.Channel, req.To, cfg.DefaultCountryCode); err == nil {
+		req.To = to
 	}
-
-	// Validate required fields
-	if req.To == "" || req.OTP == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(ErrorResponse{Error: "Contact information and OTP are required"})
+	if err := request.Validate(&req); err != nil {
+		apierror.Write(w, r, err)
 		return
 	}
 
@@ -130,35 +153,64 @@ func (h *Handler) verifyOTPHandler(w http.ResponseWriter, r *http.Request) {
 	params.SetTo(req.To)
 	params.SetCode(req.OTP)
 
-	resp, err := h.twilioClient.VerifyV2.CreateVerificationCheck(h.config.TwilioVerifyServiceSID, params)
+	resp, err := twilioclient.New(r.Context(), cfg).VerifyV2.CreateVerificationCheck(cfg.TwilioVerifyServiceSID, params)
 	if err != nil {
-		slog.Error("Failed to verify OTP", "error", err, "channel", req.Channel)
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(ErrorResponse{Error: "Failed to verify OTP"})
+		log.Error("Failed to verify OTP", "error", err, "channel", req.Channel)
+		otpChecks.Inc(req.Channel, "error")
+		h.audit.Record(r, audit.Entry{Actor: req.To, Action: audit.ActionLogin, Outcome: audit.OutcomeError,
+			Details: map[string]string{"channel": req.Channel}})
+		apierror.Write(w, r, apierror.Upstream("Failed to verify OTP"))
 		return
 	}
 
 	if resp.Status == nil || *resp.Status != "approved" {
-		slog.Warn("OTP verification failed", "channel", req.Channel, "to", req.To, "status", resp.Status)
-		w.WriteHeader(http.StatusUnauthorized)
-		json.NewEncoder(w).Encode(ErrorResponse{Error: "Invalid OTP"})
+		log.Warn("OTP verification failed", "channel", req.Channel, "to", req.To, "status", resp.Status)
+		otpChecks.Inc(req.Channel, "rejected")
+		h.audit.Record(r, audit.Entry{Actor: req.To, Action: audit.ActionLogin, Outcome: audit.OutcomeFailure,
+			Details: map[string]string{"channel": req.Channel}})
+		apierror.Write(w, r, apierror.New(http.StatusUnauthorized, apierror.CodeInvalidOTP, "Invalid OTP"))
 		return
 	}
 
-	slog.Info("OTP verified", "channel", req.Channel, "to", req.To)
+	log.Info("OTP verified", "channel", req.Channel, "to", req.To)
+	otpChecks.Inc(req.Channel, "approved")
 
-	// Generate JWT token with 24 hour expiry
-	sessionToken, err := GenerateJWT(req.To, h.config.JWTSecret, 24*time.Hour)
+	if IsDisabled(h.users, req.To) {
+		log.Warn("Disabled user tried to sign in", "to", req.To)
+		h.audit.Record(r, audit.Entry{Actor: req.To, Action: audit.ActionLogin, Outcome: audit.OutcomeDenied,
+			Details: map[string]string{"channel": req.Channel}})
+		apierror.Write(w, r, apierror.Forbidden("Account disabled"))
+		return
+	}
+
+	// Record the login; a failure here should not lock the user out
+	if _, created, err := RecordLogin(h.users, req.To); err != nil {
+		log.Error("Failed to record user login", "error", err)
+	} else if created {
+		log.Info("User created", "subject", req.To)
+		h.webhooks.Emit(webhooks.EventUserCreated, map[string]any{"subject": req.To})
+	}
+
+	sessionToken, err := GenerateJWT(req.To, cfg.JWTSecret, SessionTTL)
 	if err != nil {
-		slog.Error("Failed to generate JWT", "error", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(ErrorResponse{Error: "Failed to generate session token"})
+		log.Error("Failed to generate JWT", "error", err)
+		apierror.Write(w, r, apierror.Internal("Failed to generate session token"))
 		return
 	}
 
+	h.audit.Record(r, audit.Entry{Actor: req.To, Action: audit.ActionLogin, Outcome: audit.OutcomeSuccess,
+		Details: map[string]string{"channel": req.Channel}})
+
+	result := VerifyOTPResponse{Success: true}
+	if cfg.AuthMode == config.AuthModeCookie {
+		// Keep the token away from scripts
+		result.CSRFToken = NewCSRFToken()
+		setSessionCookie(w, cfg, sessionToken)
+		SetCSRFCookie(w, cfg, result.CSRFToken)
+	} else {
+		result.Token = sessionToken
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(VerifyOTPResponse{
-		Success: true,
-		Token:   sessionToken,
-	})
+	json.NewEncoder(w).Encode(result)
 }
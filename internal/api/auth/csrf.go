@@ -0,0 +1,64 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+
+	"github.com/kaustavdm/awwdio/config"
+)
+
+// Cookie authentication uses double-submit CSRF tokens: the token is set in
+// a cookie scripts can read, and state-changing requests must echo it in
+// the X-CSRF-Token header. Other sites can make the browser send the cookie
+// but cannot read it.
+const (
+	// SessionCookie carries the JWT when cookie authentication is used
+	SessionCookie = "awwdio_session"
+	// CSRFCookie carries the CSRF token
+	CSRFCookie = "awwdio_csrf"
+	// CSRFHeader must repeat the CSRF cookie on state-changing requests
+	CSRFHeader = "X-CSRF-Token"
+)
+
+type CSRFResponse struct {
+	CSRFToken string `json:"csrf_token"`
+}
+
+// NewCSRFToken returns a random CSRF token
+func NewCSRFToken() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// SetCSRFCookie sets the CSRF cookie to token, with the same attributes as
+// the session cookie. It lives as long as the browser session; a missing
+// cookie is simply issued again.
+func SetCSRFCookie(w http.ResponseWriter, cfg *config.Config, token string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     CSRFCookie,
+		Value:    token,
+		Path:     "/",
+		Secure:   cfg.SessionCookieSecure,
+		SameSite: cookieSameSite(cfg),
+		// Not HttpOnly: the frontend reads it to fill in the header
+	})
+}
+
+// csrfHandler returns the current CSRF token, issuing one if needed. Front
+// ends on other origins cannot read the cookie, so they get it from here.
+func (h *Handler) csrfHandler(w http.ResponseWriter, r *http.Request) {
+	token := ""
+	if c, err := r.Cookie(CSRFCookie); err == nil && c.Value != "" {
+		token = c.Value
+	} else {
+		token = NewCSRFToken()
+		SetCSRFCookie(w, h.config.Get(), token)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(CSRFResponse{CSRFToken: token})
+}
@@ -0,0 +1,78 @@
+package auth
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/kaustavdm/awwdio/config"
+)
+
+// SessionTTL is how long a login lasts
+const SessionTTL = 24 * time.Hour
+
+// cookieSameSite maps SESSION_COOKIE_SAMESITE to its http value
+func cookieSameSite(cfg *config.Config) http.SameSite {
+	switch cfg.SessionCookieSameSite {
+	case "strict":
+		return http.SameSiteStrictMode
+	case "none":
+		return http.SameSiteNoneMode
+	default:
+		return http.SameSiteLaxMode
+	}
+}
+
+// setSessionCookie stores the session JWT in an HttpOnly cookie, out of
+// reach of scripts
+func setSessionCookie(w http.ResponseWriter, cfg *config.Config, token string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     SessionCookie,
+		Value:    token,
+		Path:     "/api/",
+		MaxAge:   int(SessionTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   cfg.SessionCookieSecure,
+		SameSite: cookieSameSite(cfg),
+	})
+}
+
+// clearSessionCookies removes the session and CSRF cookies
+func clearSessionCookies(w http.ResponseWriter, cfg *config.Config) {
+	for _, c := range []*http.Cookie{
+		{Name: SessionCookie, Path: "/api/", HttpOnly: true},
+		{Name: CSRFCookie, Path: "/"},
+	} {
+		c.MaxAge = -1
+		c.Secure = cfg.SessionCookieSecure
+		c.SameSite = cookieSameSite(cfg)
+		http.SetCookie(w, c)
+	}
+}
+
+// SessionToken returns the JWT a request authenticates with: the bearer
+// token from the Authorization header or, in cookie mode, the session
+// cookie. The header wins when both are present. ok is false when the
+// Authorization header is present but malformed.
+func SessionToken(r *http.Request, cfg *config.Config) (token string, ok bool) {
+	if header := r.Header.Get("Authorization"); header != "" {
+		scheme, token, found := strings.Cut(header, " ")
+		if !found || !strings.EqualFold(scheme, "bearer") {
+			return "", false
+		}
+		return token, true
+	}
+	if cfg.AuthMode == config.AuthModeCookie {
+		if c, err := r.Cookie(SessionCookie); err == nil {
+			return c.Value, true
+		}
+	}
+	return "", true
+}
+
+// logoutHandler ends a cookie session. Bearer tokens are simply discarded
+// by the client.
+func (h *Handler) logoutHandler(w http.ResponseWriter, r *http.Request) {
+	clearSessionCookies(w, h.config.Get())
+	w.WriteHeader(http.StatusNoContent)
+}
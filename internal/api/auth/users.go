@@ -0,0 +1,68 @@
+package auth
+
+import (
+	"errors"
+	"time"
+
+	"github.com/kaustavdm/awwdio/internal/store"
+)
+
+// User is someone who has signed in at least once
+type User struct {
+	Subject     string    `json:"subject"` // Email address or phone number
+	CreatedAt   time.Time `json:"created_at"`
+	LastLoginAt time.Time `json:"last_login_at"`
+	DisabledAt  time.Time `json:"disabled_at,omitzero"` // Zero unless disabled
+}
+
+// Disabled reports whether the user may not sign in or use their sessions
+func (u User) Disabled() bool {
+	return !u.DisabledAt.IsZero()
+}
+
+var ErrUserNotFound = errors.New("user not found")
+
+// IsDisabled reports whether the user with subject is disabled. Unknown
+// subjects, such as guests who never signed in, are not.
+func IsDisabled(users *store.Collection[User], subject string) bool {
+	u, ok := users.Get(subject)
+	return ok && u.Disabled()
+}
+
+// SetDisabled disables or re-enables the user with subject. Disabled users
+// cannot sign in, and their sessions are rejected.
+func SetDisabled(users *store.Collection[User], subject string, disabled bool) (User, error) {
+	return users.Update(subject, func(u User, exists bool) (User, error) {
+		if !exists {
+			return u, ErrUserNotFound
+		}
+		switch {
+		case disabled && !u.Disabled():
+			u.DisabledAt = time.Now().UTC()
+		case !disabled:
+			u.DisabledAt = time.Time{}
+		}
+		return u, nil
+	})
+}
+
+// NewUserCollection opens the users collection
+func NewUserCollection(st *store.Store) (*store.Collection[User], error) {
+	return store.NewCollection[User](st, "users")
+}
+
+// RecordLogin creates or updates the user for subject and reports whether
+// the user is new
+func RecordLogin(users *store.Collection[User], subject string) (User, bool, error) {
+	created := false
+	u, err := users.Update(subject, func(u User, exists bool) (User, error) {
+		now := time.Now().UTC()
+		if !exists {
+			created = true
+			u = User{Subject: subject, CreatedAt: now}
+		}
+		u.LastLoginAt = now
+		return u, nil
+	})
+	return u, created, err
+}
@@ -0,0 +1,23 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// Unsigned callbacks could fake participants and unlock recordings
+func TestUnsignedCallbacksRejected(t *testing.T) {
+	_, handler := newTestServer(t)
+	for _, path := range []string{"/api/video/callbacks/room-status", "/api/video/callbacks/composition-status"} {
+		body := "StatusCallbackEvent=participant-connected&RoomSid=RM1&RoomName=call-1&ParticipantIdentity=eve@example.com"
+		req := httptest.NewRequest("POST", path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusForbidden {
+			t.Errorf("%s: status %d, want 403", path, rec.Code)
+		}
+	}
+}
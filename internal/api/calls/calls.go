@@ -0,0 +1,370 @@
+package calls
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/kaustavdm/awwdio/apitypes"
+	"github.com/kaustavdm/awwdio/config"
+	"github.com/kaustavdm/awwdio/internal/api/apierror"
+	"github.com/kaustavdm/awwdio/internal/api/middleware"
+	"github.com/kaustavdm/awwdio/internal/api/openapi"
+	"github.com/kaustavdm/awwdio/internal/api/request"
+	"github.com/kaustavdm/awwdio/internal/logging"
+	"github.com/kaustavdm/awwdio/internal/normalize"
+	"github.com/kaustavdm/awwdio/internal/notify"
+	"github.com/kaustavdm/awwdio/internal/store"
+)
+
+const (
+	// roomPrefix marks rooms that belong to scheduled calls
+	roomPrefix = "call-"
+	// joinEarly is how long before the start time participants may join
+	joinEarly = 10 * time.Minute
+	// maxDuration is the longest call that can be scheduled, in minutes
+	maxDuration = 8 * 60
+	// maxInvitees is the largest number of invitees per call
+	maxInvitees = 50
+)
+
+// Call is a scheduled call
+type Call struct {
+	ID        string    `json:"id"`
+	Title     string    `json:"title"`
+	Organizer string    `json:"organizer"`
+	Start     time.Time `json:"start"`
+	Duration  int       `json:"duration"` // minutes
+	Room      string    `json:"room"`
+	Invitees  []Invitee `json:"invitees"`
+	CreatedAt time.Time `json:"created_at"`
+	// RemindedAt is when reminders were queued, zero until then
+	RemindedAt time.Time `json:"reminded_at,omitzero"`
+}
+
+// Invitee is a contact invited to a call. Token grants access to the
+// invitee's calendar invitation without logging in.
+type Invitee struct {
+	Contact string `json:"contact"`
+	Token   string `json:"token"`
+}
+
+// End returns the time the call's join window closes
+func (c Call) End() time.Time {
+	return c.Start.Add(time.Duration(c.Duration) * time.Minute)
+}
+
+// IsMember reports whether identity organized or was invited to the call
+func (c Call) IsMember(identity string) bool {
+	if c.Organizer == identity {
+		return true
+	}
+	return slices.ContainsFunc(c.Invitees, func(i Invitee) bool { return i.Contact == identity })
+}
+
+type Handler struct {
+	config *config.Provider
+
+	calls *store.Collection[Call]
+	queue *notify.Queue
+}
+
+func NewHandler(cfg *config.Provider, st *store.Store, queue *notify.Queue) (*Handler, error) {
+	calls, err := store.NewCollection[Call](st, "calls")
+	if err != nil {
+		return nil, err
+	}
+	return &Handler{
+		config: cfg,
+		calls:  calls,
+		queue:  queue,
+	}, nil
+}
+
+// Register registers the call routes. Unlike other modules the collection
+// itself lives at /calls rather than /calls/, so patterns carry the full
+// path and the mux is mounted without StripPrefix.
+func (h *Handler) Register(mux *openapi.Mux) {
+	mux.HandleFunc("POST /calls", h.createCall, openapi.Operation{
+		ID:       "createCall",
+		Summary:  "Schedule a call and invite participants",
+		Request:  CreateCallRequest{},
+		Response: CallResponse{},
+		Status:   http.StatusCreated,
+	})
+	mux.HandleFunc("GET /calls", h.listCalls, openapi.Operation{
+		ID:       "listCalls",
+		Summary:  "List upcoming and in-progress calls of the user",
+		Response: ListCallsResponse{},
+	})
+	mux.HandleFunc("GET /calls/{id}", h.getCall, openapi.Operation{
+		ID:       "getCall",
+		Summary:  "Get a call the user organized or was invited to",
+		Response: CallResponse{},
+	})
+}
+
+// RegisterPublic registers routes that need no authentication. Calendar
+// clients fetch invitations without a session, so these are guarded by the
+// unguessable per-invitee token instead.
+func (h *Handler) RegisterPublic(mux *openapi.Mux) {
+	mux.HandleFunc("GET /calls/invites/{file}", h.invitationHandler, openapi.Operation{
+		ID:           "getInvitation",
+		Summary:      "Download a calendar invitation, named <token>.ics",
+		ResponseType: "text/calendar",
+	})
+}
+
+type (
+	CreateCallRequest = apitypes.CreateCallRequest
+	CallResponse      = apitypes.CallResponse
+	InviteeResponse   = apitypes.InviteeResponse
+	ListCallsResponse = apitypes.ListCallsResponse
+)
+
+// createCall schedules a call and generates an invitation per invitee
+func (h *Handler) createCall(w http.ResponseWriter, r *http.Request) {
+	log := logging.FromContext(r.Context())
+	w.Header().Set("Content-Type", "application/json")
+
+	user := middleware.GetUser(r)
+	if user == nil {
+		apierror.Write(w, r, apierror.Unauthorized("Authentication required"))
+		return
+	}
+
+	var req CreateCallRequest
+	if err := request.Decode(w, r, &req); err != nil {
+		log.Debug("Invalid request body", "error", err)
+		apierror.Write(w, r, err)
+		return
+	}
+
+	req.Title = strings.TrimSpace(req.Title)
+	if req.Title == "" || len(req.Title) > 200 {
+		apierror.Write(w, r, apierror.BadRequest("Title is required and must be at most 200 characters"))
+		return
+	}
+	if strings.ContainsFunc(req.Title, unicode.IsControl) {
+		apierror.Write(w, r, apierror.BadRequest("Title must not contain control characters"))
+		return
+	}
+	if req.Start.IsZero() || req.Start.Before(time.Now()) {
+		apierror.Write(w, r, apierror.BadRequest("Start time must be in the future"))
+		return
+	}
+	if req.Duration <= 0 || req.Duration > maxDuration {
+		apierror.Write(w, r, apierror.BadRequest("Duration must be between 1 and 480 minutes"))
+		return
+	}
+	if len(req.Invitees) == 0 || len(req.Invitees) > maxInvitees {
+		apierror.Write(w, r, apierror.BadRequest("Between 1 and 50 invitees are required"))
+		return
+	}
+
+	id, err := randomID()
+	if err != nil {
+		log.Error("Failed to generate call ID", "error", err)
+		apierror.Write(w, r, apierror.Internal("Failed to schedule call"))
+		return
+	}
+
+	call := Call{
+		ID:        id,
+		Title:     req.Title,
+		Organizer: user.Subject,
+		Start:     req.Start.UTC(),
+		Duration:  req.Duration,
+		Room:      roomPrefix + id,
+		CreatedAt: time.Now().UTC(),
+	}
+	// Invitees are matched against the normalized subjects users log in with
+	defaultCountry := h.config.Get().DefaultCountryCode
+	for i, contact := range req.Invitees {
+		contact, err := normalize.Subject(contact, defaultCountry)
+		if err != nil {
+			apierror.Write(w, r, apierror.Validation("Invalid invitee", apierror.FieldError{
+				Field:   fmt.Sprintf("invitees[%d]", i),
+				Message: "must be an email address or phone number",
+			}))
+			return
+		}
+		if contact == user.Subject || call.IsMember(contact) {
+			continue
+		}
+		token, err := randomID()
+		if err != nil {
+			log.Error("Failed to generate invitation token", "error", err)
+			apierror.Write(w, r, apierror.Internal("Failed to schedule call"))
+			return
+		}
+		call.Invitees = append(call.Invitees, Invitee{Contact: contact, Token: token})
+	}
+
+	if err := h.calls.Put(call.ID, call); err != nil {
+		log.Error("Failed to store call", "error", err)
+		apierror.Write(w, r, apierror.Internal("Failed to schedule call"))
+		return
+	}
+
+	log.Info("Call scheduled", "call_id", call.ID, "organizer", call.Organizer, "start", call.Start, "invitees", len(call.Invitees))
+
+	h.sendInvitations(call)
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(h.callResponse(call, user.Subject))
+}
+
+// listCalls returns upcoming and in-progress calls the user organized or was invited to
+func (h *Handler) listCalls(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	user := middleware.GetUser(r)
+	if user == nil {
+		apierror.Write(w, r, apierror.Unauthorized("Authentication required"))
+		return
+	}
+
+	now := time.Now()
+	resp := ListCallsResponse{Calls: []CallResponse{}}
+	for _, call := range h.calls.List() {
+		if call.IsMember(user.Subject) && call.End().After(now) {
+			resp.Calls = append(resp.Calls, h.callResponse(call, user.Subject))
+		}
+	}
+	sort.Slice(resp.Calls, func(i, j int) bool { return resp.Calls[i].Start.Before(resp.Calls[j].Start) })
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// getCall returns a single call
+func (h *Handler) getCall(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	user := middleware.GetUser(r)
+	if user == nil {
+		apierror.Write(w, r, apierror.Unauthorized("Authentication required"))
+		return
+	}
+
+	call, ok := h.calls.Get(r.PathValue("id"))
+	if !ok || !call.IsMember(user.Subject) {
+		apierror.Write(w, r, apierror.NotFound("Call not found"))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(h.callResponse(call, user.Subject))
+}
+
+// invitationHandler serves an invitee's calendar invitation as <token>.ics
+func (h *Handler) invitationHandler(w http.ResponseWriter, r *http.Request) {
+	token, ok := strings.CutSuffix(r.PathValue("file"), ".ics")
+	if !ok || token == "" {
+		apierror.Write(w, r, apierror.NotFound("Invitation not found"))
+		return
+	}
+
+	call, invitee, found := h.findInvitation(token)
+	if !found {
+		apierror.Write(w, r, apierror.NotFound("Invitation not found"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8; method=REQUEST")
+	w.Header().Set("Content-Disposition", `attachment; filename="invite.ics"`)
+	w.Write(call.ICS(invitee.Contact, h.JoinURL(call)))
+}
+
+// findInvitation looks up the call and invitee an invitation token belongs
+// to. Tokens are secrets, so they are compared in constant time.
+func (h *Handler) findInvitation(token string) (Call, Invitee, bool) {
+	for _, call := range h.calls.List() {
+		for _, invitee := range call.Invitees {
+			if subtle.ConstantTimeCompare([]byte(invitee.Token), []byte(token)) == 1 {
+				return call, invitee, true
+			}
+		}
+	}
+	return Call{}, Invitee{}, false
+}
+
+// ErrNotInvited and ErrOutsideWindow are returned by CheckJoin
+var (
+	ErrNotInvited    = errors.New("you are not invited to this call")
+	ErrOutsideWindow = errors.New("this call is not open for joining right now")
+)
+
+// CheckJoin decides whether identity may join room. Rooms of scheduled calls
+// are only open to the organizer and invitees, from shortly before the start
+// time until the scheduled end. Other rooms are not restricted.
+func (h *Handler) CheckJoin(identity, room string) error {
+	id, ok := strings.CutPrefix(room, roomPrefix)
+	if !ok {
+		return nil
+	}
+	call, ok := h.calls.Get(id)
+	if !ok || !call.IsMember(identity) {
+		return ErrNotInvited
+	}
+	now := time.Now()
+	if now.Before(call.Start.Add(-joinEarly)) || now.After(call.End()) {
+		return ErrOutsideWindow
+	}
+	return nil
+}
+
+// JoinURL returns the frontend URL for joining a call. It is relative when
+// PUBLIC_URL is not configured.
+func (h *Handler) JoinURL(call Call) string {
+	return h.config.Get().PublicURL + "/call/" + call.Room + "/setup"
+}
+
+// InvitationURL returns the calendar invitation URL for an invitee. It is
+// relative when PUBLIC_URL is not configured.
+func (h *Handler) InvitationURL(invitee Invitee) string {
+	return h.config.Get().PublicURL + "/api/calls/invites/" + invitee.Token + ".ics"
+}
+
+// callResponse builds the API view of a call. Invitation URLs are only
+// included for the organizer, and for an invitee their own.
+func (h *Handler) callResponse(call Call, identity string) CallResponse {
+	resp := CallResponse{
+		ID:        call.ID,
+		Title:     call.Title,
+		Organizer: call.Organizer,
+		Start:     call.Start,
+		End:       call.End(),
+		Duration:  call.Duration,
+		Room:      call.Room,
+		JoinURL:   h.JoinURL(call),
+		Invitees:  make([]InviteeResponse, 0, len(call.Invitees)),
+	}
+	for _, invitee := range call.Invitees {
+		ir := InviteeResponse{Contact: invitee.Contact}
+		if identity == call.Organizer || identity == invitee.Contact {
+			ir.ICSURL = h.InvitationURL(invitee)
+		}
+		resp.Invitees = append(resp.Invitees, ir)
+	}
+	return resp
+}
+
+// randomID returns 16 random bytes, hex encoded
+func randomID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
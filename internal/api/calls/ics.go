@@ -0,0 +1,93 @@
+package calls
+
+import (
+	"strings"
+	"unicode"
+)
+
+// icsTimeFormat is the UTC date-time format used by iCalendar (RFC 5545)
+const icsTimeFormat = "20060102T150405Z"
+
+// ICS renders an iCalendar invitation for the call addressed to invitee.
+// joinURL is where the invitee joins the call.
+func (c Call) ICS(invitee, joinURL string) []byte {
+	var b strings.Builder
+	line := func(s string) {
+		b.WriteString(foldICSLine(s))
+		b.WriteString("\r\n")
+	}
+
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:-//Awwdio//Scheduled Calls//EN")
+	line("CALSCALE:GREGORIAN")
+	line("METHOD:REQUEST")
+	line("BEGIN:VEVENT")
+	line("UID:" + c.ID + "@awwdio")
+	line("DTSTAMP:" + c.CreatedAt.UTC().Format(icsTimeFormat))
+	line("DTSTART:" + c.Start.UTC().Format(icsTimeFormat))
+	line("DTEND:" + c.End().UTC().Format(icsTimeFormat))
+	line("SUMMARY:" + escapeICSText(c.Title))
+	// Relative join URLs are useless in a calendar, so they are left out
+	if strings.HasPrefix(joinURL, "http") {
+		line("DESCRIPTION:" + escapeICSText("Join the call: "+joinURL))
+		line("URL:" + joinURL)
+	}
+	line("ORGANIZER:" + icsAddress(c.Organizer))
+	line("ATTENDEE;ROLE=REQ-PARTICIPANT;RSVP=FALSE:" + icsAddress(invitee))
+	line("END:VEVENT")
+	line("END:VCALENDAR")
+
+	return []byte(b.String())
+}
+
+// icsAddress turns a contact into a calendar user address. Phone numbers
+// have no mailto form, so they are expressed as tel: URIs.
+func icsAddress(contact string) string {
+	if strings.Contains(contact, "@") {
+		return "mailto:" + contact
+	}
+	return "tel:" + contact
+}
+
+// escapeICSText escapes a TEXT property value. Line breaks become \n and
+// other control characters are dropped, so that values cannot start new
+// properties.
+func escapeICSText(s string) string {
+	s = strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+		"\r", `\n`,
+	).Replace(s)
+	return strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+// foldICSLine splits content lines longer than 75 octets, continuing each
+// fold with a single space, without breaking multi-byte characters.
+func foldICSLine(s string) string {
+	const limit = 75
+	if len(s) <= limit {
+		return s
+	}
+
+	var b strings.Builder
+	width := 0
+	for _, r := range s {
+		size := len(string(r))
+		if width+size > limit {
+			b.WriteString("\r\n ")
+			width = 1
+		}
+		b.WriteRune(r)
+		width += size
+	}
+	return b.String()
+}
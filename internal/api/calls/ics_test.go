@@ -0,0 +1,97 @@
+package calls
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestFoldICSLine(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+	}{
+		{"short", "SUMMARY:Standup"},
+		{"exactly 75 octets", "SUMMARY:" + strings.Repeat("a", 67)},
+		{"ascii", "SUMMARY:" + strings.Repeat("a", 200)},
+		{"two-byte runes", "SUMMARY:" + strings.Repeat("é", 100)},
+		{"four-byte runes", "SUMMARY:" + strings.Repeat("🎉", 50)},
+		{"mixed", "SUMMARY:a" + strings.Repeat("日本語", 30)},
+	}
+	for _, tt := range tests {
+		folded := foldICSLine(tt.line)
+		if len(tt.line) <= 75 && folded != tt.line {
+			t.Errorf("%s: folded a line of %d octets", tt.name, len(tt.line))
+		}
+		for i, part := range strings.Split(folded, "\r\n") {
+			if len(part) > 75 {
+				t.Errorf("%s: part %d is %d octets", tt.name, i, len(part))
+			}
+			if !utf8.ValidString(part) {
+				t.Errorf("%s: part %d splits a character: %q", tt.name, i, part)
+			}
+			if i > 0 && !strings.HasPrefix(part, " ") {
+				t.Errorf("%s: continuation %d does not start with a space", tt.name, i)
+			}
+		}
+		if unfolded := strings.ReplaceAll(folded, "\r\n ", ""); unfolded != tt.line {
+			t.Errorf("%s: unfolds to %q", tt.name, unfolded)
+		}
+	}
+}
+
+func TestEscapeICSText(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"Standup", "Standup"},
+		{`a\b;c,d`, `a\\b\;c\,d`},
+		{"one\r\ntwo\nthree", `one\ntwo\nthree`},
+		{"x\rATTENDEE:mailto:eve@example.com", `x\nATTENDEE:mailto:eve@example.com`},
+		{"tab\there\x00\x1b\u0085", "tabhere"},
+	}
+	for _, tt := range tests {
+		if got := escapeICSText(tt.in); got != tt.want {
+			t.Errorf("escapeICSText(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestICS(t *testing.T) {
+	start := time.Date(2025, 3, 1, 15, 0, 0, 0, time.UTC)
+	call := Call{
+		ID:        "abc",
+		Title:     "Plan; review, ship\rATTENDEE:mailto:eve@example.com",
+		Organizer: "alice@example.com",
+		Start:     start,
+		Duration:  30,
+		CreatedAt: start.Add(-time.Hour),
+	}
+	ics := string(call.ICS("+14155550100", "https://awwdio.example.com/call/x/setup"))
+
+	for _, want := range []string{
+		"BEGIN:VCALENDAR\r\n",
+		"UID:abc@awwdio\r\n",
+		"DTSTART:20250301T150000Z\r\n",
+		"DTEND:20250301T153000Z\r\n",
+		`SUMMARY:Plan\; review\, ship\nATTENDEE:mailto:eve@example.com` + "\r\n",
+		"URL:https://awwdio.example.com/call/x/setup\r\n",
+		"ORGANIZER:mailto:alice@example.com\r\n",
+		"ATTENDEE;ROLE=REQ-PARTICIPANT;RSVP=FALSE:tel:+14155550100\r\n",
+		"END:VCALENDAR\r\n",
+	} {
+		if !strings.Contains(ics, want) {
+			t.Errorf("missing %q in:\n%s", want, ics)
+		}
+	}
+	// Every line ends in CRLF, so the title cannot add an attendee
+	if n := strings.Count(ics, "ATTENDEE"); n != 2 || strings.Contains(strings.ReplaceAll(ics, "\r\n", ""), "\r") {
+		t.Errorf("title injected a line:\n%q", ics)
+	}
+
+	// Relative join URLs are left out
+	if ics := string(call.ICS("bob@example.com", "/call/x/setup")); strings.Contains(ics, "URL:") || !strings.Contains(ics, "mailto:bob@example.com") {
+		t.Errorf("relative join URL:\n%s", ics)
+	}
+}
@@ -0,0 +1,107 @@
+package calls
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/kaustavdm/awwdio/internal/notify"
+)
+
+const (
+	// reminderLead is how long before the start time reminders go out
+	reminderLead = 15 * time.Minute
+	// reminderInterval is how often upcoming calls are checked for reminders
+	reminderInterval = time.Minute
+	// notificationTimeFormat is how call times appear in notifications
+	notificationTimeFormat = "Mon, 02 Jan 2006 15:04 MST"
+)
+
+// callNotification is the data available to notification templates
+type callNotification struct {
+	Title     string
+	Organizer string
+	Start     string
+	Duration  int
+	JoinURL   string
+	ICSURL    string
+}
+
+// sendInvitations queues an invitation for every invitee. Emails carry the
+// calendar invitation as an attachment, SMS link to it.
+func (h *Handler) sendInvitations(call Call) {
+	for _, invitee := range call.Invitees {
+		msg, err := notify.Render(notify.TemplateInvitation, invitee.Contact, h.notificationData(call, invitee))
+		if err != nil {
+			slog.Error("Failed to render invitation", "error", err, "call_id", call.ID)
+			continue
+		}
+		if notify.IsEmail(invitee.Contact) {
+			msg.Attachments = []notify.Attachment{{
+				Filename:    "invite.ics",
+				ContentType: "text/calendar; charset=utf-8; method=REQUEST",
+				Data:        call.ICS(invitee.Contact, h.JoinURL(call)),
+			}}
+		}
+		h.queue.Enqueue(msg)
+	}
+}
+
+// RunReminders periodically queues reminders for calls that start soon,
+// until ctx is done
+func (h *Handler) RunReminders(ctx context.Context) {
+	ticker := time.NewTicker(reminderInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			h.sendReminders(time.Now())
+		}
+	}
+}
+
+// sendReminders queues reminders to everyone in calls starting within
+// reminderLead of now that have not been reminded yet
+func (h *Handler) sendReminders(now time.Time) {
+	for _, call := range h.calls.List() {
+		if !call.RemindedAt.IsZero() || call.Start.Before(now) || call.Start.After(now.Add(reminderLead)) {
+			continue
+		}
+
+		// Mark the call first so that a slow queue never causes duplicates
+		if _, err := h.calls.Update(call.ID, func(c Call, _ bool) (Call, error) {
+			c.RemindedAt = now.UTC()
+			return c, nil
+		}); err != nil {
+			slog.Error("Failed to mark call reminded", "error", err, "call_id", call.ID)
+			continue
+		}
+
+		recipients := append([]Invitee{{Contact: call.Organizer}}, call.Invitees...)
+		for _, invitee := range recipients {
+			msg, err := notify.Render(notify.TemplateReminder, invitee.Contact, h.notificationData(call, invitee))
+			if err != nil {
+				slog.Error("Failed to render reminder", "error", err, "call_id", call.ID)
+				continue
+			}
+			h.queue.Enqueue(msg)
+		}
+		slog.Info("Call reminders queued", "call_id", call.ID, "recipients", len(recipients))
+	}
+}
+
+func (h *Handler) notificationData(call Call, invitee Invitee) callNotification {
+	data := callNotification{
+		Title:     call.Title,
+		Organizer: call.Organizer,
+		Start:     call.Start.UTC().Format(notificationTimeFormat),
+		Duration:  call.Duration,
+		JoinURL:   h.JoinURL(call),
+	}
+	if invitee.Token != "" {
+		data.ICSURL = h.InvitationURL(invitee)
+	}
+	return data
+}
@@ -0,0 +1,69 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kaustavdm/awwdio/internal/api/auth"
+	"github.com/kaustavdm/awwdio/internal/api/calls"
+)
+
+// Invitees are matched against the normalized subjects users log in with
+func TestCallInviteesNormalized(t *testing.T) {
+	_, handler := newTestServer(t)
+	token, err := auth.GenerateJWT("alice@example.com", testSecret, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	create := func(invitees string) *httptest.ResponseRecorder {
+		body := `{"title": "Standup", "duration": 30, "start": "` +
+			time.Now().Add(time.Hour).Format(time.RFC3339) + `", "invitees": ` + invitees + `}`
+		req := httptest.NewRequest("POST", "/api/calls", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := create(`[" Bob@Example.com", "bob@example.com", "ALICE@example.com"]`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	var call calls.CallResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &call); err != nil {
+		t.Fatal(err)
+	}
+	if len(call.Invitees) != 1 || call.Invitees[0].Contact != "bob@example.com" {
+		t.Errorf("invitees %+v, want bob@example.com only", call.Invitees)
+	}
+
+	if rec := create(`["bob@example.com", "bob"]`); rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "invitees[1]") {
+		t.Errorf("invalid invitee: status %d: %s", rec.Code, rec.Body)
+	}
+}
+
+// Control characters in titles could start new properties in invitations
+func TestCallTitleControlCharacters(t *testing.T) {
+	_, handler := newTestServer(t)
+	token, err := auth.GenerateJWT("alice@example.com", testSecret, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, title := range []string{`x\rATTENDEE:mailto:eve@example.com`, `x\nDTSTART:20000101T000000Z`, `x\u0000`} {
+		body := `{"title": "` + title + `", "duration": 30, "start": "` +
+			time.Now().Add(time.Hour).Format(time.RFC3339) + `", "invitees": ["bob@example.com"]}`
+		req := httptest.NewRequest("POST", "/api/calls", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("title %s: status %d, want 400", title, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package api
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kaustavdm/awwdio/config"
+	"github.com/kaustavdm/awwdio/internal/api/auth"
+)
+
+const (
+	testPublicURL = "https://awwdio.example.com"
+	testAuthToken = "twilio-auth-token"
+)
+
+// fakeTwilio answers the composition calls of the video handler
+type fakeTwilio struct{}
+
+func (fakeTwilio) RoundTrip(r *http.Request) (*http.Response, error) {
+	var body string
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/v1/Compositions":
+		body = `{"sid": "CJ1", "status": "enqueued"}`
+	case r.Method == http.MethodGet && r.URL.Path == "/v1/Compositions/CJ1/Media":
+		body = `{"redirect_to": "https://media.example.com/CJ1.mp4"}`
+	default:
+		return &http.Response{StatusCode: http.StatusNotFound, Body: io.NopCloser(strings.NewReader(`{}`)), Request: r}, nil
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(body)),
+		Request:    r,
+	}, nil
+}
+
+// twilioSignature signs a callback the way Twilio does: an HMAC-SHA1 of
+// the URL followed by the sorted form parameters
+func twilioSignature(rawURL string, form url.Values) string {
+	keys := make([]string, 0, len(form))
+	for k := range form {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	data := rawURL
+	for _, k := range keys {
+		data += k + form.Get(k)
+	}
+	mac := hmac.New(sha1.New, []byte(testAuthToken))
+	mac.Write([]byte(data))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestCompositionLifecycle(t *testing.T) {
+	_, handler := newTestServer(t, func(cfg *config.Config) {
+		cfg.PublicURL = testPublicURL
+		cfg.TwilioAuthToken = testAuthToken
+		cfg.RecordCalls = true
+	})
+	transport := http.DefaultTransport
+	http.DefaultTransport = fakeTwilio{}
+	t.Cleanup(func() { http.DefaultTransport = transport })
+
+	callback := func(path string, form url.Values) {
+		t.Helper()
+		path = "/api/video/callbacks/" + path
+		req := httptest.NewRequest("POST", path, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("X-Twilio-Signature", twilioSignature(testPublicURL+path, form))
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusNoContent {
+			t.Fatalf("%s %s: status %d", path, form.Get("StatusCallbackEvent"), rec.Code)
+		}
+	}
+	media := func(subject string) *httptest.ResponseRecorder {
+		token, err := auth.GenerateJWT(subject, testSecret, time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req := httptest.NewRequest("GET", "/api/video/compositions/CJ1/media", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	room := url.Values{"RoomSid": {"RM1"}, "RoomName": {"standup"}}
+	with := func(v url.Values, kv ...string) url.Values {
+		out := url.Values{}
+		for k, vs := range v {
+			out[k] = vs
+		}
+		for i := 0; i < len(kv); i += 2 {
+			out.Set(kv[i], kv[i+1])
+		}
+		return out
+	}
+	callback("room-status", with(room, "StatusCallbackEvent", "room-created"))
+	callback("room-status", with(room, "StatusCallbackEvent", "participant-connected",
+		"ParticipantSid", "PA1", "ParticipantIdentity", "alice@example.com"))
+	callback("room-status", with(room, "StatusCallbackEvent", "room-ended", "RoomDuration", "60"))
+
+	// Created on room completion, but not ready yet
+	if rec := media("alice@example.com"); rec.Code != http.StatusConflict {
+		t.Errorf("media before completion: status %d, want 409: %s", rec.Code, rec.Body)
+	}
+
+	comp := url.Values{"CompositionSid": {"CJ1"}, "RoomSid": {"RM1"}}
+	callback("composition-status", with(comp, "StatusCallbackEvent", "composition-progress"))
+	callback("composition-status", with(comp, "StatusCallbackEvent", "composition-available", "Duration", "58", "Size", "1024"))
+
+	rec := media("alice@example.com")
+	if rec.Code != http.StatusFound || rec.Header().Get("Location") != "https://media.example.com/CJ1.mp4" {
+		t.Errorf("participant: status %d, location %q", rec.Code, rec.Header().Get("Location"))
+	}
+	// Only participants of the room can download it
+	if rec := media("eve@example.com"); rec.Code != http.StatusNotFound {
+		t.Errorf("non-participant: status %d, want 404", rec.Code)
+	}
+	req := httptest.NewRequest("GET", "/api/video/compositions/CJ1/media", nil)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("anonymous: status %d, want 401", rec.Code)
+	}
+}
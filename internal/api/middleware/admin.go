@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/kaustavdm/awwdio/config"
+	"github.com/kaustavdm/awwdio/internal/api/apierror"
+	"github.com/kaustavdm/awwdio/internal/api/auth"
+	"github.com/kaustavdm/awwdio/internal/logging"
+)
+
+// RequireAdmin returns middleware that only lets users listed in
+// ADMIN_USERS, and API keys with the admin scope, through. It must run
+// after RequireAuth.
+func RequireAdmin(cfg *config.Provider) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			log := logging.FromContext(r.Context())
+			user := GetUser(r)
+			if user == nil || !isAdmin(cfg.Get(), user) {
+				if user != nil {
+					log.Warn("Admin access denied", "subject", user.Subject, "path", r.URL.Path)
+				}
+				w.Header().Set("Content-Type", "application/json")
+				apierror.Write(w, r, apierror.Forbidden("Admin access required"))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// isAdmin decides by scope for API keys, even those acting for an admin
+func isAdmin(cfg *config.Config, user *UserClaims) bool {
+	if user.KeyID != "" {
+		return user.HasScope(auth.ScopeAdmin)
+	}
+	return slices.Contains(cfg.AdminUsers, user.Subject)
+}
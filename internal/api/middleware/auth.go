@@ -2,12 +2,16 @@ package middleware
 
 import (
 	"context"
-	"encoding/json"
-	"log/slog"
 	"net/http"
+	"slices"
 	"strings"
 
+	"github.com/kaustavdm/awwdio/config"
+	"github.com/kaustavdm/awwdio/internal/api/apierror"
 	"github.com/kaustavdm/awwdio/internal/api/auth"
+	"github.com/kaustavdm/awwdio/internal/logging"
+	"github.com/kaustavdm/awwdio/internal/normalize"
+	"github.com/kaustavdm/awwdio/internal/store"
 )
 
 // ContextKey is the type for context keys
@@ -16,48 +20,90 @@ type ContextKey string
 // UserContextKey is the key for storing user identity in context
 const UserContextKey ContextKey = "user"
 
-// UserClaims represents the authenticated user from JWT
+// UserClaims represents the authenticated user from a JWT or API key
 type UserClaims struct {
-	Subject string // User identifier (email or phone)
+	Subject string // User identifier (email or phone), or service:<key id>
+
+	// For API keys: the key ID and its scopes. Subject is the user the key
+	// acts for, if any.
+	KeyID  string
+	Scopes []string
+}
+
+// HasScope reports whether the user may use routes that require scope.
+// User sessions have every scope.
+func (u *UserClaims) HasScope(scope string) bool {
+	return u.KeyID == "" || slices.Contains(u.Scopes, scope)
+}
+
+// Actor identifies who made the request for auditing: the user, or the
+// API key even when it acts for a user
+func (u *UserClaims) Actor() string {
+	if u.KeyID != "" {
+		return "service:" + u.KeyID
+	}
+	return u.Subject
 }
 
-// ErrorResponse represents an error response
-type ErrorResponse struct {
-	Error string `json:"error"`
+// AuditDetails adds the user an API key acted for to details, so entries
+// recorded with Actor show on whose behalf the key acted
+func (u *UserClaims) AuditDetails(details map[string]string) map[string]string {
+	if u.KeyID == "" || u.Subject == u.Actor() {
+		return details
+	}
+	if details == nil {
+		details = map[string]string{}
+	}
+	details["act_as"] = u.Subject
+	return details
 }
 
-// RequireAuth returns middleware that validates JWT tokens
-func RequireAuth(jwtSecret string) func(http.Handler) http.Handler {
+// RequireAuth returns middleware that validates JWT tokens against the
+// current JWT secret, or API keys. The token comes from the Authorization
+// header or, in cookie mode, the session cookie; API keys only from the
+// header. Sessions of disabled users are rejected, and keys cannot act for
+// them.
+func RequireAuth(cfg *config.Provider, keys *auth.APIKeys, users *store.Collection[auth.User]) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			log := logging.FromContext(r.Context())
 			w.Header().Set("Content-Type", "application/json")
+			c := cfg.Get()
 
-			// Get Authorization header
-			authHeader := r.Header.Get("Authorization")
-			if authHeader == "" {
-				slog.Debug("Missing Authorization header")
-				w.WriteHeader(http.StatusUnauthorized)
-				json.NewEncoder(w).Encode(ErrorResponse{Error: "Authorization header required"})
+			// Bearer token, or the session cookie in cookie mode
+			token, ok := auth.SessionToken(r, c)
+			if !ok {
+				log.Debug("Invalid Authorization header format")
+				apierror.Write(w, r, apierror.New(http.StatusUnauthorized, apierror.CodeInvalidToken, "Invalid authorization format"))
 				return
 			}
-
-			// Parse Bearer token
-			parts := strings.SplitN(authHeader, " ", 2)
-			if len(parts) != 2 || strings.ToLower(parts[0]) != "bearer" {
-				slog.Debug("Invalid Authorization header format")
-				w.WriteHeader(http.StatusUnauthorized)
-				json.NewEncoder(w).Encode(ErrorResponse{Error: "Invalid authorization format"})
+			if token == "" {
+				log.Debug("Missing Authorization header or session cookie")
+				apierror.Write(w, r, apierror.Unauthorized("Authorization header required"))
 				return
 			}
 
-			token := parts[1]
+			if strings.HasPrefix(token, auth.APIKeyPrefix) && r.Header.Get("Authorization") != "" {
+				user, apiErr := authenticateKey(r, c, keys, users, token)
+				if apiErr != nil {
+					apierror.Write(w, r, apiErr)
+					return
+				}
+				log.Debug("API key authenticated", "key_id", user.KeyID, "subject", user.Subject)
+				next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), UserContextKey, user)))
+				return
+			}
 
 			// Validate JWT
-			claims, err := auth.ValidateJWT(token, jwtSecret)
+			claims, err := auth.ValidateJWT(token, c.JWTSecret)
 			if err != nil {
-				slog.Debug("JWT validation failed", "error", err)
-				w.WriteHeader(http.StatusUnauthorized)
-				json.NewEncoder(w).Encode(ErrorResponse{Error: "Invalid or expired token"})
+				log.Debug("JWT validation failed", "error", err)
+				apierror.Write(w, r, apierror.New(http.StatusUnauthorized, apierror.CodeInvalidToken, "Invalid or expired token"))
+				return
+			}
+			if auth.IsDisabled(users, claims.Sub) {
+				log.Warn("Session of disabled user rejected", "subject", claims.Sub)
+				apierror.Write(w, r, apierror.Forbidden("Account disabled"))
 				return
 			}
 
@@ -67,12 +113,60 @@ func RequireAuth(jwtSecret string) func(http.Handler) http.Handler {
 			}
 			ctx := context.WithValue(r.Context(), UserContextKey, userClaims)
 
-			slog.Debug("User authenticated", "subject", claims.Sub)
+			log.Debug("User authenticated", "subject", claims.Sub)
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
 }
 
+// authenticateKey verifies an API key, and the user it acts for if the
+// request names one
+func authenticateKey(r *http.Request, cfg *config.Config, keys *auth.APIKeys, users *store.Collection[auth.User], token string) (*UserClaims, *apierror.Error) {
+	log := logging.FromContext(r.Context())
+	key, err := keys.Verify(token)
+	if err != nil {
+		log.Debug("API key validation failed", "error", err)
+		return nil, apierror.New(http.StatusUnauthorized, apierror.CodeInvalidToken, "Invalid, expired or revoked API key")
+	}
+	user := &UserClaims{Subject: key.Subject(), KeyID: key.ID, Scopes: key.Scopes}
+
+	actAs := r.Header.Get(auth.ActAsHeader)
+	if actAs == "" {
+		return user, nil
+	}
+	if !user.HasScope(auth.ScopeActAs) {
+		log.Warn("API key may not act for users", "key_id", key.ID)
+		return nil, apierror.Forbidden("API key lacks the " + auth.ScopeActAs + " scope")
+	}
+	subject, err := normalize.Subject(actAs, cfg.DefaultCountryCode)
+	if err != nil {
+		return nil, apierror.Validation("Invalid "+auth.ActAsHeader+" header",
+			apierror.FieldError{Field: auth.ActAsHeader, Message: "must be an email address or phone number"})
+	}
+	if auth.IsDisabled(users, subject) {
+		log.Warn("API key may not act for disabled user", "key_id", key.ID, "subject", subject)
+		return nil, apierror.Forbidden("Account disabled")
+	}
+	log.Info("API key acting for user", "key_id", key.ID, "subject", subject)
+	user.Subject = subject
+	return user, nil
+}
+
+// RequireScope returns middleware that only lets API keys with scope
+// through. User sessions always pass. It must run after RequireAuth.
+func RequireScope(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if user := GetUser(r); user == nil || !user.HasScope(scope) {
+				logging.FromContext(r.Context()).Warn("API key scope missing", "scope", scope, "path", r.URL.Path)
+				apierror.Write(w, r, apierror.Forbidden("API key lacks the "+scope+" scope"))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
 // GetUser extracts user claims from request context
 func GetUser(r *http.Request) *UserClaims {
 	if user, ok := r.Context().Value(UserContextKey).(*UserClaims); ok {
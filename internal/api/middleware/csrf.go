@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/kaustavdm/awwdio/config"
+	"github.com/kaustavdm/awwdio/internal/api/apierror"
+	"github.com/kaustavdm/awwdio/internal/api/auth"
+	"github.com/kaustavdm/awwdio/internal/logging"
+)
+
+// RequireCSRF returns middleware that enforces double-submit CSRF tokens on
+// state-changing requests authenticated by the session cookie, i.e. those
+// carrying the cookie but no Authorization header. Bearer token requests
+// cannot be forged by other sites and pass through, as do requests without
+// a session. Safe requests from a session that lost its CSRF cookie get a
+// new one.
+func RequireCSRF(cfg *config.Provider) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") != "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if _, err := r.Cookie(auth.SessionCookie); err != nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			var cookieToken string
+			if c, err := r.Cookie(auth.CSRFCookie); err == nil {
+				cookieToken = c.Value
+			}
+
+			switch r.Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions:
+				if cookieToken == "" {
+					auth.SetCSRFCookie(w, cfg.Get(), auth.NewCSRFToken())
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			headerToken := r.Header.Get(auth.CSRFHeader)
+			if cookieToken == "" || subtle.ConstantTimeCompare([]byte(cookieToken), []byte(headerToken)) != 1 {
+				logging.FromContext(r.Context()).Warn("CSRF token missing or mismatched", "method", r.Method, "path", r.URL.Path)
+				w.Header().Set("Content-Type", "application/json")
+				apierror.Write(w, r, apierror.New(http.StatusForbidden, apierror.CodeCSRF, "Invalid CSRF token"))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
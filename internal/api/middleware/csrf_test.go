@@ -0,0 +1,91 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/kaustavdm/awwdio/config"
+	"github.com/kaustavdm/awwdio/internal/api/apierror"
+	"github.com/kaustavdm/awwdio/internal/api/auth"
+)
+
+func TestRequireCSRF(t *testing.T) {
+	const token = "csrf-token"
+	tests := []struct {
+		name          string
+		method        string
+		session       bool
+		csrfCookie    string
+		csrfHeader    string
+		authorization string
+
+		wantNext   bool
+		wantCookie bool
+	}{
+		{name: "POST without session", method: http.MethodPost, wantNext: true},
+		{name: "POST without token", method: http.MethodPost, session: true, csrfCookie: token},
+		{name: "POST without cookie", method: http.MethodPost, session: true, csrfHeader: token},
+		{name: "POST with wrong token", method: http.MethodPost, session: true, csrfCookie: token, csrfHeader: "other"},
+		{name: "DELETE with wrong token", method: http.MethodDelete, session: true, csrfCookie: token, csrfHeader: "other"},
+		{name: "POST with matching token", method: http.MethodPost, session: true, csrfCookie: token, csrfHeader: token, wantNext: true},
+		{name: "POST with bearer token", method: http.MethodPost, session: true, authorization: "Bearer jwt", wantNext: true},
+		{name: "GET issues cookie", method: http.MethodGet, session: true, wantNext: true, wantCookie: true},
+		{name: "HEAD issues cookie", method: http.MethodHead, session: true, wantNext: true, wantCookie: true},
+		{name: "GET keeps cookie", method: http.MethodGet, session: true, csrfCookie: token, wantNext: true},
+		{name: "GET without session", method: http.MethodGet, wantNext: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Static(&config.Config{SessionCookieSecure: true, SessionCookieSameSite: "strict"})
+			called := false
+			h := RequireCSRF(cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				called = true
+			}))
+
+			req := httptest.NewRequest(tt.method, "/api/rooms", nil)
+			if tt.session {
+				req.AddCookie(&http.Cookie{Name: auth.SessionCookie, Value: "jwt"})
+			}
+			if tt.csrfCookie != "" {
+				req.AddCookie(&http.Cookie{Name: auth.CSRFCookie, Value: tt.csrfCookie})
+			}
+			if tt.csrfHeader != "" {
+				req.Header.Set(auth.CSRFHeader, tt.csrfHeader)
+			}
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if called != tt.wantNext {
+				t.Fatalf("next called = %v, want %v", called, tt.wantNext)
+			}
+			if !tt.wantNext {
+				var body apierror.Error
+				json.Unmarshal(rec.Body.Bytes(), &body)
+				if rec.Code != http.StatusForbidden || body.Code != apierror.CodeCSRF {
+					t.Errorf("got %d %q, want 403 %s", rec.Code, rec.Body, apierror.CodeCSRF)
+				}
+			}
+
+			cookies := rec.Result().Cookies()
+			if !tt.wantCookie {
+				if len(cookies) != 0 {
+					t.Errorf("unexpected cookies: %v", cookies)
+				}
+				return
+			}
+			if len(cookies) != 1 {
+				t.Fatalf("cookies = %v, want a CSRF cookie", cookies)
+			}
+			c := cookies[0]
+			if c.Name != auth.CSRFCookie || c.Value == "" || c.Path != "/" || c.HttpOnly ||
+				!c.Secure || c.SameSite != http.SameSiteStrictMode {
+				t.Errorf("CSRF cookie = %+v", c)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/kaustavdm/awwdio/internal/api/apierror"
+)
+
+// JSONNotFound serves mux, but answers requests no route matches with a
+// JSON error instead of ServeMux's plain text, keeping the status (404, or
+// 405 with its Allow header) that mux would have sent.
+func JSONNotFound(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h, pattern := mux.Handler(r)
+		if pattern != "" {
+			mux.ServeHTTP(w, r)
+			return
+		}
+
+		// Like ServeMux, clear the pattern an outer mux left on the request,
+		// so route metrics do not credit this path to it
+		r.Pattern = ""
+
+		// Find out which error mux would send, without its body
+		probe := &statusProbe{header: http.Header{}}
+		h.ServeHTTP(probe, r)
+
+		if probe.status == http.StatusMethodNotAllowed {
+			w.Header().Set("Allow", probe.header.Get("Allow"))
+			apierror.Write(w, r, apierror.New(http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "Method not allowed"))
+			return
+		}
+		apierror.Write(w, r, apierror.NotFound("Not found"))
+	})
+}
+
+// statusProbe records the status and headers of a response, dropping the body
+type statusProbe struct {
+	header http.Header
+	status int
+}
+
+func (p *statusProbe) Header() http.Header { return p.header }
+
+func (p *statusProbe) Write(b []byte) (int, error) { return len(b), nil }
+
+func (p *statusProbe) WriteHeader(status int) {
+	if p.status == 0 {
+		p.status = status
+	}
+}
@@ -0,0 +1,316 @@
+// Package openapi describes the API as an OpenAPI 3.1 document, built from
+// the routes modules register and the Go types of their requests and
+// responses, so the description cannot fall behind the code.
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/kaustavdm/awwdio/internal/api/apierror"
+)
+
+// Auth is how the routes of a mux are authenticated
+type Auth string
+
+const (
+	AuthNone   Auth = "none"
+	AuthUser   Auth = "user"   // JWT bearer token or session cookie
+	AuthAdmin  Auth = "admin"  // As AuthUser, for an admin user
+	AuthTwilio Auth = "twilio" // Twilio request signature
+)
+
+// Operation describes one route
+type Operation struct {
+	ID      string // operationId, unique across the API
+	Summary string
+
+	// Request and Response are zero values of the JSON body types, or nil
+	// for routes without one
+	Request  any
+	Response any
+
+	// RequestType and ResponseType are the media types of bodies that are
+	// not JSON, such as Twilio's form-encoded callbacks
+	RequestType  string
+	ResponseType string
+
+	// Status is the success status, 200 if zero
+	Status int
+
+	Query []Param
+}
+
+// Param is a query parameter
+type Param struct {
+	Name        string
+	Description string
+	Type        string // JSON Schema type, "string" if empty
+	Required    bool
+}
+
+// Document collects the routes of the API
+type Document struct {
+	Title   string
+	Version string
+	// SessionCookie is the name of the session cookie AuthUser accepts
+	SessionCookie string
+
+	routes []Route
+
+	once sync.Once
+	json []byte
+}
+
+// Route is a documented route
+type Route struct {
+	Method string
+	Path   string // Full path, with {name} path parameters
+	Auth   Auth
+	Scope  string // API key scope, if any
+	Operation
+}
+
+// New returns an empty document
+func New(title, version string) *Document {
+	return &Document{Title: title, Version: version}
+}
+
+// Routes returns the documented routes, in registration order
+func (d *Document) Routes() []Route {
+	return d.routes
+}
+
+// Mux returns a mux whose routes are documented under prefix, the path it
+// is mounted at, and authenticated by auth. API keys need scope, if not
+// empty, to use them.
+func (d *Document) Mux(prefix string, auth Auth, scope string) *Mux {
+	return &Mux{ServeMux: http.NewServeMux(), doc: d, prefix: prefix, auth: auth, scope: scope}
+}
+
+// Mux is an http.ServeMux whose routes are described as they are
+// registered. Patterns must include a method.
+type Mux struct {
+	*http.ServeMux
+
+	doc    *Document
+	prefix string
+	auth   Auth
+	scope  string
+}
+
+// Prefix returns the path the mux is mounted at
+func (m *Mux) Prefix() string {
+	return m.prefix
+}
+
+// Handle registers handler for pattern and documents it as op
+func (m *Mux) Handle(pattern string, handler http.Handler, op Operation) {
+	m.ServeMux.Handle(pattern, handler)
+
+	method, path, ok := strings.Cut(pattern, " ")
+	if !ok || !strings.HasPrefix(path, "/") {
+		panic("openapi: pattern " + strconv.Quote(pattern) + " must be METHOD /path")
+	}
+	m.doc.routes = append(m.doc.routes, Route{
+		Method:    method,
+		Path:      m.prefix + specPath(path),
+		Auth:      m.auth,
+		Scope:     m.scope,
+		Operation: op,
+	})
+}
+
+// Scope returns the API key scope the routes of the mux require
+func (m *Mux) Scope() string {
+	return m.scope
+}
+
+// HandleFunc registers handler for pattern and documents it as op
+func (m *Mux) HandleFunc(pattern string, handler http.HandlerFunc, op Operation) {
+	m.Handle(pattern, handler, op)
+}
+
+var wildcard = regexp.MustCompile(`\{([^}]*)\}`)
+
+// specPath turns a ServeMux path into an OpenAPI one: {name...} becomes
+// {name}, and {$} goes
+func specPath(path string) string {
+	return wildcard.ReplaceAllStringFunc(path, func(w string) string {
+		name := strings.TrimSuffix(w[1:len(w)-1], "...")
+		if name == "$" {
+			return ""
+		}
+		return "{" + name + "}"
+	})
+}
+
+// ServeHTTP serves the document as JSON
+func (d *Document) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	d.once.Do(func() {
+		d.json, _ = json.MarshalIndent(d.Spec(), "", "  ")
+	})
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Write(d.json)
+}
+
+// Spec builds the OpenAPI document
+func (d *Document) Spec() map[string]any {
+	s := newSchemas()
+	errorSchema := s.of(reflect.TypeFor[apierror.Error]())
+
+	paths := map[string]any{}
+	for _, rt := range d.routes {
+		item, _ := paths[rt.Path].(map[string]any)
+		if item == nil {
+			item = map[string]any{}
+			paths[rt.Path] = item
+		}
+		item[strings.ToLower(rt.Method)] = d.operation(s, rt)
+	}
+
+	return map[string]any{
+		"openapi": "3.1.0",
+		"info": map[string]any{
+			"title":   d.Title,
+			"version": d.Version,
+		},
+		"paths": paths,
+		"components": map[string]any{
+			"schemas": s.defs,
+			"responses": map[string]any{
+				"Error": map[string]any{
+					"description": "Error. Clients that accept " + apierror.ProblemContentType +
+						" get an RFC 9457 problem document with the same code and request_id instead.",
+					"content": map[string]any{
+						"application/json": map[string]any{"schema": errorSchema},
+					},
+				},
+			},
+			"securitySchemes": map[string]any{
+				"bearerAuth": map[string]any{
+					"type":   "http",
+					"scheme": "bearer",
+					"description": "A session JWT, or an API key (awk_...) with the scope the operation lists. " +
+						"Keys with the act_as scope may act for a user named in the X-Awwdio-Act-As header.",
+				},
+				"cookieAuth": map[string]any{
+					"type": "apiKey",
+					"in":   "cookie",
+					"name": d.SessionCookie,
+				},
+				"twilioSignature": map[string]any{
+					"type": "apiKey",
+					"in":   "header",
+					"name": "X-Twilio-Signature",
+				},
+			},
+		},
+	}
+}
+
+// operation builds the Operation Object for rt
+func (d *Document) operation(s *schemas, rt Route) map[string]any {
+	op := map[string]any{
+		"operationId": rt.ID,
+		"summary":     rt.Summary,
+		"tags":        []string{tag(rt.Path)},
+	}
+
+	var params []any
+	for _, name := range wildcard.FindAllStringSubmatch(rt.Path, -1) {
+		params = append(params, map[string]any{
+			"name":     name[1],
+			"in":       "path",
+			"required": true,
+			"schema":   map[string]any{"type": "string"},
+		})
+	}
+	for _, p := range rt.Query {
+		typ := p.Type
+		if typ == "" {
+			typ = "string"
+		}
+		param := map[string]any{
+			"name":     p.Name,
+			"in":       "query",
+			"required": p.Required,
+			"schema":   map[string]any{"type": typ},
+		}
+		if p.Description != "" {
+			param["description"] = p.Description
+		}
+		params = append(params, param)
+	}
+	if params != nil {
+		op["parameters"] = params
+	}
+
+	if body := content(s, rt.Request, rt.RequestType); body != nil {
+		op["requestBody"] = map[string]any{"required": true, "content": body}
+	}
+
+	status := rt.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	success := map[string]any{"description": http.StatusText(status)}
+	if body := content(s, rt.Response, rt.ResponseType); body != nil {
+		success["content"] = body
+	}
+	op["responses"] = map[string]any{
+		strconv.Itoa(status): success,
+		"default":            map[string]any{"$ref": "#/components/responses/Error"},
+	}
+
+	switch rt.Auth {
+	case AuthUser, AuthAdmin:
+		// Bearer tokens may be API keys, which need the scope
+		scopes := []string{}
+		if rt.Scope != "" {
+			scopes = append(scopes, rt.Scope)
+		}
+		op["security"] = []any{
+			map[string]any{"bearerAuth": scopes},
+			map[string]any{"cookieAuth": []string{}},
+		}
+		if rt.Auth == AuthAdmin {
+			op["description"] = "Requires an admin user."
+		}
+	case AuthTwilio:
+		op["security"] = []any{map[string]any{"twilioSignature": []string{}}}
+	default:
+		op["security"] = []any{}
+	}
+	return op
+}
+
+// content builds the Content map of a body of the type of v, or of
+// mediaType for bodies that are not JSON. It returns nil for no body.
+func content(s *schemas, v any, mediaType string) map[string]any {
+	if mediaType != "" {
+		schema := map[string]any{"type": "string"}
+		if mediaType == "application/x-www-form-urlencoded" {
+			schema = map[string]any{"type": "object"}
+		}
+		return map[string]any{mediaType: map[string]any{"schema": schema}}
+	}
+	if v == nil {
+		return nil
+	}
+	return map[string]any{
+		"application/json": map[string]any{"schema": s.of(reflect.TypeOf(v))},
+	}
+}
+
+// tag groups operations by the first path segment after /api
+func tag(path string) string {
+	segments := strings.Split(strings.TrimPrefix(path, "/api/"), "/")
+	return segments[0]
+}
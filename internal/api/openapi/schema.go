@@ -0,0 +1,216 @@
+package openapi
+
+import (
+	"encoding"
+	"encoding/json"
+	"path"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kaustavdm/awwdio/internal/api/request"
+)
+
+// schemas builds JSON Schemas for Go types the way encoding/json encodes
+// them. Named structs become components, referenced by name.
+type schemas struct {
+	defs  map[string]any
+	names map[reflect.Type]string
+}
+
+func newSchemas() *schemas {
+	return &schemas{defs: map[string]any{}, names: map[reflect.Type]string{}}
+}
+
+var (
+	timeType          = reflect.TypeFor[time.Time]()
+	rawMessageType    = reflect.TypeFor[json.RawMessage]()
+	jsonMarshalerType = reflect.TypeFor[json.Marshaler]()
+	textMarshalerType = reflect.TypeFor[encoding.TextMarshaler]()
+)
+
+// of returns the schema of t
+func (s *schemas) of(t reflect.Type) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case t == rawMessageType:
+		return map[string]any{}
+	case t.Implements(jsonMarshalerType) || reflect.PointerTo(t).Implements(jsonMarshalerType):
+		// Custom encoding; nothing to tell from the type
+		return map[string]any{}
+	case t.Implements(textMarshalerType) || reflect.PointerTo(t).Implements(textMarshalerType):
+		return map[string]any{"type": "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": "string", "contentEncoding": "base64"}
+		}
+		return map[string]any{"type": "array", "items": s.of(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": s.of(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return s.object(t)
+		}
+		return map[string]any{"$ref": "#/components/schemas/" + s.ref(t)}
+	}
+	// Interfaces: any JSON value
+	return map[string]any{}
+}
+
+// ref defines the component schema of the named struct t, if not yet done,
+// and returns its name
+func (s *schemas) ref(t reflect.Type) string {
+	if name, ok := s.names[t]; ok {
+		return name
+	}
+	name := t.Name()
+	if _, taken := s.defs[name]; taken {
+		// Same name in another package: qualify it
+		pkg := path.Base(t.PkgPath())
+		name = strings.ToUpper(pkg[:1]) + pkg[1:] + name
+	}
+	s.names[t] = name
+	// Reserve the name before recursing, for self-referencing types
+	s.defs[name] = nil
+	s.defs[name] = s.object(t)
+	return name
+}
+
+// object returns the schema of struct t. Fields without omitempty or
+// omitzero, and fields that must pass validation as required, are required.
+func (s *schemas) object(t reflect.Type) map[string]any {
+	properties := map[string]any{}
+	required := []string{}
+	s.fields(t, properties, &required)
+
+	schema := map[string]any{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// fields adds the properties of struct t, including those of embedded
+// structs, as encoding/json sees them
+func (s *schemas) fields(t reflect.Type, properties map[string]any, required *[]string) {
+	for i := range t.NumField() {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+
+		ft := sf.Type
+		for ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if sf.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			s.fields(ft, properties, required)
+			continue
+		}
+		if !sf.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+
+		schema := s.of(sf.Type)
+		if v, ok := sf.Tag.Lookup("validate"); ok {
+			constrain(schema, t, ft, v)
+		}
+		properties[name] = schema
+
+		optional := false
+		for _, o := range strings.Split(opts, ",") {
+			optional = optional || o == "omitempty" || o == "omitzero"
+		}
+		if !optional || strings.Contains(","+sf.Tag.Get("validate")+",", ",required,") {
+			*required = append(*required, name)
+		}
+	}
+}
+
+// ruleNames describe rules in conditions
+var ruleNames = map[string]string{
+	"email":    "an email address",
+	"e164":     "an E.164 phone number",
+	"digits":   "only digits",
+	"roomname": "a room name",
+}
+
+// constrain adds the rules of a validate tag to schema, the schema of a
+// field of type t in struct parent. Conditional rules are only described.
+func constrain(schema map[string]any, parent, t reflect.Type, tag string) {
+	var conditions []string
+	for _, rule := range strings.Split(tag, ",") {
+		name, arg, _ := strings.Cut(rule, "=")
+		if base, ok := strings.CutSuffix(name, "_if"); ok {
+			field, value, _ := strings.Cut(arg, ":")
+			if sf, ok := parent.FieldByName(field); ok {
+				field, _, _ = strings.Cut(sf.Tag.Get("json"), ",")
+			}
+			desc, ok := ruleNames[base]
+			if !ok {
+				desc = base
+			}
+			conditions = append(conditions, desc+" when "+field+" is "+value)
+			continue
+		}
+
+		switch name {
+		case "oneof":
+			schema["enum"] = strings.Fields(arg)
+		case "min", "max":
+			n, _ := strconv.Atoi(arg)
+			schema[limitKeyword(name, t)] = n
+		case "email":
+			schema["format"] = "email"
+		default:
+			if p := request.Pattern(name); p != "" {
+				schema["pattern"] = p
+			}
+		}
+	}
+	if conditions != nil {
+		schema["description"] = "Must be " + strings.Join(conditions, "; ")
+	}
+}
+
+// limitKeyword is the JSON Schema keyword for a min or max rule on a value
+// of type t
+func limitKeyword(rule string, t reflect.Type) string {
+	suffix := "Length"
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array:
+		suffix = "Items"
+	case reflect.Map:
+		suffix = "Properties"
+	case reflect.String:
+	default:
+		if rule == "min" {
+			return "minimum"
+		}
+		return "maximum"
+	}
+	return rule + suffix
+}
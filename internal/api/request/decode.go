@@ -0,0 +1,89 @@
+// Package request decodes and validates JSON request bodies.
+package request
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/kaustavdm/awwdio/internal/api/apierror"
+)
+
+// MaxBodySize bounds request bodies. API requests are small; anything
+// bigger is a mistake or abuse.
+const MaxBodySize = 64 << 10
+
+// Decode reads r's body, which must be a single JSON object of at most
+// MaxBodySize bytes sent as application/json, into v. Unknown fields are
+// rejected, so typos in field names do not go unnoticed. The returned error
+// is ready to be written with apierror.Write.
+func Decode(w http.ResponseWriter, r *http.Request, v any) *apierror.Error {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "application/json" {
+		return apierror.New(http.StatusUnsupportedMediaType, apierror.CodeUnsupportedMediaType,
+			"Content-Type must be application/json")
+	}
+
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, MaxBodySize))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return decodeError(err)
+	}
+	// Anything after the object, even another object, is an error
+	if err := dec.Decode(&struct{}{}); !errors.Is(err, io.EOF) {
+		return apierror.BadRequest("Request body must contain a single JSON object")
+	}
+	return nil
+}
+
+// decodeError describes what was wrong with the body
+func decodeError(err error) *apierror.Error {
+	var (
+		syntaxErr   *json.SyntaxError
+		typeErr     *json.UnmarshalTypeError
+		maxBytesErr *http.MaxBytesError
+	)
+	switch {
+	case errors.As(err, &maxBytesErr):
+		return apierror.New(http.StatusRequestEntityTooLarge, apierror.CodePayloadTooLarge,
+			fmt.Sprintf("Request body must not exceed %d bytes", maxBytesErr.Limit))
+	case errors.As(err, &syntaxErr):
+		return apierror.BadRequest(fmt.Sprintf("Malformed JSON at offset %d", syntaxErr.Offset))
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return apierror.BadRequest("Malformed JSON")
+	case errors.Is(err, io.EOF):
+		return apierror.BadRequest("Request body is empty")
+	case errors.As(err, &typeErr):
+		return apierror.Validation("Invalid request body", apierror.FieldError{
+			Field:   typeErr.Field,
+			Message: "must be a " + jsonType(typeErr.Type.Kind().String()),
+		})
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		// encoding/json has no error type for this one
+		field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		return apierror.Validation("Invalid request body", apierror.FieldError{
+			Field:   field,
+			Message: "unknown field",
+		})
+	}
+	return apierror.BadRequest("Invalid request body")
+}
+
+// jsonType names a Go kind the way API clients know it
+func jsonType(kind string) string {
+	switch kind {
+	case "string":
+		return "string"
+	case "bool":
+		return "boolean"
+	case "slice", "array":
+		return "list"
+	case "map", "struct":
+		return "object"
+	}
+	return "number"
+}
@@ -0,0 +1,63 @@
+package request
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/kaustavdm/awwdio/internal/api/apierror"
+)
+
+func TestDecode(t *testing.T) {
+	type body struct {
+		Name  string `json:"name"`
+		Count int    `json:"count"`
+	}
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		status      int // 0 if the body decodes
+		code        apierror.Code
+		field       string
+	}{
+		{"valid", "application/json", `{"name": "a", "count": 1}`, 0, "", ""},
+		{"charset", "application/json; charset=utf-8", `{"name": "a"}`, 0, "", ""},
+		{"trailing whitespace", "application/json", "{}\n", 0, "", ""},
+		{"wrong content type", "text/plain", `{}`, http.StatusUnsupportedMediaType, apierror.CodeUnsupportedMediaType, ""},
+		{"missing content type", "", `{}`, http.StatusUnsupportedMediaType, apierror.CodeUnsupportedMediaType, ""},
+		{"unknown field", "application/json", `{"nmae": "a"}`, http.StatusBadRequest, apierror.CodeValidation, "nmae"},
+		{"wrong type", "application/json", `{"count": "one"}`, http.StatusBadRequest, apierror.CodeValidation, "count"},
+		{"trailing object", "application/json", `{}{}`, http.StatusBadRequest, "", ""},
+		{"trailing data", "application/json", `{} x`, http.StatusBadRequest, "", ""},
+		{"malformed", "application/json", `{"name": }`, http.StatusBadRequest, "", ""},
+		{"truncated", "application/json", `{"name": "a"`, http.StatusBadRequest, "", ""},
+		{"empty", "application/json", ``, http.StatusBadRequest, "", ""},
+		{"oversized", "application/json", `{"name": "` + strings.Repeat("a", MaxBodySize) + `"}`, http.StatusRequestEntityTooLarge, apierror.CodePayloadTooLarge, ""},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("POST", "/", strings.NewReader(tt.body))
+		if tt.contentType != "" {
+			req.Header.Set("Content-Type", tt.contentType)
+		}
+		var v body
+		err := Decode(httptest.NewRecorder(), req, &v)
+		if tt.status == 0 {
+			if err != nil {
+				t.Errorf("%s: got %v, want no error", tt.name, err)
+			}
+			continue
+		}
+		if err == nil || err.Status != tt.status {
+			t.Errorf("%s: got %+v, want status %d", tt.name, err, tt.status)
+			continue
+		}
+		if tt.code != "" && err.Code != tt.code {
+			t.Errorf("%s: code %q, want %q", tt.name, err.Code, tt.code)
+		}
+		if tt.field != "" && (len(err.Fields) != 1 || err.Fields[0].Field != tt.field) {
+			t.Errorf("%s: fields %+v, want %s", tt.name, err.Fields, tt.field)
+		}
+	}
+}
@@ -0,0 +1,289 @@
+package request
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/kaustavdm/awwdio/internal/api/apierror"
+)
+
+// Validate checks the fields of the struct v points to against the rules
+// in their `validate` tags, comma separated:
+//
+//	required     must not be empty
+//	oneof=a b    one of the listed values
+//	min=N, max=N length of a string or list, or value of a number
+//	email        an email address
+//	e164         a phone number in E.164 format, e.g. +15555550100
+//	digits       only the digits 0-9
+//	roomname     a room name: 1-128 letters, digits, '-' or '_'
+//
+// Rules without an argument, other than required, can be made conditional
+// on another field with an _if suffix, e.g. email_if=Channel:email. Rules
+// other than required are skipped for empty values. All failing fields are
+// reported together.
+//
+// Invalid tags are programming errors: Validate panics on the first value
+// of a type whose tags CheckTags rejects, whatever the field values.
+func Validate(v any) *apierror.Error {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	rt := rv.Type()
+	if msg := tagProblem(rt); msg != "" {
+		panic(msg)
+	}
+
+	var fields []apierror.FieldError
+	for i := range rt.NumField() {
+		sf := rt.Field(i)
+		tag := sf.Tag.Get("validate")
+		if tag == "" {
+			continue
+		}
+		if msg := checkField(rv, rv.Field(i), tag); msg != "" {
+			fields = append(fields, apierror.FieldError{Field: jsonName(sf), Message: msg})
+		}
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+
+	messages := make([]string, len(fields))
+	for i, f := range fields {
+		messages[i] = f.Field + " " + f.Message
+	}
+	return apierror.Validation("Invalid request: "+strings.Join(messages, "; "), fields...)
+}
+
+var (
+	e164Pattern     = regexp.MustCompile(`^\+[1-9][0-9]{1,14}$`)
+	digitsPattern   = regexp.MustCompile(`^[0-9]+$`)
+	roomNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,128}$`)
+)
+
+// Pattern returns the regular expression a string must match to pass
+// rule, or "" for rules not defined by one. It lets API descriptions state
+// the same constraints Validate checks.
+func Pattern(rule string) string {
+	switch rule {
+	case "e164":
+		return e164Pattern.String()
+	case "digits":
+		return digitsPattern.String()
+	case "roomname":
+		return roomNamePattern.String()
+	}
+	return ""
+}
+
+// checkedTypes maps the struct types Validate has seen to what is wrong
+// with their tags, "" if nothing
+var checkedTypes sync.Map
+
+// tagProblem checks the tags of t on first use
+func tagProblem(t reflect.Type) string {
+	if msg, ok := checkedTypes.Load(t); ok {
+		return msg.(string)
+	}
+	msg, _ := checkedTypes.LoadOrStore(t, checkType(t))
+	return msg.(string)
+}
+
+// CheckTags reports invalid validate tags in the struct type of v: unknown
+// rules, bad min/max limits or kinds, and conditions on missing fields
+func CheckTags(v any) error {
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil
+	}
+	if msg := checkType(t); msg != "" {
+		return errors.New(msg)
+	}
+	return nil
+}
+
+// checkType returns what is wrong with the tags of struct type t, or ""
+func checkType(t reflect.Type) string {
+	for i := range t.NumField() {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("validate")
+		if tag == "" {
+			continue
+		}
+		for _, rule := range strings.Split(tag, ",") {
+			if msg := checkTag(t, sf.Type, rule); msg != "" {
+				return fmt.Sprintf("request: %s.%s: %s", t.Name(), sf.Name, msg)
+			}
+		}
+	}
+	return ""
+}
+
+// checkTag returns what is wrong with rule, a rule of a field of type ft
+// in struct t, or ""
+func checkTag(t, ft reflect.Type, rule string) string {
+	name, arg, _ := strings.Cut(rule, "=")
+	if base, ok := strings.CutSuffix(name, "_if"); ok {
+		other, _, ok := strings.Cut(arg, ":")
+		if !ok {
+			return "condition " + strconv.Quote(arg) + " must be Field:value"
+		}
+		if _, ok := t.FieldByName(other); !ok {
+			return "condition on unknown field " + strconv.Quote(other)
+		}
+		if base == "required" || base == "min" || base == "max" || base == "oneof" {
+			return "rule " + strconv.Quote(base) + " cannot be conditional"
+		}
+		name, arg = base, ""
+	}
+
+	switch name {
+	case "required", "email", "e164", "digits", "roomname":
+	case "oneof":
+		if len(strings.Fields(arg)) == 0 {
+			return "oneof needs values"
+		}
+	case "min", "max":
+		if _, err := strconv.Atoi(arg); err != nil {
+			return "invalid " + name + " limit " + strconv.Quote(arg)
+		}
+		if _, unit := measureKind(ft.Kind()); unit == "?" {
+			return name + " on unsupported kind " + ft.Kind().String()
+		}
+	default:
+		return "unknown validation rule " + strconv.Quote(name)
+	}
+	return ""
+}
+
+// checkField applies the rules in tag to field, a field of parent, and
+// returns the first failure
+func checkField(parent, field reflect.Value, tag string) string {
+	rules := strings.Split(tag, ",")
+	if field.IsZero() {
+		if slices.Contains(rules, "required") {
+			return "is required"
+		}
+		return ""
+	}
+
+	for _, rule := range rules {
+		name, arg, _ := strings.Cut(rule, "=")
+		if base, ok := strings.CutSuffix(name, "_if"); ok {
+			other, want, _ := strings.Cut(arg, ":")
+			if fmt.Sprint(parent.FieldByName(other).Interface()) != want {
+				continue
+			}
+			name, arg = base, ""
+		}
+		if msg := checkRule(field, name, arg); msg != "" {
+			return msg
+		}
+	}
+	return ""
+}
+
+func checkRule(field reflect.Value, name, arg string) string {
+	s := ""
+	if field.Kind() == reflect.String {
+		s = field.String()
+	}
+
+	switch name {
+	case "required":
+		// Checked by checkField
+	case "oneof":
+		options := strings.Fields(arg)
+		if !slices.Contains(options, s) {
+			return "must be one of: " + strings.Join(options, ", ")
+		}
+	case "min", "max":
+		limit, _ := strconv.Atoi(arg)
+		size, unit := measure(field)
+		if name == "min" && size < limit {
+			return fmt.Sprintf("must be at least %d%s", limit, unit)
+		}
+		if name == "max" && size > limit {
+			return fmt.Sprintf("must be at most %d%s", limit, unit)
+		}
+	case "email":
+		if !validEmail(s) {
+			return "must be a valid email address"
+		}
+	case "e164":
+		if !e164Pattern.MatchString(s) {
+			return "must be a phone number in E.164 format, e.g. +15555550100"
+		}
+	case "digits":
+		if !digitsPattern.MatchString(s) {
+			return "must contain only digits"
+		}
+	case "roomname":
+		if !roomNamePattern.MatchString(s) {
+			return "must be 1-128 letters, digits, '-' or '_'"
+		}
+	}
+	return ""
+}
+
+// measure returns the size min and max compare against, and its unit
+func measure(field reflect.Value) (int, string) {
+	kind, unit := measureKind(field.Kind())
+	switch kind {
+	case reflect.String:
+		return utf8.RuneCountInString(field.String()), unit
+	case reflect.Slice:
+		return field.Len(), unit
+	case reflect.Int:
+		return int(field.Int()), unit
+	case reflect.Uint:
+		return int(field.Uint()), unit
+	}
+	return 0, unit
+}
+
+// measureKind groups the kinds min and max apply to, and gives their unit.
+// The unit is "?" for kinds they do not apply to.
+func measureKind(kind reflect.Kind) (reflect.Kind, string) {
+	switch kind {
+	case reflect.String:
+		return reflect.String, " characters"
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return reflect.Slice, " items"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return reflect.Int, ""
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return reflect.Uint, ""
+	}
+	return kind, "?"
+}
+
+// validEmail accepts a bare address with a dotted domain, such as
+// alice@example.com, but not display names like "Alice <alice@example.com>"
+func validEmail(s string) bool {
+	addr, err := mail.ParseAddress(s)
+	if err != nil || addr.Address != s || addr.Name != "" {
+		return false
+	}
+	_, domain, _ := strings.Cut(s, "@")
+	return strings.Contains(domain, ".") && !strings.HasSuffix(domain, ".")
+}
+
+// jsonName is the name a field has in JSON
+func jsonName(sf reflect.StructField) string {
+	name, _, _ := strings.Cut(sf.Tag.Get("json"), ",")
+	if name == "" || name == "-" {
+		return sf.Name
+	}
+	return name
+}
@@ -0,0 +1,138 @@
+package request
+
+import (
+	"strings"
+	"testing"
+)
+
+type testRequest struct {
+	Name    string   `json:"name" validate:"required,min=2,max=5"`
+	Kind    string   `json:"kind" validate:"oneof=a b"`
+	Email   string   `json:"email" validate:"email"`
+	Phone   string   `json:"phone" validate:"e164"`
+	Code    string   `json:"code" validate:"digits"`
+	Room    string   `json:"room" validate:"roomname"`
+	Tags    []string `json:"tags" validate:"max=2"`
+	Count   int      `json:"count" validate:"min=1,max=10"`
+	Channel string   `json:"channel"`
+	To      string   `json:"to" validate:"email_if=Channel:email,e164_if=Channel:sms"`
+}
+
+func TestValidate(t *testing.T) {
+	valid := func() testRequest { return testRequest{Name: "abc", Count: 1} }
+	tests := []struct {
+		name   string
+		modify func(r *testRequest)
+		field  string // Failing field, "" if valid
+		msg    string
+	}{
+		{"valid", func(r *testRequest) {}, "", ""},
+		{"required", func(r *testRequest) { r.Name = "" }, "name", "is required"},
+		{"min length", func(r *testRequest) { r.Name = "a" }, "name", "must be at least 2 characters"},
+		{"max length", func(r *testRequest) { r.Name = "abcdef" }, "name", "must be at most 5 characters"},
+		{"max counts characters", func(r *testRequest) { r.Name = "ééééé" }, "", ""},
+		{"oneof", func(r *testRequest) { r.Kind = "b" }, "", ""},
+		{"not oneof", func(r *testRequest) { r.Kind = "c" }, "kind", "must be one of: a, b"},
+		{"email", func(r *testRequest) { r.Email = "alice@example.com" }, "", ""},
+		{"email without dot", func(r *testRequest) { r.Email = "alice@localhost" }, "email", "must be a valid email address"},
+		{"email with name", func(r *testRequest) { r.Email = "Alice <alice@example.com>" }, "email", "must be a valid email address"},
+		{"e164", func(r *testRequest) { r.Phone = "+15555550100" }, "", ""},
+		{"e164 without plus", func(r *testRequest) { r.Phone = "15555550100" }, "phone", "must be a phone number in E.164 format, e.g. +15555550100"},
+		{"digits", func(r *testRequest) { r.Code = "012345" }, "", ""},
+		{"not digits", func(r *testRequest) { r.Code = "12a" }, "code", "must contain only digits"},
+		{"roomname", func(r *testRequest) { r.Room = "Team_standup-1" }, "", ""},
+		{"roomname with space", func(r *testRequest) { r.Room = "team standup" }, "room", "must be 1-128 letters, digits, '-' or '_'"},
+		{"roomname too long", func(r *testRequest) { r.Room = strings.Repeat("a", 129) }, "room", "must be 1-128 letters, digits, '-' or '_'"},
+		{"max items", func(r *testRequest) { r.Tags = []string{"a", "b", "c"} }, "tags", "must be at most 2 items"},
+		{"min number", func(r *testRequest) { r.Count = 0 }, "", ""}, // Empty, not required
+		{"max number", func(r *testRequest) { r.Count = 11 }, "count", "must be at most 10"},
+		{"negative number", func(r *testRequest) { r.Count = -1 }, "count", "must be at least 1"},
+		// Conditional rules
+		{"email if email", func(r *testRequest) { r.Channel, r.To = "email", "alice@example.com" }, "", ""},
+		{"email if email, phone given", func(r *testRequest) { r.Channel, r.To = "email", "+15555550100" }, "to", "must be a valid email address"},
+		{"e164 if sms", func(r *testRequest) { r.Channel, r.To = "sms", "+15555550100" }, "", ""},
+		{"e164 if sms, email given", func(r *testRequest) { r.Channel, r.To = "sms", "alice@example.com" }, "to", "must be a phone number in E.164 format, e.g. +15555550100"},
+		{"no condition met", func(r *testRequest) { r.Channel, r.To = "whatsapp", "anything" }, "", ""},
+	}
+	for _, tt := range tests {
+		r := valid()
+		tt.modify(&r)
+		err := Validate(&r)
+		if tt.field == "" {
+			if err != nil {
+				t.Errorf("%s: got %v, want no error", tt.name, err)
+			}
+			continue
+		}
+		if err == nil || len(err.Fields) != 1 || err.Fields[0].Field != tt.field || err.Fields[0].Message != tt.msg {
+			t.Errorf("%s: got %+v, want %s %s", tt.name, err, tt.field, tt.msg)
+		}
+	}
+}
+
+func TestValidateReportsAllFields(t *testing.T) {
+	err := Validate(&testRequest{Kind: "c", Count: 20})
+	if err == nil || len(err.Fields) != 3 {
+		t.Fatalf("got %+v, want 3 fields", err)
+	}
+	want := "Invalid request: name is required; kind must be one of: a, b; count must be at most 10"
+	if err.Message != want {
+		t.Errorf("message %q, want %q", err.Message, want)
+	}
+}
+
+func TestCheckTags(t *testing.T) {
+	tests := []struct {
+		name string
+		v    any
+		want string // Part of the error, "" if valid
+	}{
+		{"valid", testRequest{}, ""},
+		{"pointer", &testRequest{}, ""},
+		{"not a struct", "text", ""},
+		{"unknown rule", struct {
+			A string `validate:"requird"`
+		}{}, `unknown validation rule "requird"`},
+		{"bad limit", struct {
+			A string `validate:"max=ten"`
+		}{}, `invalid max limit "ten"`},
+		{"limit on bool", struct {
+			A bool `validate:"min=1"`
+		}{}, "min on unsupported kind bool"},
+		{"empty oneof", struct {
+			A string `validate:"oneof="`
+		}{}, "oneof needs values"},
+		{"condition without value", struct {
+			A string `validate:"email_if=B"`
+			B string
+		}{}, `condition "B" must be Field:value`},
+		{"condition on unknown field", struct {
+			A string `validate:"email_if=C:x"`
+		}{}, `condition on unknown field "C"`},
+		{"conditional required", struct {
+			A string `validate:"required_if=B:x"`
+			B string
+		}{}, `rule "required" cannot be conditional`},
+	}
+	for _, tt := range tests {
+		err := CheckTags(tt.v)
+		switch {
+		case tt.want == "" && err != nil:
+			t.Errorf("%s: got %v, want no error", tt.name, err)
+		case tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)):
+			t.Errorf("%s: got %v, want %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+// Bad tags panic whatever the values, so tests using the type catch them
+func TestValidatePanicsOnBadTags(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("no panic")
+		}
+	}()
+	Validate(&struct {
+		A string `validate:"requird"`
+	}{})
+}
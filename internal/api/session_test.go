@@ -0,0 +1,144 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kaustavdm/awwdio/config"
+	"github.com/kaustavdm/awwdio/internal/api/auth"
+)
+
+// approveOTP approves every Twilio Verify check
+type approveOTP struct{}
+
+func (approveOTP) RoundTrip(r *http.Request) (*http.Response, error) {
+	if r.Method != http.MethodPost || !strings.HasSuffix(r.URL.Path, "/VerificationCheck") {
+		return &http.Response{StatusCode: http.StatusNotFound, Body: io.NopCloser(strings.NewReader(`{}`)), Request: r}, nil
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(`{"status": "approved"}`)),
+		Request:    r,
+	}, nil
+}
+
+func TestCookieSession(t *testing.T) {
+	_, handler := newTestServer(t, func(cfg *config.Config) {
+		cfg.AuthMode = config.AuthModeCookie
+		cfg.SessionCookieSecure = true
+		cfg.SessionCookieSameSite = "strict"
+		cfg.TwilioVerifyServiceSID = "VA00000000000000000000000000000000"
+	})
+	transport := http.DefaultTransport
+	http.DefaultTransport = approveOTP{}
+	t.Cleanup(func() { http.DefaultTransport = transport })
+
+	// Signing in sets the session and CSRF cookies instead of returning the token
+	req := httptest.NewRequest(http.MethodPost, "/api/auth/verify-otp",
+		strings.NewReader(`{"channel": "email", "to": "alice@example.com", "otp": "123456"}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("verify-otp: status %d: %s", rec.Code, rec.Body)
+	}
+	var verified auth.VerifyOTPResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &verified); err != nil {
+		t.Fatal(err)
+	}
+	if verified.Token != "" || verified.CSRFToken == "" {
+		t.Errorf("verify-otp response = %+v, want a CSRF token and no session token", verified)
+	}
+
+	cookies := map[string]*http.Cookie{}
+	for _, c := range rec.Result().Cookies() {
+		cookies[c.Name] = c
+	}
+	session, csrf := cookies[auth.SessionCookie], cookies[auth.CSRFCookie]
+	if session == nil || csrf == nil {
+		t.Fatalf("cookies = %v, want session and CSRF cookies", rec.Result().Cookies())
+	}
+	if session.Value == "" || !session.HttpOnly || !session.Secure || session.SameSite != http.SameSiteStrictMode ||
+		session.Path != "/api/" || session.MaxAge != int(auth.SessionTTL.Seconds()) {
+		t.Errorf("session cookie = %+v", session)
+	}
+	if csrf.Value != verified.CSRFToken || csrf.HttpOnly || !csrf.Secure || csrf.SameSite != http.SameSiteStrictMode {
+		t.Errorf("CSRF cookie = %+v", csrf)
+	}
+
+	bearer, err := auth.GenerateJWT("bob@example.com", testSecret, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name          string
+		cookie        string
+		authorization string
+		want          int
+	}{
+		{"session cookie", session.Value, "", http.StatusOK},
+		{"bearer token", "", "Bearer " + bearer, http.StatusOK},
+		{"header wins over cookie", session.Value, "Bearer invalid", http.StatusUnauthorized},
+		{"invalid cookie", "invalid", "", http.StatusUnauthorized},
+		{"neither", "", "", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/user/me/calls", nil)
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: auth.SessionCookie, Value: tt.cookie})
+			}
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("status %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+		})
+	}
+
+	// Logging out expires both cookies
+	req = httptest.NewRequest(http.MethodPost, "/api/auth/logout", nil)
+	req.AddCookie(session)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("logout: status %d: %s", rec.Code, rec.Body)
+	}
+	cleared := map[string]*http.Cookie{}
+	for _, c := range rec.Result().Cookies() {
+		cleared[c.Name] = c
+	}
+	for _, name := range []string{auth.SessionCookie, auth.CSRFCookie} {
+		if c := cleared[name]; c == nil || c.MaxAge >= 0 || c.Value != "" {
+			t.Errorf("%s after logout = %+v, want it expired", name, c)
+		}
+	}
+	if c := cleared[auth.SessionCookie]; c != nil && (c.Path != session.Path || !c.HttpOnly) {
+		t.Errorf("cleared session cookie = %+v, want the attributes it was set with", c)
+	}
+}
+
+func TestSessionCookieIgnoredInBearerMode(t *testing.T) {
+	_, handler := newTestServer(t)
+	token, err := auth.GenerateJWT("alice@example.com", testSecret, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/user/me/calls", nil)
+	req.AddCookie(&http.Cookie{Name: auth.SessionCookie, Value: token})
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("status %d, want 401: %s", rec.Code, rec.Body)
+	}
+}
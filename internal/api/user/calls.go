@@ -0,0 +1,212 @@
+package user
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kaustavdm/awwdio/apitypes"
+	"github.com/kaustavdm/awwdio/internal/api/apierror"
+	"github.com/kaustavdm/awwdio/internal/api/middleware"
+	"github.com/kaustavdm/awwdio/internal/api/video"
+)
+
+const (
+	defaultCallsLimit = 20
+	maxCallsLimit     = 100
+)
+
+type (
+	CallHistoryEntry    = apitypes.CallHistoryEntry
+	CallRecordingEntry  = apitypes.CallRecordingEntry
+	CallHistoryResponse = apitypes.CallHistoryResponse
+)
+
+// myCallsHandler lists the calls the user took part in, newest first.
+//
+// Query parameters:
+//   - from, to: only calls joined in this range (RFC 3339 or YYYY-MM-DD, to is exclusive)
+//   - limit: page size, 1-100 (default 20)
+//   - cursor: next_cursor from the previous page
+func (h *Handler) myCallsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	user := middleware.GetUser(r)
+	if user == nil {
+		apierror.Write(w, r, apierror.Unauthorized("Authentication required"))
+		return
+	}
+
+	query := r.URL.Query()
+	from, err := parseDateParam(query.Get("from"))
+	if err != nil {
+		apierror.Write(w, r, apierror.BadRequest("Invalid from date"))
+		return
+	}
+	to, err := parseDateParam(query.Get("to"))
+	if err != nil {
+		apierror.Write(w, r, apierror.BadRequest("Invalid to date"))
+		return
+	}
+
+	limit := defaultCallsLimit
+	if l := query.Get("limit"); l != "" {
+		limit, err = strconv.Atoi(l)
+		if err != nil || limit < 1 || limit > maxCallsLimit {
+			apierror.Write(w, r, apierror.BadRequest("Limit must be between 1 and 100"))
+			return
+		}
+	}
+
+	var after *callCursor
+	if c := query.Get("cursor"); c != "" {
+		cur, err := decodeCursor(c)
+		if err != nil {
+			apierror.Write(w, r, apierror.BadRequest("Invalid cursor"))
+			return
+		}
+		after = &cur
+	}
+
+	var entries []CallHistoryEntry
+	for _, room := range h.rooms.Rooms() {
+		entry, ok := h.historyEntry(room, user.Subject)
+		if !ok {
+			continue
+		}
+		if (!from.IsZero() && entry.JoinedAt.Before(from)) || (!to.IsZero() && !entry.JoinedAt.Before(to)) {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	// Newest first, with the room SID breaking ties so the order is stable across pages
+	sort.Slice(entries, func(i, j int) bool {
+		return cursorOf(entries[i]).before(cursorOf(entries[j]))
+	})
+
+	if after != nil {
+		start := sort.Search(len(entries), func(i int) bool {
+			return after.before(cursorOf(entries[i]))
+		})
+		entries = entries[start:]
+	}
+
+	resp := CallHistoryResponse{Calls: []CallHistoryEntry{}}
+	if len(entries) > limit {
+		entries = entries[:limit]
+		resp.NextCursor = cursorOf(entries[limit-1]).encode()
+	}
+	resp.Calls = append(resp.Calls, entries...)
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// historyEntry summarizes a room from the point of view of identity,
+// returning false if identity never joined it
+func (h *Handler) historyEntry(room video.Room, identity string) (CallHistoryEntry, bool) {
+	entry := CallHistoryEntry{
+		RoomSid:      room.Sid,
+		RoomName:     room.Name,
+		Status:       room.Status,
+		StartedAt:    room.CreatedAt,
+		EndedAt:      room.EndedAt,
+		Participants: []string{},
+		Recordings:   []CallRecordingEntry{},
+	}
+
+	joined := false
+	for _, p := range room.Participants {
+		if p.Identity != identity {
+			if !slices.Contains(entry.Participants, p.Identity) {
+				entry.Participants = append(entry.Participants, p.Identity)
+			}
+			continue
+		}
+		if !joined || p.JoinedAt.Before(entry.JoinedAt) {
+			entry.JoinedAt = p.JoinedAt
+		}
+		if p.LeftAt.After(entry.LeftAt) {
+			entry.LeftAt = p.LeftAt
+		}
+		entry.Duration += p.Duration
+		joined = true
+	}
+	if !joined {
+		return CallHistoryEntry{}, false
+	}
+
+	if room.CompositionSid != "" {
+		if comp, ok := h.rooms.Composition(room.CompositionSid); ok {
+			rec := CallRecordingEntry{
+				CompositionSid: comp.Sid,
+				Status:         comp.Status,
+				Duration:       comp.Duration,
+			}
+			if comp.Status == "completed" {
+				rec.MediaURL = "/api/video/compositions/" + comp.Sid + "/media"
+			}
+			entry.Recordings = append(entry.Recordings, rec)
+		}
+	}
+	return entry, true
+}
+
+// parseDateParam accepts RFC 3339 timestamps and plain dates (midnight UTC).
+// An empty value returns the zero time.
+func parseDateParam(v string) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.DateOnly, v)
+}
+
+// callCursor is the position of a call in the newest-first listing
+type callCursor struct {
+	joinedAt time.Time
+	roomSid  string
+}
+
+func cursorOf(e CallHistoryEntry) callCursor {
+	return callCursor{joinedAt: e.JoinedAt, roomSid: e.RoomSid}
+}
+
+// before reports whether c sorts before other in the listing
+func (c callCursor) before(other callCursor) bool {
+	if !c.joinedAt.Equal(other.joinedAt) {
+		return c.joinedAt.After(other.joinedAt)
+	}
+	return c.roomSid < other.roomSid
+}
+
+// encode returns an opaque cursor string
+func (c callCursor) encode() string {
+	raw := strconv.FormatInt(c.joinedAt.UnixNano(), 10) + "|" + c.roomSid
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeCursor(s string) (callCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return callCursor{}, err
+	}
+	nanos, sid, ok := strings.Cut(string(raw), "|")
+	if !ok {
+		return callCursor{}, fmt.Errorf("malformed cursor")
+	}
+	n, err := strconv.ParseInt(nanos, 10, 64)
+	if err != nil {
+		return callCursor{}, err
+	}
+	return callCursor{joinedAt: time.Unix(0, n), roomSid: sid}, nil
+}
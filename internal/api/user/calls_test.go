@@ -0,0 +1,153 @@
+package user
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/kaustavdm/awwdio/internal/api/middleware"
+	"github.com/kaustavdm/awwdio/internal/api/video"
+)
+
+// fakeRooms is a RoomSource over fixed rooms
+type fakeRooms struct {
+	rooms        []video.Room
+	compositions map[string]video.Composition
+}
+
+func (f fakeRooms) Rooms() []video.Room { return f.rooms }
+
+func (f fakeRooms) Composition(sid string) (video.Composition, bool) {
+	c, ok := f.compositions[sid]
+	return c, ok
+}
+
+func day(d int) time.Time { return time.Date(2025, 1, d, 12, 0, 0, 0, time.UTC) }
+
+// testRooms has alice in rooms RM1-RM5, two of them joined at the same
+// time, and not in RM6
+func testRooms() fakeRooms {
+	alice := func(joined time.Time) []video.Participant {
+		return []video.Participant{
+			{Sid: "PA1", Identity: "alice@example.com", JoinedAt: joined, LeftAt: joined.Add(time.Minute), Duration: 60},
+			{Sid: "PA2", Identity: "bob@example.com", JoinedAt: joined},
+		}
+	}
+	return fakeRooms{
+		rooms: []video.Room{
+			{Sid: "RM1", Name: "one", Participants: alice(day(1))},
+			{Sid: "RM3", Name: "three", Participants: alice(day(3))},
+			{Sid: "RM2", Name: "two", Participants: alice(day(3))},
+			{Sid: "RM4", Name: "four", Participants: alice(day(4)), CompositionSid: "CJ4"},
+			{Sid: "RM5", Name: "five", Participants: alice(day(5))},
+			{Sid: "RM6", Name: "six", Participants: []video.Participant{{Sid: "PA3", Identity: "bob@example.com", JoinedAt: day(6)}}},
+		},
+		compositions: map[string]video.Composition{"CJ4": {Sid: "CJ4", Status: "completed", Duration: 55}},
+	}
+}
+
+func listCalls(t *testing.T, h *Handler, query url.Values) (int, CallHistoryResponse) {
+	t.Helper()
+	req := httptest.NewRequest("GET", "/me/calls?"+query.Encode(), nil)
+	req = req.WithContext(context.WithValue(req.Context(), middleware.UserContextKey, &middleware.UserClaims{Subject: "alice@example.com"}))
+	rec := httptest.NewRecorder()
+	h.myCallsHandler(rec, req)
+	var resp CallHistoryResponse
+	if rec.Code == http.StatusOK {
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return rec.Code, resp
+}
+
+func sids(calls []CallHistoryEntry) string {
+	var s []string
+	for _, c := range calls {
+		s = append(s, c.RoomSid)
+	}
+	return fmt.Sprint(s)
+}
+
+// Paging visits every call once, newest first, with ties broken by room SID
+func TestCallHistoryPaging(t *testing.T) {
+	h := NewHandler(testRooms())
+
+	var pages []string
+	query := url.Values{"limit": {"2"}}
+	for {
+		code, resp := listCalls(t, h, query)
+		if code != http.StatusOK {
+			t.Fatalf("status %d", code)
+		}
+		pages = append(pages, sids(resp.Calls))
+		if resp.NextCursor == "" {
+			break
+		}
+		query.Set("cursor", resp.NextCursor)
+	}
+	// The last page is full, but there is no more after it
+	want := []string{"[RM5 RM4]", "[RM2 RM3]", "[RM1]"}
+	if fmt.Sprint(pages) != fmt.Sprint(want) {
+		t.Errorf("pages %v, want %v", pages, want)
+	}
+
+	_, resp := listCalls(t, h, url.Values{"limit": {"5"}})
+	if resp.NextCursor != "" || len(resp.Calls) != 5 {
+		t.Errorf("exactly one page: %d calls, next cursor %q", len(resp.Calls), resp.NextCursor)
+	}
+	rm4 := resp.Calls[1]
+	if rm4.Duration != 60 || fmt.Sprint(rm4.Participants) != "[bob@example.com]" ||
+		len(rm4.Recordings) != 1 || rm4.Recordings[0].MediaURL != "/api/video/compositions/CJ4/media" {
+		t.Errorf("entry %+v", rm4)
+	}
+}
+
+func TestCallHistoryQuery(t *testing.T) {
+	h := NewHandler(testRooms())
+	tests := []struct {
+		name  string
+		query url.Values
+		code  int
+		want  string
+	}{
+		{"all", url.Values{}, http.StatusOK, "[RM5 RM4 RM2 RM3 RM1]"},
+		{"from date", url.Values{"from": {"2025-01-03"}}, http.StatusOK, "[RM5 RM4 RM2 RM3]"},
+		{"to is exclusive", url.Values{"to": {day(3).Format(time.RFC3339)}}, http.StatusOK, "[RM1]"},
+		{"range", url.Values{"from": {"2025-01-02"}, "to": {"2025-01-05"}}, http.StatusOK, "[RM4 RM2 RM3]"},
+		{"bad from", url.Values{"from": {"yesterday"}}, http.StatusBadRequest, ""},
+		{"bad to", url.Values{"to": {"2025-13-01"}}, http.StatusBadRequest, ""},
+		{"limit too small", url.Values{"limit": {"0"}}, http.StatusBadRequest, ""},
+		{"limit too large", url.Values{"limit": {"101"}}, http.StatusBadRequest, ""},
+		{"bad cursor encoding", url.Values{"cursor": {"!!"}}, http.StatusBadRequest, ""},
+		{"bad cursor content", url.Values{"cursor": {"bm90LWEtY3Vyc29y"}}, http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		code, resp := listCalls(t, h, tt.query)
+		if code != tt.code {
+			t.Errorf("%s: status %d, want %d", tt.name, code, tt.code)
+			continue
+		}
+		if tt.want != "" && sids(resp.Calls) != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.name, sids(resp.Calls), tt.want)
+		}
+	}
+}
+
+func TestDecodeCursor(t *testing.T) {
+	c := callCursor{joinedAt: day(3), roomSid: "RM2"}
+	got, err := decodeCursor(c.encode())
+	if err != nil || !got.joinedAt.Equal(c.joinedAt) || got.roomSid != c.roomSid {
+		t.Errorf("round trip: %+v, %v", got, err)
+	}
+	for _, bad := range []string{"!!", "bm8tc2VwYXJhdG9y", "eHxSTTE"} { // "no-separator", "x|RM1"
+		if _, err := decodeCursor(bad); err == nil {
+			t.Errorf("decodeCursor(%q) succeeded", bad)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package user
+
+import (
+	"github.com/kaustavdm/awwdio/internal/api/openapi"
+	"github.com/kaustavdm/awwdio/internal/api/video"
+)
+
+// RoomSource provides the rooms and compositions that call history is built from
+type RoomSource interface {
+	Rooms() []video.Room
+	Composition(sid string) (video.Composition, bool)
+}
+
+type Handler struct {
+	rooms RoomSource
+}
+
+func NewHandler(rooms RoomSource) *Handler {
+	return &Handler{rooms: rooms}
+}
+
+func (h *Handler) Register(mux *openapi.Mux) {
+	mux.HandleFunc("GET /me/calls", h.myCallsHandler, openapi.Operation{
+		ID:       "listMyCalls",
+		Summary:  "List the calls the user took part in, newest first",
+		Response: CallHistoryResponse{},
+		Query: []openapi.Param{
+			{Name: "from", Description: "Only calls joined at or after this time (RFC 3339 or YYYY-MM-DD)"},
+			{Name: "to", Description: "Only calls joined before this time (RFC 3339 or YYYY-MM-DD)"},
+			{Name: "limit", Description: "Page size, 1-100 (default 20)", Type: "integer"},
+			{Name: "cursor", Description: "next_cursor from the previous page"},
+		},
+	})
+}
@@ -2,14 +2,25 @@ package video
 
 import (
 	"encoding/json"
-	"log/slog"
 	"net/http"
 
+	"github.com/kaustavdm/awwdio/apitypes"
 	"github.com/kaustavdm/awwdio/config"
+	"github.com/kaustavdm/awwdio/internal/api/apierror"
 	"github.com/kaustavdm/awwdio/internal/api/middleware"
+	"github.com/kaustavdm/awwdio/internal/api/request"
+	"github.com/kaustavdm/awwdio/internal/audit"
+	"github.com/kaustavdm/awwdio/internal/logging"
+	"github.com/kaustavdm/awwdio/internal/metrics"
 	"github.com/twilio/twilio-go/client/jwt"
 )
 
+// tokensMinted has no room label: room names are chosen by clients, so it
+// would grow without bound. Per-room counts are in the audit log, where
+// video.token entries target the room.
+var tokensMinted = metrics.NewCounter("awwdio_video_tokens_minted_total",
+	"Video access tokens issued.")
+
 // accessToken generates a Twilio access token with Video grant for a given user identity and room name.
 func accessToken(c *config.Config, identity, roomName string) (string, error) {
 	params := jwt.AccessTokenParams{
@@ -26,56 +37,72 @@ func accessToken(c *config.Config, identity, roomName string) (string, error) {
 	return token.ToJwt()
 }
 
-type TokenRequest struct {
-	Room string `json:"room"`
-}
-
-type TokenResponse struct {
-	Token string `json:"token"`
-}
-
-type ErrorResponse struct {
-	Error string `json:"error"`
-}
+type (
+	TokenRequest  = apitypes.TokenRequest
+	TokenResponse = apitypes.TokenResponse
+)
 
 // tokenHandler handles the token generation
 func (h *Handler) tokenHandler(w http.ResponseWriter, r *http.Request) {
+	log := logging.FromContext(r.Context())
+	cfg := h.config.Get()
 	w.Header().Set("Content-Type", "application/json")
 
 	// Get authenticated user from context
 	user := middleware.GetUser(r)
 	if user == nil {
-		slog.Error("No user in context")
-		w.WriteHeader(http.StatusUnauthorized)
-		json.NewEncoder(w).Encode(ErrorResponse{Error: "Authentication required"})
+		log.Error("No user in context")
+		apierror.Write(w, r, apierror.Unauthorized("Authentication required"))
 		return
 	}
 
 	// Parse request body for room name
 	var req TokenRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		slog.Error("Failed to decode request", "error", err)
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(ErrorResponse{Error: "Invalid request body"})
+	if err := request.Decode(w, r, &req); err != nil {
+		log.Debug("Invalid request body", "error", err)
+		apierror.Write(w, r, err)
+		return
+	}
+	if err := request.Validate(&req); err != nil {
+		apierror.Write(w, r, err)
 		return
 	}
 
-	if req.Room == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(ErrorResponse{Error: "Room name is required"})
+	// Let other modules restrict access to rooms they own
+	if err := h.checkGuards(user.Subject, req.Room); err != nil {
+		log.Warn("Room access denied", "identity", user.Subject, "room", req.Room, "reason", err)
+		h.audit.Record(r, audit.Entry{Actor: user.Actor(), Action: audit.ActionTokenIssued, Target: req.Room,
+			Outcome: audit.OutcomeDenied, Details: user.AuditDetails(map[string]string{"reason": err.Error()})})
+		apierror.Write(w, r, apierror.Forbidden(err.Error()))
 		return
 	}
 
+	// Create the room up front so Twilio reports its events back to us
+	if cfg.PublicURL != "" {
+		created, err := h.ensureRoom(r.Context(), cfg, req.Room)
+		if err != nil {
+			log.Error("Failed to create room", "error", err, "room", req.Room)
+			apierror.Write(w, r, apierror.Upstream("Failed to create room"))
+			return
+		}
+		if created {
+			h.audit.Record(r, audit.Entry{Actor: user.Actor(), Action: audit.ActionRoomCreated, Target: req.Room,
+				Outcome: audit.OutcomeSuccess, Details: user.AuditDetails(nil)})
+		}
+	}
+
 	// Generate token using authenticated user identity
-	token, err := accessToken(h.config, user.Subject, req.Room)
+	token, err := accessToken(cfg, user.Subject, req.Room)
 	if err != nil {
-		slog.Error("Failed to generate access token", "error", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(ErrorResponse{Error: "Failed to generate token"})
+		log.Error("Failed to generate access token", "error", err)
+		apierror.Write(w, r, apierror.Internal("Failed to generate token"))
 		return
 	}
 
-	slog.Info("Generated video token", "identity", user.Subject, "room", req.Room)
+	log.Info("Generated video token", "identity", user.Subject, "room", req.Room)
+	tokensMinted.Inc()
+	h.audit.Record(r, audit.Entry{Actor: user.Actor(), Action: audit.ActionTokenIssued, Target: req.Room,
+		Outcome: audit.OutcomeSuccess, Details: user.AuditDetails(nil)})
 
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(TokenResponse{Token: token})
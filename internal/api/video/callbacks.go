@@ -0,0 +1,160 @@
+package video
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/kaustavdm/awwdio/internal/api/webhooks"
+	"github.com/kaustavdm/awwdio/internal/logging"
+	"github.com/twilio/twilio-go/client"
+)
+
+// callbackPath is where RegisterCallbacks is mounted on the public server
+const callbackPath = "/api/video/callbacks"
+
+// Room is a Twilio room as observed through its status callbacks
+type Room struct {
+	Sid            string        `json:"sid"`
+	Name           string        `json:"name"`
+	Status         string        `json:"status"` // "in-progress" or "completed"
+	CreatedAt      time.Time     `json:"created_at"`
+	EndedAt        time.Time     `json:"ended_at,omitzero"`
+	Duration       int           `json:"duration,omitempty"` // seconds
+	Participants   []Participant `json:"participants"`
+	CompositionSid string        `json:"composition_sid,omitempty"`
+}
+
+// Participant is one connection of an identity to a room. An identity that
+// reconnects appears once per connection.
+type Participant struct {
+	Sid      string    `json:"sid"`
+	Identity string    `json:"identity"`
+	JoinedAt time.Time `json:"joined_at"`
+	LeftAt   time.Time `json:"left_at,omitzero"`
+	Duration int       `json:"duration,omitempty"` // seconds
+}
+
+// HasParticipant reports whether identity ever connected to the room
+func (r Room) HasParticipant(identity string) bool {
+	for _, p := range r.Participants {
+		if p.Identity == identity {
+			return true
+		}
+	}
+	return false
+}
+
+// validateTwilioSignature rejects callbacks that are not signed by Twilio.
+// Without an auth token no signature can be checked, so every callback is
+// rejected: unsigned events could fake room participants.
+func (h *Handler) validateTwilioSignature(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		log := logging.FromContext(r.Context())
+		cfg := h.config.Get()
+		if cfg.TwilioAuthToken == "" {
+			log.Warn("Rejecting Twilio callback, TWILIO_AUTH_TOKEN not set", "path", r.URL.Path)
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		if err := r.ParseForm(); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		params := make(map[string]string, len(r.PostForm))
+		for k, v := range r.PostForm {
+			params[k] = v[0]
+		}
+
+		// RequestURI is the path before any StripPrefix, which is what Twilio signed
+		validator := client.NewRequestValidator(cfg.TwilioAuthToken)
+		if !validator.Validate(cfg.PublicURL+r.RequestURI, params, r.Header.Get("X-Twilio-Signature")) {
+			log.Warn("Invalid Twilio signature", "path", r.URL.Path)
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// roomStatusCallback records room and participant events sent by Twilio
+func (h *Handler) roomStatusCallback(w http.ResponseWriter, r *http.Request) {
+	log := logging.FromContext(r.Context())
+	if err := r.ParseForm(); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	event := r.PostForm.Get("StatusCallbackEvent")
+	roomSid := r.PostForm.Get("RoomSid")
+	if roomSid == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	ts, err := time.Parse(time.RFC3339, r.PostForm.Get("Timestamp"))
+	if err != nil {
+		ts = time.Now().UTC()
+	}
+
+	log.Debug("Room status callback", "event", event, "room_sid", roomSid)
+
+	room, err := h.rooms.Update(roomSid, func(room Room, exists bool) (Room, error) {
+		if !exists {
+			room = Room{
+				Sid:       roomSid,
+				Name:      r.PostForm.Get("RoomName"),
+				Status:    "in-progress",
+				CreatedAt: ts,
+			}
+		}
+
+		switch event {
+		case "room-ended":
+			room.Status = "completed"
+			room.EndedAt = ts
+			room.Duration, _ = strconv.Atoi(r.PostForm.Get("RoomDuration"))
+		case "participant-connected":
+			room.Participants = append(room.Participants, Participant{
+				Sid:      r.PostForm.Get("ParticipantSid"),
+				Identity: r.PostForm.Get("ParticipantIdentity"),
+				JoinedAt: ts,
+			})
+		case "participant-disconnected":
+			sid := r.PostForm.Get("ParticipantSid")
+			for i := range room.Participants {
+				if room.Participants[i].Sid == sid {
+					room.Participants[i].LeftAt = ts
+					room.Participants[i].Duration, _ = strconv.Atoi(r.PostForm.Get("ParticipantDuration"))
+				}
+			}
+		}
+		return room, nil
+	})
+	if err != nil {
+		log.Error("Failed to store room event", "error", err, "room_sid", roomSid)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	switch event {
+	case "room-created":
+		h.webhooks.Emit(webhooks.EventRoomStarted, map[string]any{
+			"room_sid":  room.Sid,
+			"room_name": room.Name,
+		})
+	case "participant-connected":
+		h.webhooks.Emit(webhooks.EventParticipantJoined, map[string]any{
+			"room_sid":  room.Sid,
+			"room_name": room.Name,
+			"identity":  r.PostForm.Get("ParticipantIdentity"),
+		})
+	case "room-ended":
+		if cfg := h.config.Get(); cfg.RecordCalls {
+			h.createComposition(r.Context(), cfg, room)
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,221 @@
+package video
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/kaustavdm/awwdio/apitypes"
+	"github.com/kaustavdm/awwdio/config"
+	"github.com/kaustavdm/awwdio/internal/api/apierror"
+	"github.com/kaustavdm/awwdio/internal/api/middleware"
+	"github.com/kaustavdm/awwdio/internal/api/webhooks"
+	"github.com/kaustavdm/awwdio/internal/audit"
+	"github.com/kaustavdm/awwdio/internal/logging"
+	"github.com/kaustavdm/awwdio/internal/twilioclient"
+	openapi "github.com/twilio/twilio-go/rest/video/v1"
+)
+
+// compositionMediaTTL is how long a media download link stays valid, in seconds
+const compositionMediaTTL = 3600
+
+// Composition is a mixed audio file produced from a room's recordings
+type Composition = apitypes.Composition
+
+// createComposition asks Twilio to mix all audio tracks of a completed room
+// into a single file. Failures are logged, as there is no caller to report to.
+func (h *Handler) createComposition(ctx context.Context, cfg *config.Config, room Room) {
+	log := logging.FromContext(ctx)
+	params := &openapi.CreateCompositionParams{}
+	params.SetRoomSid(room.Sid)
+	params.SetAudioSources([]string{"*"})
+	params.SetFormat("mp4")
+	params.SetTrim(true)
+	params.SetStatusCallback(cfg.PublicURL + callbackPath + "/composition-status")
+	params.SetStatusCallbackMethod("POST")
+
+	resp, err := twilioclient.New(ctx, cfg).VideoV1.CreateComposition(params)
+	if err != nil {
+		log.Error("Failed to create composition", "error", err, "room_sid", room.Sid)
+		return
+	}
+
+	comp := Composition{
+		Sid:       *resp.Sid,
+		RoomSid:   room.Sid,
+		RoomName:  room.Name,
+		Status:    "enqueued",
+		Format:    "mp4",
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := h.compositions.Put(comp.Sid, comp); err != nil {
+		log.Error("Failed to store composition", "error", err, "composition_sid", comp.Sid)
+		return
+	}
+	if _, err := h.rooms.Update(room.Sid, func(r Room, _ bool) (Room, error) {
+		r.CompositionSid = comp.Sid
+		return r, nil
+	}); err != nil {
+		log.Error("Failed to link composition to room", "error", err, "room_sid", room.Sid)
+	}
+
+	log.Info("Composition created", "composition_sid", comp.Sid, "room", room.Name)
+}
+
+// compositionStatusCallback tracks composition progress reported by Twilio
+func (h *Handler) compositionStatusCallback(w http.ResponseWriter, r *http.Request) {
+	log := logging.FromContext(r.Context())
+	if err := r.ParseForm(); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	sid := r.PostForm.Get("CompositionSid")
+	event := r.PostForm.Get("StatusCallbackEvent")
+	if sid == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	log.Debug("Composition status callback", "event", event, "composition_sid", sid)
+
+	comp, err := h.compositions.Update(sid, func(c Composition, exists bool) (Composition, error) {
+		if !exists {
+			c = Composition{Sid: sid, RoomSid: r.PostForm.Get("RoomSid"), Format: "mp4", CreatedAt: time.Now().UTC()}
+		}
+		switch event {
+		case "composition-started", "composition-progress":
+			c.Status = "processing"
+		case "composition-available":
+			c.Status = "completed"
+			c.CompletedAt = time.Now().UTC()
+			c.Duration, _ = strconv.Atoi(r.PostForm.Get("Duration"))
+			c.Size, _ = strconv.ParseInt(r.PostForm.Get("Size"), 10, 64)
+		case "composition-failed":
+			c.Status = "failed"
+		case "composition-deleted":
+			c.Status = "deleted"
+		}
+		return c, nil
+	})
+	if err != nil {
+		log.Error("Failed to store composition status", "error", err, "composition_sid", sid)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if event == "composition-available" {
+		log.Info("Composition available", "composition_sid", sid)
+		h.webhooks.Emit(webhooks.EventRecordingReady, map[string]any{
+			"composition_sid": comp.Sid,
+			"room_sid":        comp.RoomSid,
+			"room_name":       comp.RoomName,
+			"format":          comp.Format,
+			"duration":        comp.Duration,
+			"size":            comp.Size,
+		})
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// authorizedComposition loads a composition the current user may access,
+// writing an error response and returning false otherwise.
+// Only participants of the room a composition was made from can access it.
+func (h *Handler) authorizedComposition(w http.ResponseWriter, r *http.Request) (Composition, bool) {
+	user := middleware.GetUser(r)
+	if user == nil {
+		apierror.Write(w, r, apierror.Unauthorized("Authentication required"))
+		return Composition{}, false
+	}
+
+	comp, ok := h.compositions.Get(r.PathValue("sid"))
+	if ok {
+		room, found := h.rooms.Get(comp.RoomSid)
+		ok = found && room.HasParticipant(user.Subject)
+	}
+	if !ok {
+		h.audit.Record(r, audit.Entry{Actor: user.Actor(), Action: audit.ActionRecordingAccess, Target: r.PathValue("sid"),
+			Outcome: audit.OutcomeDenied, Details: user.AuditDetails(nil)})
+		apierror.Write(w, r, apierror.NotFound("Composition not found"))
+		return Composition{}, false
+	}
+	return comp, true
+}
+
+// getComposition returns the status of a composition
+func (h *Handler) getComposition(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	comp, ok := h.authorizedComposition(w, r)
+	if !ok {
+		return
+	}
+
+	user := middleware.GetUser(r)
+	h.audit.Record(r, audit.Entry{Actor: user.Actor(), Action: audit.ActionRecordingAccess, Target: comp.Sid,
+		Outcome: audit.OutcomeSuccess, Details: user.AuditDetails(map[string]string{"access": "metadata"})})
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(comp)
+}
+
+// compositionMedia redirects to a short-lived download URL for a completed composition
+func (h *Handler) compositionMedia(w http.ResponseWriter, r *http.Request) {
+	log := logging.FromContext(r.Context())
+	w.Header().Set("Content-Type", "application/json")
+
+	comp, ok := h.authorizedComposition(w, r)
+	if !ok {
+		return
+	}
+
+	if comp.Status != "completed" {
+		apierror.Write(w, r, apierror.Conflict("Composition is not ready"))
+		return
+	}
+
+	mediaURL, err := h.compositionMediaURL(r.Context(), h.config.Get(), comp.Sid)
+	if err != nil {
+		log.Error("Failed to fetch composition media", "error", err, "composition_sid", comp.Sid)
+		apierror.Write(w, r, apierror.Upstream("Failed to fetch composition media"))
+		return
+	}
+
+	user := middleware.GetUser(r)
+	log.Info("Composition downloaded", "composition_sid", comp.Sid, "identity", user.Subject)
+	h.audit.Record(r, audit.Entry{Actor: user.Actor(), Action: audit.ActionRecordingAccess, Target: comp.Sid,
+		Outcome: audit.OutcomeSuccess, Details: user.AuditDetails(map[string]string{"access": "media"})})
+
+	http.Redirect(w, r, mediaURL, http.StatusFound)
+}
+
+// compositionMediaURL asks Twilio for a pre-signed URL of the composition media.
+// twilio-go has no wrapper for the Media subresource, so it is called directly.
+func (h *Handler) compositionMediaURL(ctx context.Context, cfg *config.Config, sid string) (string, error) {
+	query := url.Values{}
+	query.Set("Ttl", strconv.Itoa(compositionMediaTTL))
+
+	resp, err := twilioclient.New(ctx, cfg).Get("https://video.twilio.com/v1/Compositions/"+sid+"/Media", query, nil)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var media struct {
+		RedirectTo string `json:"redirect_to"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&media); err != nil {
+		return "", fmt.Errorf("failed to decode media response: %w", err)
+	}
+	if media.RedirectTo == "" {
+		media.RedirectTo = resp.Header.Get("Location")
+	}
+	if media.RedirectTo == "" {
+		return "", fmt.Errorf("media response has no redirect_to")
+	}
+	return media.RedirectTo, nil
+}
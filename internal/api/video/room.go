@@ -1,22 +1,47 @@
 package video
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
+
+	"github.com/kaustavdm/awwdio/apitypes"
+	"github.com/kaustavdm/awwdio/config"
+	"github.com/kaustavdm/awwdio/internal/api/apierror"
+	"github.com/kaustavdm/awwdio/internal/api/middleware"
+	"github.com/kaustavdm/awwdio/internal/api/request"
+	"github.com/kaustavdm/awwdio/internal/audit"
+	"github.com/kaustavdm/awwdio/internal/logging"
+	"github.com/kaustavdm/awwdio/internal/twilioclient"
+	"github.com/twilio/twilio-go/client"
+	openapi "github.com/twilio/twilio-go/rest/video/v1"
 )
 
+// errRoomExists is the Twilio error code for a duplicate room unique name
+const errRoomExists = 53113
+
+// TwilioRoom is a room as Twilio's REST API describes it
+type TwilioRoom = openapi.VideoV1Room
+
+type CreateRoomRequest = apitypes.CreateRoomRequest
+
+type ListRoomsResponse struct {
+	Rooms []TwilioRoom `json:"rooms"`
+}
+
 func (h *Handler) getRoom(w http.ResponseWriter, r *http.Request) {
 	// Extract room name from URL
 	roomName := r.URL.Query().Get("roomName")
 	if roomName == "" {
-		http.Error(w, "Room name is required", http.StatusBadRequest)
+		apierror.Write(w, r, apierror.BadRequest("Room name is required"))
 		return
 	}
 
 	// Fetch room details using Twilio API
-	room, err := h.twilioClient.VideoV1.FetchRoom(roomName)
+	room, err := twilioclient.New(r.Context(), h.config.Get()).VideoV1.FetchRoom(roomName)
 	if err != nil {
-		http.Error(w, "Failed to fetch room details", http.StatusInternalServerError)
+		apierror.Write(w, r, apierror.Upstream("Failed to fetch room details"))
 		return
 	}
 
@@ -25,3 +50,164 @@ func (h *Handler) getRoom(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(room)
 }
+
+// ensureRoom creates the room with status callbacks, and recording when
+// enabled, unless it is already in progress. Rooms created implicitly by
+// clients connecting would have neither. It reports whether it created the room.
+func (h *Handler) ensureRoom(ctx context.Context, cfg *config.Config, name string) (bool, error) {
+	log := logging.FromContext(ctx)
+	twilio := twilioclient.New(ctx, cfg)
+
+	listParams := &openapi.ListRoomParams{}
+	listParams.SetUniqueName(name)
+	listParams.SetStatus("in-progress")
+	listParams.SetLimit(1)
+	rooms, err := twilio.VideoV1.ListRoom(listParams)
+	if err != nil {
+		return false, err
+	}
+	if len(rooms) > 0 {
+		return false, nil
+	}
+
+	params := &openapi.CreateRoomParams{}
+	params.SetUniqueName(name)
+	params.SetType("group")
+	// Without a public URL Twilio cannot reach the callbacks
+	if cfg.PublicURL != "" {
+		params.SetStatusCallback(cfg.PublicURL + callbackPath + "/room-status")
+		params.SetStatusCallbackMethod("POST")
+	}
+	params.SetRecordParticipantsOnConnect(cfg.RecordCalls)
+
+	room, err := twilio.VideoV1.CreateRoom(params)
+	if err != nil {
+		// Another request may have created the room since we checked
+		var restErr *client.TwilioRestError
+		if errors.As(err, &restErr) && restErr.Code == errRoomExists {
+			return false, nil
+		}
+		return false, err
+	}
+
+	log.Info("Room created", "room", name, "room_sid", *room.Sid, "recording", cfg.RecordCalls)
+	return true, nil
+}
+
+// createRoom creates a room ahead of its participants joining. Creating a
+// room that is already in progress is not an error.
+func (h *Handler) createRoom(w http.ResponseWriter, r *http.Request) {
+	log := logging.FromContext(r.Context())
+	cfg := h.config.Get()
+	w.Header().Set("Content-Type", "application/json")
+
+	user := middleware.GetUser(r)
+	if user == nil {
+		apierror.Write(w, r, apierror.Unauthorized("Authentication required"))
+		return
+	}
+
+	var req CreateRoomRequest
+	if err := request.Decode(w, r, &req); err != nil {
+		log.Debug("Invalid request body", "error", err)
+		apierror.Write(w, r, err)
+		return
+	}
+	if err := request.Validate(&req); err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	if err := h.checkGuards(user.Subject, req.Name); err != nil {
+		log.Warn("Room access denied", "identity", user.Subject, "room", req.Name, "reason", err)
+		h.audit.Record(r, audit.Entry{Actor: user.Actor(), Action: audit.ActionRoomCreated, Target: req.Name,
+			Outcome: audit.OutcomeDenied, Details: user.AuditDetails(map[string]string{"reason": err.Error()})})
+		apierror.Write(w, r, apierror.Forbidden(err.Error()))
+		return
+	}
+
+	created, err := h.ensureRoom(r.Context(), cfg, req.Name)
+	if err != nil {
+		log.Error("Failed to create room", "error", err, "room", req.Name)
+		apierror.Write(w, r, apierror.Upstream("Failed to create room"))
+		return
+	}
+	room, err := twilioclient.New(r.Context(), cfg).VideoV1.FetchRoom(req.Name)
+	if err != nil {
+		log.Error("Failed to fetch room", "error", err, "room", req.Name)
+		apierror.Write(w, r, apierror.Upstream("Failed to fetch room details"))
+		return
+	}
+
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+		h.audit.Record(r, audit.Entry{Actor: user.Actor(), Action: audit.ActionRoomCreated, Target: req.Name,
+			Outcome: audit.OutcomeSuccess, Details: user.AuditDetails(nil)})
+	}
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(room)
+}
+
+// ListActiveRooms returns the rooms in progress
+func ListActiveRooms(ctx context.Context, cfg *config.Config) ([]TwilioRoom, error) {
+	params := &openapi.ListRoomParams{}
+	params.SetStatus("in-progress")
+	return twilioclient.New(ctx, cfg).VideoV1.ListRoom(params)
+}
+
+// EndRoom completes a room in progress, disconnecting its participants.
+// name is the room's unique name or SID.
+func EndRoom(ctx context.Context, cfg *config.Config, name string) (*TwilioRoom, error) {
+	params := &openapi.UpdateRoomParams{}
+	params.SetStatus("completed")
+	return twilioclient.New(ctx, cfg).VideoV1.UpdateRoom(name, params)
+}
+
+// listRooms returns the rooms in progress
+func (h *Handler) listRooms(w http.ResponseWriter, r *http.Request) {
+	log := logging.FromContext(r.Context())
+	w.Header().Set("Content-Type", "application/json")
+
+	rooms, err := ListActiveRooms(r.Context(), h.config.Get())
+	if err != nil {
+		log.Error("Failed to list rooms", "error", err)
+		apierror.Write(w, r, apierror.Upstream("Failed to list rooms"))
+		return
+	}
+
+	resp := ListRoomsResponse{Rooms: []TwilioRoom{}}
+	resp.Rooms = append(resp.Rooms, rooms...)
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// endRoom ends a room in progress
+func (h *Handler) endRoom(w http.ResponseWriter, r *http.Request) {
+	log := logging.FromContext(r.Context())
+	w.Header().Set("Content-Type", "application/json")
+
+	name := r.PathValue("name")
+	user := middleware.GetUser(r)
+	actor := user.Actor()
+	room, err := EndRoom(r.Context(), h.config.Get(), name)
+	if err != nil {
+		var restErr *client.TwilioRestError
+		if errors.As(err, &restErr) && restErr.Status == http.StatusNotFound {
+			apierror.Write(w, r, apierror.NotFound("Room not found"))
+			return
+		}
+		log.Error("Failed to end room", "error", err, "room", name)
+		h.audit.Record(r, audit.Entry{Actor: actor, Action: audit.ActionRoomEnded, Target: name, Outcome: audit.OutcomeError,
+			Details: user.AuditDetails(nil)})
+		apierror.Write(w, r, apierror.Upstream("Failed to end room"))
+		return
+	}
+
+	log.Info("Room ended", "room", name, "actor", actor)
+	h.audit.Record(r, audit.Entry{Actor: actor, Action: audit.ActionRoomEnded, Target: name, Outcome: audit.OutcomeSuccess,
+		Details: user.AuditDetails(nil)})
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(room)
+}
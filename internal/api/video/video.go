@@ -4,27 +4,136 @@ import (
 	"net/http"
 
 	"github.com/kaustavdm/awwdio/config"
-	"github.com/twilio/twilio-go"
+	"github.com/kaustavdm/awwdio/internal/api/openapi"
+	"github.com/kaustavdm/awwdio/internal/api/webhooks"
+	"github.com/kaustavdm/awwdio/internal/audit"
+	"github.com/kaustavdm/awwdio/internal/store"
 )
 
 type Handler struct {
-	config *config.Config
+	config *config.Provider
 
-	twilioClient *twilio.RestClient
+	rooms        *store.Collection[Room]
+	compositions *store.Collection[Composition]
+
+	webhooks *webhooks.Handler
+	audit    *audit.Log
+	guards   []RoomGuard
 }
 
-func NewHandler(cfg *config.Config) *Handler {
-	return &Handler{
-		config: cfg,
-		twilioClient: twilio.NewRestClientWithParams(twilio.ClientParams{
-			Username:   cfg.TwilioApiKey,
-			Password:   cfg.TwilioApiSecret,
-			AccountSid: cfg.TwilioAccountSID,
-		}),
+// RoomGuard decides whether identity may join room. A non-nil error denies
+// access and its message is returned to the user.
+type RoomGuard func(identity, room string) error
+
+// AddRoomGuard adds a check run before issuing any video token or
+// creating a room
+func (h *Handler) AddRoomGuard(g RoomGuard) {
+	h.guards = append(h.guards, g)
+}
+
+// checkGuards returns the first guard's reason for denying identity access
+// to room
+func (h *Handler) checkGuards(identity, room string) error {
+	for _, guard := range h.guards {
+		if err := guard(identity, room); err != nil {
+			return err
+		}
 	}
+	return nil
+}
+
+func NewHandler(cfg *config.Provider, st *store.Store, hooks *webhooks.Handler, auditLog *audit.Log) (*Handler, error) {
+	rooms, err := store.NewCollection[Room](st, "rooms")
+	if err != nil {
+		return nil, err
+	}
+	compositions, err := store.NewCollection[Composition](st, "compositions")
+	if err != nil {
+		return nil, err
+	}
+	return &Handler{
+		config:       cfg,
+		rooms:        rooms,
+		compositions: compositions,
+		webhooks:     hooks,
+		audit:        auditLog,
+	}, nil
+}
+
+func (h *Handler) Register(mux *openapi.Mux) {
+	mux.HandleFunc("POST /token", h.tokenHandler, openapi.Operation{
+		ID:       "createVideoToken",
+		Summary:  "Issue a Twilio Video access token for a room",
+		Request:  TokenRequest{},
+		Response: TokenResponse{},
+	})
+	mux.HandleFunc("GET /room", h.getRoom, openapi.Operation{
+		ID:       "getRoom",
+		Summary:  "Fetch a room from Twilio",
+		Response: TwilioRoom{},
+		Query: []openapi.Param{
+			{Name: "roomName", Description: "Room unique name or SID", Required: true},
+		},
+	})
+	mux.HandleFunc("POST /rooms", h.createRoom, openapi.Operation{
+		ID:       "createRoom",
+		Summary:  "Create a room with status callbacks before anyone joins; 200 if it was already in progress",
+		Request:  CreateRoomRequest{},
+		Response: TwilioRoom{},
+		Status:   http.StatusCreated,
+	})
+	mux.HandleFunc("GET /compositions/{sid}", h.getComposition, openapi.Operation{
+		ID:       "getComposition",
+		Summary:  "Get a recording composition",
+		Response: Composition{},
+	})
+	mux.HandleFunc("GET /compositions/{sid}/media", h.compositionMedia, openapi.Operation{
+		ID:      "getCompositionMedia",
+		Summary: "Redirect to a short-lived download link for a completed composition",
+		Status:  http.StatusFound,
+	})
+}
+
+// RegisterAdmin registers the room administration routes. They are meant
+// to be mounted behind admin authentication.
+func (h *Handler) RegisterAdmin(mux *openapi.Mux) {
+	mux.HandleFunc("GET /rooms", h.listRooms, openapi.Operation{
+		ID:       "listRooms",
+		Summary:  "List the rooms in progress",
+		Response: ListRoomsResponse{},
+	})
+	mux.HandleFunc("POST /rooms/{name}/end", h.endRoom, openapi.Operation{
+		ID:       "endRoom",
+		Summary:  "End a room in progress, disconnecting its participants",
+		Response: TwilioRoom{},
+	})
+}
+
+// RegisterCallbacks registers the Twilio status callback routes. These are
+// called by Twilio rather than users, so they are authenticated by request
+// signature instead of JWT.
+func (h *Handler) RegisterCallbacks(mux *openapi.Mux) {
+	mux.Handle("POST /room-status", h.validateTwilioSignature(http.HandlerFunc(h.roomStatusCallback)), openapi.Operation{
+		ID:          "roomStatusCallback",
+		Summary:     "Twilio room status callback",
+		RequestType: formType,
+	})
+	mux.Handle("POST /composition-status", h.validateTwilioSignature(http.HandlerFunc(h.compositionStatusCallback)), openapi.Operation{
+		ID:          "compositionStatusCallback",
+		Summary:     "Twilio composition status callback",
+		RequestType: formType,
+	})
+}
+
+// formType is the media type of Twilio callbacks
+const formType = "application/x-www-form-urlencoded"
+
+// Rooms returns every room observed through status callbacks
+func (h *Handler) Rooms() []Room {
+	return h.rooms.List()
 }
 
-func (h *Handler) Register(mux *http.ServeMux) {
-	mux.HandleFunc("POST /token", h.tokenHandler)
-	mux.HandleFunc("GET /room", h.getRoom)
+// Composition returns the composition with the given SID
+func (h *Handler) Composition(sid string) (Composition, bool) {
+	return h.compositions.Get(sid)
 }
@@ -0,0 +1,61 @@
+package webhooks
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+)
+
+// errBlockedAddress is returned when a webhook URL resolves to an address
+// on this host or its private network
+var errBlockedAddress = errors.New("webhook address is not public")
+
+// blockedAddr reports whether webhooks must not be sent to addr: loopback,
+// private, link-local, multicast and unspecified addresses
+func blockedAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsLoopback() || addr.IsPrivate() || addr.IsLinkLocalUnicast() ||
+		addr.IsLinkLocalMulticast() || addr.IsInterfaceLocalMulticast() ||
+		addr.IsMulticast() || addr.IsUnspecified()
+}
+
+// checkDial rejects connections to blocked addresses. It runs after DNS
+// resolution, so names pointing at internal addresses are caught too.
+func checkDial(network, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+	if blockedAddr(addrPort.Addr()) {
+		return errBlockedAddress
+	}
+	return nil
+}
+
+// newClient returns the client deliveries are sent with. It does not use
+// proxies from the environment and only connects to public addresses.
+func newClient() *http.Client {
+	dialer := &net.Dialer{Timeout: 10 * time.Second, Control: checkDial}
+	return &http.Client{
+		Timeout: deliveryTimeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: 10 * time.Second,
+			MaxIdleConns:        10,
+			IdleConnTimeout:     90 * time.Second,
+		},
+	}
+}
+
+// blockedHost reports whether host is an address or name that is never
+// public, so such URLs are rejected before any delivery is attempted
+func blockedHost(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	addr, err := netip.ParseAddr(host)
+	return err == nil && blockedAddr(addr)
+}
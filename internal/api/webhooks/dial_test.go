@@ -0,0 +1,49 @@
+package webhooks
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestBlockedHost(t *testing.T) {
+	tests := []struct {
+		host string
+		want bool
+	}{
+		{"localhost", true},
+		{"127.0.0.1", true},
+		{"::1", true},
+		{"10.1.2.3", true},
+		{"172.16.0.1", true},
+		{"192.168.1.1", true},
+		{"169.254.169.254", true},
+		{"fe80::1", true},
+		{"fd00::1", true},
+		{"0.0.0.0", true},
+		{"::ffff:127.0.0.1", true},
+		{"8.8.8.8", false},
+		{"2606:4700::1111", false},
+		{"example.com", false},
+	}
+	for _, tt := range tests {
+		if got := blockedHost(tt.host); got != tt.want {
+			t.Errorf("blockedHost(%q) = %v, want %v", tt.host, got, tt.want)
+		}
+	}
+}
+
+// Names can resolve to internal addresses, so the check also runs when
+// connecting
+func TestClientRefusesPrivateAddresses(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("request reached a loopback server")
+	}))
+	defer srv.Close()
+
+	_, err := newClient().Get(srv.URL)
+	if !errors.Is(err, errBlockedAddress) {
+		t.Errorf("got %v, want %v", err, errBlockedAddress)
+	}
+}
@@ -0,0 +1,223 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/kaustavdm/awwdio/apitypes"
+)
+
+const (
+	// deliveryTimeout bounds a single delivery attempt
+	deliveryTimeout = 10 * time.Second
+	// maxAttempts is how often a delivery is tried before it is marked failed
+	maxAttempts = 8
+	// initialBackoff is the delay before the first retry. It doubles with
+	// every attempt, so the last retry happens roughly an hour after the event.
+	initialBackoff = 30 * time.Second
+	// pollInterval is how often the worker looks for due retries
+	pollInterval = 5 * time.Second
+	// deliveryRetention is how long finished deliveries stay in the log
+	deliveryRetention = 30 * 24 * time.Hour
+)
+
+// Delivery statuses
+const (
+	StatusPending   = apitypes.StatusPending
+	StatusSucceeded = apitypes.StatusSucceeded
+	StatusFailed    = apitypes.StatusFailed
+)
+
+var errDeliveryNotFound = errors.New("delivery not found")
+
+// Event is the JSON body posted to subscribers
+type Event struct {
+	ID        string    `json:"id"`
+	Type      string    `json:"type"`
+	CreatedAt time.Time `json:"created_at"`
+	Data      any       `json:"data"`
+}
+
+// Delivery is one event sent to one subscription, with its outcome
+type Delivery = apitypes.Delivery
+
+// Emit records an event for every subscription that wants it. Delivery
+// happens in the background; failures never reach the caller.
+func (h *Handler) Emit(eventType string, data any) {
+	eventID, err := randomHex(16)
+	if err != nil {
+		slog.Error("Failed to generate event ID", "error", err)
+		return
+	}
+	event := Event{ID: eventID, Type: eventType, CreatedAt: time.Now().UTC(), Data: data}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		slog.Error("Failed to encode event", "error", err, "event_type", eventType)
+		return
+	}
+
+	queued := false
+	for _, sub := range h.subscriptions.List() {
+		if !sub.Wants(eventType) {
+			continue
+		}
+		id, err := randomHex(16)
+		if err != nil {
+			slog.Error("Failed to generate delivery ID", "error", err)
+			return
+		}
+		d := Delivery{
+			ID:             id,
+			SubscriptionID: sub.ID,
+			EventID:        event.ID,
+			EventType:      eventType,
+			Payload:        payload,
+			Status:         StatusPending,
+			CreatedAt:      event.CreatedAt,
+			NextAttemptAt:  event.CreatedAt,
+		}
+		if err := h.deliveries.Put(d.ID, d); err != nil {
+			slog.Error("Failed to store delivery", "error", err, "webhook_id", sub.ID)
+			continue
+		}
+		queued = true
+	}
+
+	if queued {
+		h.notifyWorker()
+	}
+}
+
+// Run runs the delivery worker until ctx is done. Deliveries are kept in
+// the store, so pending retries resume after a restart.
+func (h *Handler) Run(ctx context.Context) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		h.deliverDue(ctx, time.Now())
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-h.wake:
+		}
+	}
+}
+
+// notifyWorker wakes the worker without waiting for the next poll
+func (h *Handler) notifyWorker() {
+	select {
+	case h.wake <- struct{}{}:
+	default:
+	}
+}
+
+// deliverDue attempts every pending delivery whose retry time has come,
+// and prunes old finished deliveries
+func (h *Handler) deliverDue(ctx context.Context, now time.Time) {
+	for _, d := range h.deliveries.List() {
+		if ctx.Err() != nil {
+			return
+		}
+
+		if d.Status != StatusPending {
+			if d.CreatedAt.Before(now.Add(-deliveryRetention)) {
+				if err := h.deliveries.Delete(d.ID); err != nil {
+					slog.Error("Failed to prune delivery", "error", err, "delivery_id", d.ID)
+				}
+			}
+			continue
+		}
+		if d.NextAttemptAt.After(now) {
+			continue
+		}
+
+		sub, ok := h.subscriptions.Get(d.SubscriptionID)
+		if !ok {
+			// The subscription was deleted, so there is nowhere to deliver to
+			if err := h.deliveries.Delete(d.ID); err != nil {
+				slog.Error("Failed to drop delivery", "error", err, "delivery_id", d.ID)
+			}
+			continue
+		}
+
+		h.attempt(ctx, sub, d)
+	}
+}
+
+// attempt posts a delivery once and records the outcome
+func (h *Handler) attempt(ctx context.Context, sub Subscription, d Delivery) {
+	code, err := h.post(ctx, sub, d)
+
+	d.Attempts++
+	d.ResponseCode = code
+	d.LastError = ""
+	switch {
+	case err == nil:
+		d.Status = StatusSucceeded
+		d.DeliveredAt = time.Now().UTC()
+		d.NextAttemptAt = time.Time{}
+		slog.Debug("Webhook delivered", "delivery_id", d.ID, "event_type", d.EventType, "url", sub.URL)
+	case d.Attempts >= maxAttempts:
+		d.Status = StatusFailed
+		d.LastError = err.Error()
+		d.NextAttemptAt = time.Time{}
+		slog.Error("Webhook delivery failed", "error", err, "delivery_id", d.ID, "url", sub.URL, "attempts", d.Attempts)
+	default:
+		d.LastError = err.Error()
+		d.NextAttemptAt = time.Now().UTC().Add(initialBackoff << (d.Attempts - 1))
+		slog.Warn("Webhook delivery failed, retrying", "error", err, "delivery_id", d.ID, "url", sub.URL, "retry_at", d.NextAttemptAt)
+	}
+
+	if err := h.deliveries.Put(d.ID, d); err != nil {
+		slog.Error("Failed to store delivery", "error", err, "delivery_id", d.ID)
+	}
+}
+
+// post sends the delivery payload, signed with the subscription secret
+func (h *Handler) post(ctx context.Context, sub Subscription, d Delivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.URL, bytes.NewReader(d.Payload))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Awwdio-Webhooks/1.0")
+	req.Header.Set("X-Awwdio-Event", d.EventType)
+	req.Header.Set("X-Awwdio-Delivery", d.ID)
+	req.Header.Set("X-Awwdio-Signature", "t="+timestamp+",v1="+Sign(sub.Secret, timestamp, d.Payload))
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// Sign computes the v1 signature of a delivery: the hex encoded
+// HMAC-SHA256 of "<timestamp>.<body>" keyed with the subscription secret.
+// Receivers should recompute it and reject stale timestamps.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,190 @@
+package webhooks
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kaustavdm/awwdio/internal/store"
+)
+
+// newTestHandler returns a handler that delivers to srv, which listens on
+// loopback and would otherwise be refused
+func newTestHandler(t *testing.T, srv *httptest.Server, subs ...Subscription) *Handler {
+	t.Helper()
+	st, err := store.Open("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := NewHandler(st)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.client = srv.Client()
+	for _, sub := range subs {
+		sub.URL = srv.URL
+		if err := h.subscriptions.Put(sub.ID, sub); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return h
+}
+
+// receiver answers with the given status codes in turn, then 200, and
+// fails the test on unsigned requests
+func receiver(t *testing.T, secret string, codes ...int) (*httptest.Server, *atomic.Int32) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		timestamp, sig, _ := strings.Cut(strings.TrimPrefix(r.Header.Get("X-Awwdio-Signature"), "t="), ",v1=")
+		if sig != Sign(secret, timestamp, body) {
+			t.Errorf("bad signature %q", r.Header.Get("X-Awwdio-Signature"))
+		}
+		if r.Header.Get("X-Awwdio-Event") == "" || r.Header.Get("X-Awwdio-Delivery") == "" {
+			t.Errorf("missing event headers: %v", r.Header)
+		}
+		n := int(calls.Add(1))
+		if n <= len(codes) {
+			w.WriteHeader(codes[n-1])
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &calls
+}
+
+func TestSign(t *testing.T) {
+	// Computed with: printf '1700000000.{}' | openssl dgst -sha256 -hmac secret
+	want := "b8569b78799ff9e3cbff0fc2d63a33a2b57f3282abd07c37ae5e8e7d79a5f163"
+	if got := Sign("secret", "1700000000", []byte("{}")); got != want {
+		t.Errorf("Sign = %q, want %q", got, want)
+	}
+	if Sign("secret", "1", []byte("a")) == Sign("secret", "2", []byte("a")) {
+		t.Error("signature does not cover the timestamp")
+	}
+	if Sign("secret", "1", []byte("a")) == Sign("other", "1", []byte("a")) {
+		t.Error("signature does not depend on the secret")
+	}
+}
+
+func TestEmitFansOut(t *testing.T) {
+	srv, _ := receiver(t, "s")
+	h := newTestHandler(t, srv,
+		Subscription{ID: "all", Secret: "s", Events: []string{"*"}},
+		Subscription{ID: "rooms", Secret: "s", Events: []string{EventRoomStarted}},
+		Subscription{ID: "users", Secret: "s", Events: []string{EventUserCreated}},
+	)
+	h.Emit(EventRoomStarted, map[string]string{"room_sid": "RM1"})
+
+	got := map[string]bool{}
+	for _, d := range h.deliveries.List() {
+		got[d.SubscriptionID] = true
+		if d.Status != StatusPending || d.EventType != EventRoomStarted || !strings.Contains(string(d.Payload), "RM1") {
+			t.Errorf("delivery %+v", d)
+		}
+	}
+	if len(got) != 2 || !got["all"] || !got["rooms"] {
+		t.Errorf("deliveries for %v, want all and rooms", got)
+	}
+}
+
+func TestDeliveryRetries(t *testing.T) {
+	srv, calls := receiver(t, "s", http.StatusInternalServerError)
+	h := newTestHandler(t, srv, Subscription{ID: "sub", Secret: "s", Events: []string{"*"}})
+	h.Emit(EventUserCreated, map[string]string{"subject": "alice@example.com"})
+	ctx := context.Background()
+
+	now := time.Now()
+	h.deliverDue(ctx, now)
+	d := h.deliveries.List()[0]
+	if d.Status != StatusPending || d.Attempts != 1 || d.ResponseCode != http.StatusInternalServerError || d.LastError == "" {
+		t.Fatalf("after a failure: %+v", d)
+	}
+	if wait := d.NextAttemptAt.Sub(now); wait < initialBackoff || wait > initialBackoff+time.Minute {
+		t.Errorf("retry in %v, want %v", wait, initialBackoff)
+	}
+
+	// Not retried before it is due
+	h.deliverDue(ctx, now)
+	if calls.Load() != 1 {
+		t.Fatalf("%d calls, want 1", calls.Load())
+	}
+
+	h.deliverDue(ctx, d.NextAttemptAt)
+	d = h.deliveries.List()[0]
+	if d.Status != StatusSucceeded || d.Attempts != 2 || d.DeliveredAt.IsZero() || d.LastError != "" {
+		t.Errorf("after a retry: %+v", d)
+	}
+}
+
+func TestDeliveryFailsAfterMaxAttempts(t *testing.T) {
+	codes := make([]int, maxAttempts+1)
+	for i := range codes {
+		codes[i] = http.StatusServiceUnavailable
+	}
+	srv, calls := receiver(t, "s", codes...)
+	h := newTestHandler(t, srv, Subscription{ID: "sub", Secret: "s", Events: []string{"*"}})
+	h.Emit(EventUserCreated, nil)
+	ctx := context.Background()
+
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		before := time.Now()
+		h.deliverDue(ctx, before.Add(24*time.Hour))
+		d := h.deliveries.List()[0]
+		if attempt < maxAttempts {
+			// The backoff doubles with every attempt
+			want := initialBackoff << (attempt - 1)
+			if wait := d.NextAttemptAt.Sub(before); d.Status != StatusPending || wait < want || wait > want+time.Minute {
+				t.Fatalf("attempt %d: status %s, retry in %v, want %v", attempt, d.Status, wait, want)
+			}
+			continue
+		}
+		if d.Status != StatusFailed || d.Attempts != maxAttempts || !d.NextAttemptAt.IsZero() {
+			t.Errorf("after %d attempts: %+v", attempt, d)
+		}
+	}
+
+	// Failed deliveries are not retried
+	h.deliverDue(ctx, time.Now().Add(24*time.Hour))
+	if int(calls.Load()) != maxAttempts {
+		t.Errorf("%d calls, want %d", calls.Load(), maxAttempts)
+	}
+}
+
+func TestRedeliver(t *testing.T) {
+	srv, calls := receiver(t, "s")
+	h := newTestHandler(t, srv, Subscription{ID: "sub", Secret: "s", Events: []string{"*"}})
+	h.Emit(EventUserCreated, nil)
+	ctx := context.Background()
+	h.deliverDue(ctx, time.Now())
+	d := h.deliveries.List()[0]
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /webhooks/deliveries/{id}/redeliver", h.redeliver)
+	for _, tt := range []struct {
+		id   string
+		code int
+	}{
+		{"unknown", http.StatusNotFound},
+		{d.ID, http.StatusAccepted},
+	} {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest("POST", "/webhooks/deliveries/"+tt.id+"/redeliver", nil))
+		if rec.Code != tt.code {
+			t.Errorf("redeliver %s: status %d, want %d", tt.id, rec.Code, tt.code)
+		}
+	}
+
+	d, _ = h.deliveries.Get(d.ID)
+	if d.Status != StatusPending || d.Attempts != 0 {
+		t.Fatalf("after redeliver: %+v", d)
+	}
+	h.deliverDue(ctx, time.Now())
+	if d, _ = h.deliveries.Get(d.ID); d.Status != StatusSucceeded || calls.Load() != 2 {
+		t.Errorf("redelivery: %+v after %d calls", d, calls.Load())
+	}
+}
@@ -0,0 +1,260 @@
+package webhooks
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kaustavdm/awwdio/apitypes"
+	"github.com/kaustavdm/awwdio/internal/api/apierror"
+	"github.com/kaustavdm/awwdio/internal/api/openapi"
+	"github.com/kaustavdm/awwdio/internal/api/request"
+	"github.com/kaustavdm/awwdio/internal/logging"
+	"github.com/kaustavdm/awwdio/internal/store"
+)
+
+// Event types delivered to subscribers
+const (
+	EventRoomStarted       = "room.started"
+	EventParticipantJoined = "participant.joined"
+	EventRecordingReady    = "recording.ready"
+	EventUserCreated       = "user.created"
+)
+
+// eventTypes lists every event type a subscription may ask for. "*"
+// subscribes to all of them.
+var eventTypes = []string{EventRoomStarted, EventParticipantJoined, EventRecordingReady, EventUserCreated}
+
+// Subscription is an endpoint that receives events
+type Subscription = apitypes.Subscription
+
+type Handler struct {
+	subscriptions *store.Collection[Subscription]
+	deliveries    *store.Collection[Delivery]
+
+	client *http.Client
+	wake   chan struct{}
+}
+
+func NewHandler(st *store.Store) (*Handler, error) {
+	subscriptions, err := store.NewCollection[Subscription](st, "webhook_subscriptions")
+	if err != nil {
+		return nil, err
+	}
+	deliveries, err := store.NewCollection[Delivery](st, "webhook_deliveries")
+	if err != nil {
+		return nil, err
+	}
+	return &Handler{
+		subscriptions: subscriptions,
+		deliveries:    deliveries,
+		client:        newClient(),
+		wake:          make(chan struct{}, 1),
+	}, nil
+}
+
+// Register registers the subscription management routes. They are meant
+// to be mounted behind admin authentication.
+func (h *Handler) Register(mux *openapi.Mux) {
+	mux.HandleFunc("POST /webhooks", h.createSubscription, openapi.Operation{
+		ID:       "createWebhook",
+		Summary:  "Add a webhook endpoint; the response is the only one to include its secret",
+		Request:  CreateSubscriptionRequest{},
+		Response: Subscription{},
+		Status:   http.StatusCreated,
+	})
+	mux.HandleFunc("GET /webhooks", h.listSubscriptions, openapi.Operation{
+		ID:       "listWebhooks",
+		Summary:  "List webhook endpoints, without their secrets",
+		Response: ListSubscriptionsResponse{},
+	})
+	mux.HandleFunc("DELETE /webhooks/{id}", h.deleteSubscription, openapi.Operation{
+		ID:      "deleteWebhook",
+		Summary: "Remove a webhook endpoint and drop its pending deliveries",
+		Status:  http.StatusNoContent,
+	})
+	mux.HandleFunc("GET /webhooks/{id}/deliveries", h.listDeliveries, openapi.Operation{
+		ID:       "listWebhookDeliveries",
+		Summary:  "List the deliveries to a webhook endpoint, newest first",
+		Response: ListDeliveriesResponse{},
+	})
+	mux.HandleFunc("POST /webhooks/deliveries/{id}/redeliver", h.redeliver, openapi.Operation{
+		ID:       "redeliverWebhook",
+		Summary:  "Send a delivery again right away",
+		Response: Delivery{},
+		Status:   http.StatusAccepted,
+	})
+}
+
+type (
+	CreateSubscriptionRequest = apitypes.CreateSubscriptionRequest
+	ListSubscriptionsResponse = apitypes.ListSubscriptionsResponse
+	ListDeliveriesResponse    = apitypes.ListDeliveriesResponse
+)
+
+// createSubscription adds a webhook endpoint. The secret is only returned here.
+func (h *Handler) createSubscription(w http.ResponseWriter, r *http.Request) {
+	log := logging.FromContext(r.Context())
+	w.Header().Set("Content-Type", "application/json")
+
+	var req CreateSubscriptionRequest
+	if err := request.Decode(w, r, &req); err != nil {
+		log.Debug("Invalid request body", "error", err)
+		apierror.Write(w, r, err)
+		return
+	}
+
+	u, err := url.Parse(req.URL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		apierror.Write(w, r, apierror.BadRequest("URL must be an absolute http or https URL"))
+		return
+	}
+	if blockedHost(strings.ToLower(u.Hostname())) {
+		apierror.Write(w, r, apierror.BadRequest("URL must point to a public address"))
+		return
+	}
+
+	if len(req.Events) == 0 {
+		apierror.Write(w, r, apierror.BadRequest("At least one event type is required"))
+		return
+	}
+	for _, e := range req.Events {
+		if e != "*" && !slices.Contains(eventTypes, e) {
+			apierror.Write(w, r, apierror.BadRequest("Unknown event type: "+e))
+			return
+		}
+	}
+
+	id, err := randomHex(16)
+	if err == nil && req.Secret == "" {
+		req.Secret, err = randomHex(32)
+	}
+	if err != nil {
+		log.Error("Failed to generate webhook identifiers", "error", err)
+		apierror.Write(w, r, apierror.Internal("Failed to create webhook"))
+		return
+	}
+
+	sub := Subscription{
+		ID:        id,
+		URL:       req.URL,
+		Secret:    req.Secret,
+		Events:    req.Events,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := h.subscriptions.Put(sub.ID, sub); err != nil {
+		log.Error("Failed to store webhook", "error", err)
+		apierror.Write(w, r, apierror.Internal("Failed to create webhook"))
+		return
+	}
+
+	log.Info("Webhook created", "webhook_id", sub.ID, "url", sub.URL, "events", sub.Events)
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(sub)
+}
+
+// listSubscriptions returns all webhook endpoints without their secrets
+func (h *Handler) listSubscriptions(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	resp := ListSubscriptionsResponse{Subscriptions: []Subscription{}}
+	for _, sub := range h.subscriptions.List() {
+		sub.Secret = ""
+		resp.Subscriptions = append(resp.Subscriptions, sub)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// deleteSubscription removes a webhook endpoint. Pending deliveries to it are dropped.
+func (h *Handler) deleteSubscription(w http.ResponseWriter, r *http.Request) {
+	log := logging.FromContext(r.Context())
+	w.Header().Set("Content-Type", "application/json")
+
+	id := r.PathValue("id")
+	if _, ok := h.subscriptions.Get(id); !ok {
+		apierror.Write(w, r, apierror.NotFound("Webhook not found"))
+		return
+	}
+	if err := h.subscriptions.Delete(id); err != nil {
+		log.Error("Failed to delete webhook", "error", err, "webhook_id", id)
+		apierror.Write(w, r, apierror.Internal("Failed to delete webhook"))
+		return
+	}
+
+	log.Info("Webhook deleted", "webhook_id", id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// listDeliveries returns the delivery log of a webhook, newest first
+func (h *Handler) listDeliveries(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id := r.PathValue("id")
+	if _, ok := h.subscriptions.Get(id); !ok {
+		apierror.Write(w, r, apierror.NotFound("Webhook not found"))
+		return
+	}
+
+	resp := ListDeliveriesResponse{Deliveries: []Delivery{}}
+	for _, d := range h.deliveries.List() {
+		if d.SubscriptionID == id {
+			resp.Deliveries = append(resp.Deliveries, d)
+		}
+	}
+	sort.Slice(resp.Deliveries, func(i, j int) bool {
+		return resp.Deliveries[i].CreatedAt.After(resp.Deliveries[j].CreatedAt)
+	})
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// redeliver schedules a delivery to be sent again right away, whatever its status
+func (h *Handler) redeliver(w http.ResponseWriter, r *http.Request) {
+	log := logging.FromContext(r.Context())
+	w.Header().Set("Content-Type", "application/json")
+
+	id := r.PathValue("id")
+	d, err := h.deliveries.Update(id, func(d Delivery, exists bool) (Delivery, error) {
+		if !exists {
+			return d, errDeliveryNotFound
+		}
+		d.Status = StatusPending
+		d.Attempts = 0
+		d.NextAttemptAt = time.Now().UTC()
+		return d, nil
+	})
+	if err == errDeliveryNotFound {
+		apierror.Write(w, r, apierror.NotFound("Delivery not found"))
+		return
+	}
+	if err != nil {
+		log.Error("Failed to schedule redelivery", "error", err, "delivery_id", id)
+		apierror.Write(w, r, apierror.Internal("Failed to schedule redelivery"))
+		return
+	}
+
+	log.Info("Webhook redelivery scheduled", "delivery_id", id)
+	h.notifyWorker()
+
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(d)
+}
+
+// randomHex returns n random bytes, hex encoded
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,382 @@
+// Package audit keeps a tamper-evident trail of security-relevant events.
+//
+// Entries are appended to a JSON-lines file. Each entry carries the hash of
+// the one before it and its own hash over both, an HMAC keyed with
+// AUDIT_HMAC_KEY. Editing, removing or reordering past entries breaks the
+// chain, and without the key it cannot be rebuilt; Verify detects it. Several
+// processes, such as the server and the admin CLI, may append to one file.
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kaustavdm/awwdio/apitypes"
+	"github.com/kaustavdm/awwdio/internal/filelock"
+	"github.com/kaustavdm/awwdio/internal/logging"
+)
+
+// Actions
+const (
+	ActionLogin           = "auth.login"        // OTP verification, failures included
+	ActionTokenIssued     = "video.token"       // Video access token requested
+	ActionRoomCreated     = "video.room_create" // Twilio room created
+	ActionRoomEnded       = "video.room_end"    // Room ended through the API
+	ActionRecordingAccess = "recording.access"  // Composition metadata or media fetched
+	ActionAdmin           = "admin.request"     // Any request to the admin API
+	ActionAPIKeyCreated   = "apikey.create"
+	ActionAPIKeyRotated   = "apikey.rotate"
+	ActionAPIKeyRevoked   = "apikey.revoke"
+	ActionSessionIssued   = "auth.token_issue" // Session token issued with the admin CLI
+	ActionUserDisabled    = "user.disable"
+	ActionUserEnabled     = "user.enable"
+	ActionMigration       = "store.migrate" // Store schema migrated up or down
+)
+
+// Outcomes
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure" // e.g. wrong OTP
+	OutcomeDenied  = "denied"  // not allowed to
+	OutcomeError   = "error"   // something broke on our side
+)
+
+// Entry is one audited event
+type Entry = apitypes.AuditEntry
+
+// computeHash is the HMAC-SHA256 of e with its Hash field cleared, keyed
+// with the log key
+func computeHash(e Entry, key []byte) (string, error) {
+	e.Hash = ""
+	data, err := json.Marshal(e)
+	if err != nil {
+		return "", err
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// ErrBroken is returned when the chain does not verify: entries were
+// modified, removed or reordered, or the key is wrong
+var ErrBroken = errors.New("audit log failed verification")
+
+// chain is the head of a hash chain
+type chain struct {
+	key  []byte
+	hash string // Of the last entry
+	seq  int64  // Of the last entry
+}
+
+// next checks that e follows the head of the chain, and makes it the head
+func (c *chain) next(e Entry) error {
+	if e.PrevHash != c.hash || e.Seq != c.seq+1 {
+		return fmt.Errorf("%w: seq %d: chain broken, previous entry does not match", ErrBroken, e.Seq)
+	}
+	hash, err := computeHash(e, c.key)
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal([]byte(hash), []byte(e.Hash)) {
+		return fmt.Errorf("%w: seq %d: hash mismatch, entry was modified or the key is wrong", ErrBroken, e.Seq)
+	}
+	c.hash, c.seq = e.Hash, e.Seq
+	return nil
+}
+
+// memoryEntries is how many recent entries are kept in memory for
+// queries. Older ones are read from the file when asked for.
+var memoryEntries = 10000
+
+// Log is the audit trail
+type Log struct {
+	mu      sync.Mutex
+	chain   chain
+	path    string
+	file    *os.File       // nil when not persisted
+	lock    *filelock.File // On file, held across sync and append
+	size    int64          // Of the file as far as read or written
+	entries []Entry        // The most recent, oldest first
+}
+
+// Open loads the audit trail at path, verifying its hash chain, and
+// appends new entries to it. Entries are chained with an HMAC keyed with
+// key. With an empty path only the most recent entries are kept, in
+// memory.
+//
+// A trail that fails verification is not appended to: Open returns an
+// error wrapping ErrBroken.
+func Open(path string, key []byte) (*Log, error) {
+	l := &Log{chain: chain{key: key}}
+	if path == "" {
+		return l, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create audit log directory: %w", err)
+	}
+	l.path = path
+	if err := l.sync(); err != nil {
+		if errors.Is(err, ErrBroken) {
+			return nil, fmt.Errorf("%w; investigate %s, then move it aside to start a new log", err, path)
+		}
+		return nil, err
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	l.file = file
+	l.lock = filelock.On(file)
+	return l, nil
+}
+
+// sync reads and verifies the entries appended to the file since it was
+// last read, e.g. by another process. Callers must hold the lock, or own l.
+func (l *Log) sync() error {
+	info, err := os.Stat(l.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read audit log: %w", err)
+	}
+	if info.Size() == l.size {
+		return nil
+	}
+	if info.Size() < l.size {
+		return fmt.Errorf("%w: file shrank from %d to %d bytes", ErrBroken, l.size, info.Size())
+	}
+	l.size, err = scan(l.path, l.size, func(e Entry) error {
+		if err := l.chain.next(e); err != nil {
+			return err
+		}
+		l.keep(e)
+		return nil
+	})
+	return err
+}
+
+// keep adds e to the entries in memory, dropping the oldest ones once
+// there are twice as many as memoryEntries
+func (l *Log) keep(e Entry) {
+	l.entries = append(l.entries, e)
+	if len(l.entries) >= 2*memoryEntries {
+		l.entries = slices.Clone(l.entries[len(l.entries)-memoryEntries:])
+	}
+}
+
+// scan calls fn with each entry of the file at path from byte offset on,
+// and returns the offset after the last entry read. A partly written last
+// line is left for the next scan.
+func scan(path string, offset int64, fn func(Entry) error) (int64, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return offset, nil
+	}
+	if err != nil {
+		return offset, fmt.Errorf("failed to read audit log: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return offset, fmt.Errorf("failed to read audit log: %w", err)
+	}
+
+	r := bufio.NewReader(f)
+	for {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			return offset, nil
+		}
+		if err != nil {
+			return offset, fmt.Errorf("failed to read audit log: %w", err)
+		}
+		if len(bytes.TrimSpace(line)) > 0 {
+			var e Entry
+			if err := json.Unmarshal(line, &e); err != nil {
+				return offset, fmt.Errorf("%w: invalid entry at byte %d: %v", ErrBroken, offset, err)
+			}
+			if err := fn(e); err != nil {
+				return offset, err
+			}
+		}
+		offset += int64(len(line))
+	}
+}
+
+// ReadFile reads the entries of an audit log file without opening it for writing
+func ReadFile(path string) ([]Entry, error) {
+	var entries []Entry
+	_, err := scan(path, 0, func(e Entry) error {
+		entries = append(entries, e)
+		return nil
+	})
+	return entries, err
+}
+
+// Verify checks the hash chain of entries, oldest first, with the key the
+// log was opened with
+func Verify(key []byte, entries []Entry) error {
+	c := chain{key: key}
+	for _, e := range entries {
+		if err := c.next(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Record appends an event that happened while serving r. The time, client
+// IP and request ID are filled in. Failures are logged, not returned: an
+// audit problem should not fail the request being audited.
+func (l *Log) Record(r *http.Request, e Entry) {
+	e.IP = clientIP(r)
+	e.RequestID = logging.RequestID(r.Context())
+	if err := l.Append(e); err != nil {
+		logging.FromContext(r.Context()).Error("Failed to write audit entry", "error", err, "action", e.Action)
+	}
+}
+
+// Append chains and stores an entry. It fails if entries other processes
+// appended do not verify. The file is locked while appending, so that
+// processes sharing it do not fork the chain.
+func (l *Log) Append(e Entry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// Chain onto entries other processes appended
+	if l.file != nil {
+		if err := l.lock.Lock(); err != nil {
+			return err
+		}
+		defer l.lock.Unlock()
+		if err := l.sync(); err != nil {
+			return err
+		}
+	}
+	e.Time = time.Now().UTC()
+	e.PrevHash = l.chain.hash
+	e.Seq = l.chain.seq + 1
+	hash, err := computeHash(e, l.chain.key)
+	if err != nil {
+		return err
+	}
+	e.Hash = hash
+
+	if l.file != nil {
+		line, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		n, err := l.file.Write(append(line, '\n'))
+		l.size += int64(n)
+		if err != nil {
+			return err
+		}
+	}
+	l.chain.hash, l.chain.seq = e.Hash, e.Seq
+	l.keep(e)
+	return nil
+}
+
+// Filter selects entries. Zero fields match everything.
+type Filter struct {
+	Actor   string
+	Action  string // Exact action, or a prefix ending in "." such as "auth."
+	Target  string
+	Outcome string
+	From    time.Time // Inclusive
+	To      time.Time // Exclusive
+	Before  int64     // Only entries with a lower sequence number, for paging
+}
+
+// Match reports whether e passes the filter
+func (f Filter) Match(e Entry) bool {
+	switch {
+	case f.Actor != "" && e.Actor != f.Actor,
+		f.Target != "" && e.Target != f.Target,
+		f.Outcome != "" && e.Outcome != f.Outcome,
+		!f.From.IsZero() && e.Time.Before(f.From),
+		!f.To.IsZero() && !e.Time.Before(f.To),
+		f.Before > 0 && e.Seq >= f.Before:
+		return false
+	}
+	if f.Action != "" {
+		if strings.HasSuffix(f.Action, ".") {
+			return strings.HasPrefix(e.Action, f.Action)
+		}
+		return e.Action == f.Action
+	}
+	return true
+}
+
+// Query returns up to limit matching entries, newest first
+func (l *Log) Query(f Filter, limit int) []Entry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file != nil {
+		// Serve what is in memory if the file cannot be read
+		l.sync()
+	}
+
+	var out []Entry
+	for i := len(l.entries) - 1; i >= 0 && len(out) < limit; i-- {
+		if f.Match(l.entries[i]) {
+			out = append(out, l.entries[i])
+		}
+	}
+	// Look for the rest among the entries no longer in memory
+	if len(out) < limit && l.file != nil && len(l.entries) > 0 && l.entries[0].Seq > 1 {
+		if oldest := l.entries[0].Seq; f.Before == 0 || f.Before > oldest {
+			f.Before = oldest
+		}
+		out = append(out, l.queryFile(f, limit-len(out))...)
+	}
+	return out
+}
+
+// queryFile reads the file for up to limit matching entries, newest first
+func (l *Log) queryFile(f Filter, limit int) []Entry {
+	// The newest matches so far, oldest first
+	var found []Entry
+	_, err := scan(l.path, 0, func(e Entry) error {
+		if f.Match(e) {
+			if len(found) == limit {
+				found = found[1:]
+			}
+			found = append(found, e)
+		}
+		return nil
+	})
+	if err != nil {
+		slog.Error("Failed to read audit log", "error", err, "path", l.path)
+	}
+	slices.Reverse(found)
+	return found
+}
+
+// clientIP is the address the request came from
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
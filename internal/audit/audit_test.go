@@ -0,0 +1,197 @@
+package audit
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+var testKey = []byte("0123456789abcdef0123456789abcdef")
+
+func appendN(t *testing.T, l *Log, n int) {
+	t.Helper()
+	for i := range n {
+		if err := l.Append(Entry{Actor: "admin@example.com", Action: ActionAdmin, Target: strconv.Itoa(i), Outcome: OutcomeSuccess}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func mustRead(t *testing.T, path string) []Entry {
+	t.Helper()
+	entries, err := ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return entries
+}
+
+func TestTamperingDetected(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func(data []byte) []byte
+		key    []byte
+	}{
+		{
+			name: "modified",
+			tamper: func(data []byte) []byte {
+				return bytes.Replace(data, []byte(`"target":"1"`), []byte(`"target":"9"`), 1)
+			},
+		},
+		{
+			name: "removed",
+			tamper: func(data []byte) []byte {
+				lines := bytes.SplitAfter(data, []byte("\n"))
+				return bytes.Join(append(lines[:1:1], lines[2:]...), nil)
+			},
+		},
+		{
+			name: "reordered",
+			tamper: func(data []byte) []byte {
+				lines := bytes.SplitAfter(data, []byte("\n"))
+				lines[1], lines[2] = lines[2], lines[1]
+				return bytes.Join(lines, nil)
+			},
+		},
+		{
+			name:   "wrong key",
+			tamper: func(data []byte) []byte { return data },
+			key:    []byte("another key, another key, another"),
+		},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), "audit.jsonl")
+		l, err := Open(path, testKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		appendN(t, l, 3)
+
+		// Another process, such as the admin CLI, chains onto the log
+		other, err := Open(path, testKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		appendN(t, other, 1)
+		appendN(t, l, 1)
+		if err := Verify(testKey, mustRead(t, path)); err != nil {
+			t.Fatalf("%s: before tampering: %v", tt.name, err)
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, tt.tamper(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		key := tt.key
+		if key == nil {
+			key = testKey
+		}
+		if _, err := Open(path, key); !errors.Is(err, ErrBroken) {
+			t.Errorf("%s: open got %v, want %v", tt.name, err, ErrBroken)
+		}
+	}
+}
+
+// Processes appending at the same time do not fork the chain
+func TestConcurrentAppendsChain(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	var logs []*Log
+	for range 2 {
+		l, err := Open(path, testKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		logs = append(logs, l)
+	}
+
+	var wg sync.WaitGroup
+	for _, l := range logs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 200 {
+				if err := l.Append(Entry{Action: ActionAdmin, Outcome: OutcomeSuccess}); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	entries := mustRead(t, path)
+	if len(entries) != 400 {
+		t.Errorf("got %d entries, want 400", len(entries))
+	}
+	if err := Verify(testKey, entries); err != nil {
+		t.Error(err)
+	}
+}
+
+// A log that shrank under a running process is not appended to
+func TestTruncationRefused(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	l, err := Open(path, testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	appendN(t, l, 3)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data[:bytes.IndexByte(data, '\n')+1], 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Append(Entry{Action: ActionAdmin}); !errors.Is(err, ErrBroken) {
+		t.Errorf("append got %v, want %v", err, ErrBroken)
+	}
+}
+
+func TestQueryBeyondMemory(t *testing.T) {
+	defer func(n int) { memoryEntries = n }(memoryEntries)
+	memoryEntries = 5
+
+	l, err := Open(filepath.Join(t.TempDir(), "audit.jsonl"), testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	appendN(t, l, 25)
+	if len(l.entries) >= 2*memoryEntries {
+		t.Errorf("%d entries in memory, want fewer than %d", len(l.entries), 2*memoryEntries)
+	}
+
+	// Page through everything, newest first
+	var seqs []int64
+	f := Filter{Action: ActionAdmin}
+	for {
+		page := l.Query(f, 4)
+		if len(page) == 0 {
+			break
+		}
+		for _, e := range page {
+			seqs = append(seqs, e.Seq)
+		}
+		f.Before = page[len(page)-1].Seq
+	}
+	if len(seqs) != 25 {
+		t.Fatalf("got %d entries, want 25: %v", len(seqs), seqs)
+	}
+	for i, seq := range seqs {
+		if seq != int64(25-i) {
+			t.Fatalf("entries out of order: %v", seqs)
+		}
+	}
+
+	if got := l.Query(Filter{Target: "2"}, 10); len(got) != 1 || got[0].Seq != 3 {
+		t.Errorf("query for an old entry got %+v", got)
+	}
+}
@@ -0,0 +1,137 @@
+package audit
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/kaustavdm/awwdio/apitypes"
+	"github.com/kaustavdm/awwdio/internal/api/apierror"
+	"github.com/kaustavdm/awwdio/internal/api/openapi"
+	"github.com/kaustavdm/awwdio/internal/respwriter"
+)
+
+const (
+	defaultQueryLimit = 100
+	maxQueryLimit     = 1000
+)
+
+type Handler struct {
+	log *Log
+}
+
+func NewHandler(log *Log) *Handler {
+	return &Handler{log: log}
+}
+
+// Register registers the audit query route. It is meant to be mounted
+// behind admin authentication.
+func (h *Handler) Register(mux *openapi.Mux) {
+	mux.HandleFunc("GET /audit", h.listEntries, openapi.Operation{
+		ID:       "listAuditEntries",
+		Summary:  "Query the audit log, newest first",
+		Response: ListEntriesResponse{},
+		Query: []openapi.Param{
+			{Name: "actor", Description: "Exact match"},
+			{Name: "action", Description: `Exact match, or a prefix ending in "." such as "auth."`},
+			{Name: "target", Description: "Exact match"},
+			{Name: "outcome", Description: "Exact match"},
+			{Name: "from", Description: "Only entries at or after this time (RFC 3339)"},
+			{Name: "to", Description: "Only entries before this time (RFC 3339)"},
+			{Name: "limit", Description: "Page size, 1-1000 (default 100)", Type: "integer"},
+			{Name: "cursor", Description: "next_cursor from the previous page"},
+		},
+	})
+}
+
+type ListEntriesResponse = apitypes.ListAuditEntriesResponse
+
+// listEntries returns audit entries, newest first.
+//
+// Query parameters:
+//   - actor, target, outcome: exact match
+//   - action: exact match, or a prefix ending in "." (e.g. "auth.")
+//   - from, to: time range (RFC 3339, to is exclusive)
+//   - limit: page size, 1-1000 (default 100)
+//   - cursor: next_cursor from the previous page
+func (h *Handler) listEntries(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	query := r.URL.Query()
+	f := Filter{
+		Actor:   query.Get("actor"),
+		Action:  query.Get("action"),
+		Target:  query.Get("target"),
+		Outcome: query.Get("outcome"),
+	}
+
+	var err error
+	if v := query.Get("from"); v != "" {
+		if f.From, err = time.Parse(time.RFC3339, v); err != nil {
+			apierror.Write(w, r, apierror.BadRequest("Invalid from time"))
+			return
+		}
+	}
+	if v := query.Get("to"); v != "" {
+		if f.To, err = time.Parse(time.RFC3339, v); err != nil {
+			apierror.Write(w, r, apierror.BadRequest("Invalid to time"))
+			return
+		}
+	}
+	if v := query.Get("cursor"); v != "" {
+		if f.Before, err = strconv.ParseInt(v, 10, 64); err != nil || f.Before < 1 {
+			apierror.Write(w, r, apierror.BadRequest("Invalid cursor"))
+			return
+		}
+	}
+
+	limit := defaultQueryLimit
+	if v := query.Get("limit"); v != "" {
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > maxQueryLimit {
+			apierror.Write(w, r, apierror.BadRequest("Limit must be between 1 and 1000"))
+			return
+		}
+	}
+
+	resp := ListEntriesResponse{Entries: h.log.Query(f, limit)}
+	if resp.Entries == nil {
+		resp.Entries = []Entry{}
+	}
+	if n := len(resp.Entries); n == limit && resp.Entries[n-1].Seq > 1 {
+		resp.NextCursor = strconv.FormatInt(resp.Entries[n-1].Seq, 10)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// AdminRequests records every request to the admin API with its outcome.
+// actor identifies the authenticated user, so the middleware must run after
+// authentication but before the admin check, to also catch denied attempts.
+func (l *Log) AdminRequests(actor func(*http.Request) string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rec := respwriter.Wrap(w)
+			next.ServeHTTP(rec, r)
+
+			outcome := OutcomeSuccess
+			switch status := rec.Status(); {
+			case status == http.StatusUnauthorized || status == http.StatusForbidden:
+				outcome = OutcomeDenied
+			case status >= 500:
+				outcome = OutcomeError
+			case status >= 400:
+				outcome = OutcomeFailure
+			}
+			l.Record(r, Entry{
+				Actor:   actor(r),
+				Action:  ActionAdmin,
+				Target:  r.Method + " " + r.URL.EscapedPath(),
+				Outcome: outcome,
+				Details: map[string]string{"status": strconv.Itoa(rec.Status())},
+			})
+		})
+	}
+}
@@ -0,0 +1,47 @@
+// Package filelock serializes access to files shared between processes,
+// such as the server and the admin CLI working on the same data directory.
+package filelock
+
+import (
+	"fmt"
+	"os"
+)
+
+// File is an advisory lock held on an open file. The zero value locks
+// nothing.
+type File struct {
+	f *os.File
+}
+
+// Open opens, creating it if needed, the lock file at path
+func Open(path string) (*File, error) {
+	f, err := os.OpenFile(path, os.O_RDONLY|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file: %w", err)
+	}
+	return &File{f: f}, nil
+}
+
+// On locks f, an already open file
+func On(f *os.File) *File {
+	return &File{f: f}
+}
+
+// Lock blocks until the lock is held exclusively
+func (l *File) Lock() error {
+	if l == nil || l.f == nil {
+		return nil
+	}
+	if err := lock(l.f); err != nil {
+		return fmt.Errorf("failed to lock %s: %w", l.f.Name(), err)
+	}
+	return nil
+}
+
+// Unlock releases the lock
+func (l *File) Unlock() {
+	if l == nil || l.f == nil {
+		return
+	}
+	unlock(l.f)
+}
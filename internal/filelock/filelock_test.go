@@ -0,0 +1,44 @@
+//go:build unix
+
+package filelock
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Locks held through separate opens exclude each other, as they would
+// across processes
+func TestLockExcludes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "x.lock")
+	a, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Lock(); err != nil {
+		t.Fatal(err)
+	}
+
+	locked := make(chan struct{})
+	go func() {
+		b.Lock()
+		close(locked)
+	}()
+	select {
+	case <-locked:
+		t.Fatal("second lock acquired while the first is held")
+	case <-time.After(50 * time.Millisecond):
+	}
+	a.Unlock()
+	select {
+	case <-locked:
+	case <-time.After(time.Second):
+		t.Fatal("second lock not acquired after unlock")
+	}
+	b.Unlock()
+}
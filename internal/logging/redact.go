@@ -0,0 +1,227 @@
+package logging
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// contactKeys are attributes that hold an email address or phone number.
+// Their values are masked whatever they look like.
+var contactKeys = map[string]bool{
+	"to":         true,
+	"subject":    true,
+	"identity":   true,
+	"organizer":  true,
+	"recipient":  true,
+	"recipients": true,
+	"invitee":    true,
+	"invitees":   true,
+	"email":      true,
+	"phone":      true,
+}
+
+// secretKeys are attributes whose values are never logged
+var secretKeys = map[string]bool{
+	"token":         true,
+	"otp":           true,
+	"secret":        true,
+	"password":      true,
+	"authorization": true,
+	"cookie":        true,
+	"jwt":           true,
+}
+
+// Patterns caught in any string value, e.g. error messages
+var (
+	emailPattern  = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+	phonePattern  = regexp.MustCompile(`\+[1-9][0-9]{6,14}`)
+	jwtPattern    = regexp.MustCompile(`eyJ[A-Za-z0-9_\-]+\.[A-Za-z0-9_\-]+\.[A-Za-z0-9_\-]+`)
+	invitePattern = regexp.MustCompile(`/invites/[^/\s"]+`)
+	// API keys keep their identifying prefix
+	apiKeyPattern = regexp.MustCompile(`(awk_[0-9a-f]+_)[A-Za-z0-9_\-]+`)
+)
+
+const redacted = "[redacted]"
+
+// Redact wraps h so that contact details and tokens do not reach the logs.
+// Values of contact attributes (to, subject, identity...) are masked to
+// keep just enough to tell users apart, e.g. "a***@example.com" or
+// "+*******0006"; secret attributes (token, otp...) are dropped. Email
+// addresses, phone numbers, JWTs, API keys and invitation links are also masked
+// inside any other string, such as error messages. Structs, maps and
+// slices are logged in their JSON form, redacted field by field.
+func Redact(h slog.Handler) slog.Handler {
+	return redactHandler{h}
+}
+
+type redactHandler struct {
+	slog.Handler
+}
+
+func (h redactHandler) Handle(ctx context.Context, rec slog.Record) error {
+	out := slog.NewRecord(rec.Time, rec.Level, redactString(rec.Message), rec.PC)
+	rec.Attrs(func(a slog.Attr) bool {
+		out.AddAttrs(redactAttr(a))
+		return true
+	})
+	return h.Handler.Handle(ctx, out)
+}
+
+func (h redactHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redactedAttrs := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		redactedAttrs[i] = redactAttr(a)
+	}
+	return redactHandler{h.Handler.WithAttrs(redactedAttrs)}
+}
+
+func (h redactHandler) WithGroup(name string) slog.Handler {
+	return redactHandler{h.Handler.WithGroup(name)}
+}
+
+func redactAttr(a slog.Attr) slog.Attr {
+	a.Value = a.Value.Resolve()
+	key := strings.ToLower(a.Key)
+
+	if secretKeys[key] {
+		return slog.String(a.Key, redacted)
+	}
+
+	switch a.Value.Kind() {
+	case slog.KindGroup:
+		group := a.Value.Group()
+		attrs := make([]any, len(group))
+		for i, ga := range group {
+			attrs[i] = redactAttr(ga)
+		}
+		return slog.Group(a.Key, attrs...)
+	case slog.KindString:
+		if contactKeys[key] {
+			return slog.String(a.Key, maskContact(a.Value.String()))
+		}
+		return slog.String(a.Key, redactString(a.Value.String()))
+	case slog.KindAny:
+		switch v := a.Value.Any().(type) {
+		case error:
+			return slog.String(a.Key, redactString(v.Error()))
+		case []string:
+			masked := make([]string, len(v))
+			for i, s := range v {
+				if contactKeys[key] {
+					masked[i] = maskContact(s)
+				} else {
+					masked[i] = redactString(s)
+				}
+			}
+			return slog.Any(a.Key, masked)
+		case *string:
+			if v != nil {
+				return redactAttr(slog.String(a.Key, *v))
+			}
+		default:
+			if isComposite(v) {
+				return slog.Any(a.Key, redactComposite(key, v))
+			}
+		}
+	}
+	return a
+}
+
+// isComposite reports whether v is a struct, map or slice, whose fields
+// may hold contact details
+func isComposite(v any) bool {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return false
+		}
+		rv = rv.Elem()
+	}
+	switch rv.Kind() {
+	case reflect.Struct, reflect.Map, reflect.Slice, reflect.Array:
+		return true
+	}
+	return false
+}
+
+// redactComposite returns the JSON form of v with each field redacted by
+// its JSON name, as attributes are by their key
+func redactComposite(key string, v any) any {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return redacted
+	}
+	var decoded any
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		return redacted
+	}
+	return redactJSON(key, decoded)
+}
+
+func redactJSON(key string, v any) any {
+	if secretKeys[key] {
+		return redacted
+	}
+	switch v := v.(type) {
+	case map[string]any:
+		for k, field := range v {
+			v[k] = redactJSON(strings.ToLower(k), field)
+		}
+	case []any:
+		for i, elem := range v {
+			v[i] = redactJSON(key, elem)
+		}
+	case string:
+		if contactKeys[key] {
+			return maskContact(v)
+		}
+		return redactString(v)
+	}
+	return v
+}
+
+// redactString masks contact details and tokens found in s
+func redactString(s string) string {
+	s = jwtPattern.ReplaceAllString(s, redacted)
+	s = apiKeyPattern.ReplaceAllString(s, "${1}"+redacted)
+	s = invitePattern.ReplaceAllString(s, "/invites/"+redacted)
+	s = emailPattern.ReplaceAllStringFunc(s, maskEmail)
+	s = phonePattern.ReplaceAllStringFunc(s, maskPhone)
+	return s
+}
+
+// maskContact masks a value known to be an email address or phone number
+func maskContact(s string) string {
+	switch {
+	case s == "":
+		return s
+	case strings.Contains(s, "@"):
+		return maskEmail(s)
+	case strings.HasPrefix(s, "+"):
+		return maskPhone(s)
+	default:
+		// Unknown shape, keep the first character only
+		return s[:1] + "***"
+	}
+}
+
+// maskEmail keeps the first character of the local part and the domain
+func maskEmail(s string) string {
+	local, domain, ok := strings.Cut(s, "@")
+	if !ok || local == "" {
+		return redacted
+	}
+	return local[:1] + "***@" + domain
+}
+
+// maskPhone keeps the last four digits
+func maskPhone(s string) string {
+	if len(s) <= 5 {
+		return redacted
+	}
+	return "+" + strings.Repeat("*", len(s)-5) + s[len(s)-4:]
+}
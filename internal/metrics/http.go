@@ -0,0 +1,98 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kaustavdm/awwdio/internal/respwriter"
+)
+
+var (
+	httpRequests = NewCounter("awwdio_http_requests_total",
+		"HTTP requests handled, by route pattern, method and status code.",
+		"route", "method", "code")
+	httpDuration = NewHistogram("awwdio_http_request_duration_seconds",
+		"Time taken to handle HTTP requests, by route pattern and method.",
+		nil, "route", "method")
+)
+
+type routeKey struct{}
+
+// route receives the full pattern of the handler that served a request
+type route struct {
+	pattern string
+}
+
+// Middleware records the count and latency of every request, labelled by
+// the route pattern that matched rather than the raw path, so that path
+// parameters do not create new series.
+//
+// Requests handled by muxes mounted below the top-level mux report their
+// full pattern when the inner mux is wrapped with Routes. Otherwise the
+// top-level pattern (e.g. "/api/") is used.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rt := &route{}
+		r = r.WithContext(context.WithValue(r.Context(), routeKey{}, rt))
+		rec := respwriter.Wrap(w)
+
+		next.ServeHTTP(rec, r)
+
+		pattern := Route(r)
+		if pattern == "" {
+			pattern = "unmatched"
+		}
+		method := methodLabel(r.Method)
+		httpRequests.Inc(pattern, method, strconv.Itoa(rec.Status()))
+		httpDuration.Observe(time.Since(start).Seconds(), pattern, method)
+	})
+}
+
+// methodLabel returns the method as a label value. Clients can send any
+// method, so unknown ones share one series.
+func methodLabel(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodOptions, http.MethodConnect, http.MethodTrace:
+		return method
+	}
+	return "OTHER"
+}
+
+// Routes reports the pattern matched by mux, which is mounted under prefix,
+// to Middleware
+func Routes(prefix string, mux http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mux.ServeHTTP(w, r)
+
+		// ServeMux sets Pattern on the request it was given. Inner muxes
+		// return first, so the most specific pattern wins.
+		rt, ok := r.Context().Value(routeKey{}).(*route)
+		if !ok || rt.pattern != "" || r.Pattern == "" {
+			return
+		}
+		rt.pattern = prefix + stripMethod(r.Pattern)
+	})
+}
+
+// Route returns the full pattern that matched r, without the method, or ""
+// if none did yet. r must have passed through Middleware; other middleware
+// running inside it can call Route after serving the request.
+func Route(r *http.Request) string {
+	if rt, ok := r.Context().Value(routeKey{}).(*route); ok && rt.pattern != "" {
+		return rt.pattern
+	}
+	return stripMethod(r.Pattern)
+}
+
+// stripMethod drops the method from a pattern, as it is a label of its own
+func stripMethod(pattern string) string {
+	if _, path, ok := strings.Cut(pattern, " "); ok {
+		return path
+	}
+	return pattern
+}
@@ -0,0 +1,301 @@
+// Package metrics keeps application metrics and serves them in the
+// Prometheus text exposition format. It implements the small subset of the
+// Prometheus client the server needs (labelled counters, gauges and
+// histograms) so that no client library is pulled in.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefBuckets are the default histogram buckets, in seconds
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// collector is a metric family that can write itself out
+type collector interface {
+	name() string
+	write(w io.Writer)
+}
+
+var (
+	registryMu sync.Mutex
+	registry   []collector
+)
+
+// register adds c to the default registry. Metric names must be unique.
+func register(c collector) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	for _, existing := range registry {
+		if existing.name() == c.name() {
+			panic("metrics: duplicate metric " + c.name())
+		}
+	}
+	registry = append(registry, c)
+}
+
+// Handler serves all registered metrics
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		registryMu.Lock()
+		collectors := slices.Clone(registry)
+		registryMu.Unlock()
+		slices.SortFunc(collectors, func(a, b collector) int { return strings.Compare(a.name(), b.name()) })
+
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		for _, c := range collectors {
+			c.write(w)
+		}
+	})
+}
+
+// family holds the label values of one metric family
+type family[V any] struct {
+	fname  string
+	help   string
+	typ    string
+	labels []string
+
+	mu     sync.Mutex
+	series map[string]*V
+	values map[string][]string // series key -> label values
+	newV   func() *V
+}
+
+func newFamily[V any](name, help, typ string, labels []string, newV func() *V) *family[V] {
+	f := &family[V]{
+		fname:  name,
+		help:   help,
+		typ:    typ,
+		labels: labels,
+		series: map[string]*V{},
+		values: map[string][]string{},
+		newV:   newV,
+	}
+	if len(labels) == 0 {
+		// A metric without labels has exactly one series, reported from the start
+		f.with(nil)
+	}
+	return f
+}
+
+func (f *family[V]) name() string { return f.fname }
+
+// with returns the series for labelValues, creating it on first use
+func (f *family[V]) with(labelValues []string) *V {
+	if len(labelValues) != len(f.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", f.fname, len(f.labels), len(labelValues)))
+	}
+	key := strings.Join(labelValues, "\xff")
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	v, ok := f.series[key]
+	if !ok {
+		v = f.newV()
+		f.series[key] = v
+		f.values[key] = slices.Clone(labelValues)
+	}
+	return v
+}
+
+// each calls fn for every series in label order, holding the family lock
+func (f *family[V]) each(w io.Writer, fn func(labels string, v *V)) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", f.fname, escapeHelp(f.help), f.fname, f.typ)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	keys := make([]string, 0, len(f.series))
+	for k := range f.series {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	for _, k := range keys {
+		fn(formatLabels(f.labels, f.values[k]), f.series[k])
+	}
+}
+
+// Counter is a monotonically increasing value, partitioned by labels
+type Counter struct {
+	f *family[float64]
+}
+
+// NewCounter registers a counter with the given label names
+func NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{f: newFamily(name, help, "counter", labels, func() *float64 { return new(float64) })}
+	register(c)
+	return c
+}
+
+// Inc adds one to the series for labelValues
+func (c *Counter) Inc(labelValues ...string) { c.Add(1, labelValues...) }
+
+// Add adds v, which must not be negative, to the series for labelValues
+func (c *Counter) Add(v float64, labelValues ...string) {
+	p := c.f.with(labelValues)
+	c.f.mu.Lock()
+	*p += v
+	c.f.mu.Unlock()
+}
+
+func (c *Counter) name() string { return c.f.fname }
+
+func (c *Counter) write(w io.Writer) {
+	c.f.each(w, func(labels string, v *float64) {
+		fmt.Fprintf(w, "%s%s %s\n", c.f.fname, labels, formatFloat(*v))
+	})
+}
+
+// Gauge is a value that can go up and down, partitioned by labels
+type Gauge struct {
+	f *family[float64]
+}
+
+// NewGauge registers a gauge with the given label names
+func NewGauge(name, help string, labels ...string) *Gauge {
+	g := &Gauge{f: newFamily(name, help, "gauge", labels, func() *float64 { return new(float64) })}
+	register(g)
+	return g
+}
+
+// Inc adds one to the series for labelValues
+func (g *Gauge) Inc(labelValues ...string) { g.Add(1, labelValues...) }
+
+// Dec subtracts one from the series for labelValues
+func (g *Gauge) Dec(labelValues ...string) { g.Add(-1, labelValues...) }
+
+// Add adds v to the series for labelValues
+func (g *Gauge) Add(v float64, labelValues ...string) {
+	p := g.f.with(labelValues)
+	g.f.mu.Lock()
+	*p += v
+	g.f.mu.Unlock()
+}
+
+// Set sets the series for labelValues to v
+func (g *Gauge) Set(v float64, labelValues ...string) {
+	p := g.f.with(labelValues)
+	g.f.mu.Lock()
+	*p = v
+	g.f.mu.Unlock()
+}
+
+func (g *Gauge) name() string { return g.f.fname }
+
+func (g *Gauge) write(w io.Writer) {
+	g.f.each(w, func(labels string, v *float64) {
+		fmt.Fprintf(w, "%s%s %s\n", g.f.fname, labels, formatFloat(*v))
+	})
+}
+
+// Histogram counts observations into cumulative buckets, partitioned by labels
+type Histogram struct {
+	f       *family[histogramSeries]
+	buckets []float64
+}
+
+type histogramSeries struct {
+	counts []uint64 // per bucket, not cumulative
+	count  uint64
+	sum    float64
+}
+
+// NewHistogram registers a histogram with the given upper bucket bounds,
+// or DefBuckets when buckets is nil
+func NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	if buckets == nil {
+		buckets = DefBuckets
+	}
+	buckets = slices.Clone(buckets)
+	slices.Sort(buckets)
+	h := &Histogram{buckets: buckets}
+	h.f = newFamily(name, help, "histogram", labels, func() *histogramSeries {
+		return &histogramSeries{counts: make([]uint64, len(buckets))}
+	})
+	register(h)
+	return h
+}
+
+// Observe records v in the series for labelValues
+func (h *Histogram) Observe(v float64, labelValues ...string) {
+	s := h.f.with(labelValues)
+	i, _ := slices.BinarySearch(h.buckets, v)
+
+	h.f.mu.Lock()
+	defer h.f.mu.Unlock()
+	if i < len(s.counts) {
+		s.counts[i]++
+	}
+	s.count++
+	s.sum += v
+}
+
+func (h *Histogram) name() string { return h.f.fname }
+
+func (h *Histogram) write(w io.Writer) {
+	h.f.each(w, func(labels string, s *histogramSeries) {
+		var cumulative uint64
+		for i, bound := range h.buckets {
+			cumulative += s.counts[i]
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.f.fname, withLabel(labels, "le", formatFloat(bound)), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.f.fname, withLabel(labels, "le", "+Inf"), s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.f.fname, labels, formatFloat(s.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.f.fname, labels, s.count)
+	})
+}
+
+// formatLabels renders {a="x",b="y"}, or nothing when there are no labels
+func formatLabels(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(name)
+		b.WriteString(`="`)
+		b.WriteString(escapeLabel(values[i]))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// withLabel appends one more label to rendered labels
+func withLabel(labels, name, value string) string {
+	pair := name + `="` + escapeLabel(value) + `"`
+	if labels == "" {
+		return "{" + pair + "}"
+	}
+	return labels[:len(labels)-1] + "," + pair + "}"
+}
+
+var (
+	labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeLabel(v string) string { return labelEscaper.Replace(v) }
+
+func escapeHelp(v string) string { return helpEscaper.Replace(v) }
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,117 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestExposition(t *testing.T) {
+	counter := NewCounter("test_events_total", "Events seen.\nSecond line.", "kind")
+	counter.Inc("b")
+	counter.Add(2.5, "a")
+	counter.Inc(`quote"back\slash` + "\nnewline")
+
+	gauge := NewGauge("test_open", "Open things.")
+	gauge.Inc()
+	gauge.Inc()
+	gauge.Dec()
+
+	histogram := NewHistogram("test_latency_seconds", "Latency.", []float64{1, 0.5}, "op")
+	histogram.Observe(0.2, "read")
+	histogram.Observe(0.5, "read")
+	histogram.Observe(0.7, "read")
+	histogram.Observe(3, "read")
+
+	tests := []struct {
+		name string
+		c    collector
+		want string
+	}{
+		{
+			name: "counter",
+			c:    counter,
+			want: `# HELP test_events_total Events seen.\nSecond line.
+# TYPE test_events_total counter
+test_events_total{kind="a"} 2.5
+test_events_total{kind="b"} 1
+test_events_total{kind="quote\"back\\slash\nnewline"} 1
+`,
+		},
+		{
+			name: "gauge without labels",
+			c:    gauge,
+			want: `# HELP test_open Open things.
+# TYPE test_open gauge
+test_open 1
+`,
+		},
+		{
+			name: "histogram",
+			c:    histogram,
+			want: `# HELP test_latency_seconds Latency.
+# TYPE test_latency_seconds histogram
+test_latency_seconds_bucket{op="read",le="0.5"} 2
+test_latency_seconds_bucket{op="read",le="1"} 3
+test_latency_seconds_bucket{op="read",le="+Inf"} 4
+test_latency_seconds_sum{op="read"} 4.4
+test_latency_seconds_count{op="read"} 4
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b strings.Builder
+			tt.c.write(&b)
+			if b.String() != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", b.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestHandler(t *testing.T) {
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	body := rec.Body.String()
+	first := strings.Index(body, "# HELP awwdio_http_request_duration_seconds ")
+	second := strings.Index(body, "# HELP awwdio_http_requests_total ")
+	if first < 0 || second < 0 || first > second {
+		t.Errorf("families missing or not sorted by name:\n%s", body)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	inner := http.NewServeMux()
+	inner.HandleFunc("GET /items/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+	outer := http.NewServeMux()
+	outer.Handle("/api/", http.StripPrefix("/api", Routes("/api", inner)))
+	handler := Middleware(outer)
+
+	tests := []struct {
+		method, path string
+		want         string
+	}{
+		{http.MethodGet, "/api/items/42", `awwdio_http_requests_total{route="/api/items/{id}",method="GET",code="418"} 1`},
+		{"BREW", "/api/items/42", `awwdio_http_requests_total{route="/api/",method="OTHER",code="405"} 1`},
+		{http.MethodGet, "/nowhere", `awwdio_http_requests_total{route="unmatched",method="GET",code="404"} 1`},
+	}
+	for _, tt := range tests {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(tt.method, tt.path, nil))
+	}
+
+	var b strings.Builder
+	httpRequests.write(&b)
+	for _, tt := range tests {
+		if !strings.Contains(b.String(), tt.want+"\n") {
+			t.Errorf("%s %s: missing %s in:\n%s", tt.method, tt.path, tt.want, b.String())
+		}
+	}
+}
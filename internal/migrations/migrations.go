@@ -0,0 +1,200 @@
+// Package migrations lists the changes made to how data is stored, applied
+// with `awwdio migrate up` and undone with `awwdio migrate down`.
+package migrations
+
+import (
+	"github.com/kaustavdm/awwdio/config"
+	"github.com/kaustavdm/awwdio/internal/api/auth"
+	"github.com/kaustavdm/awwdio/internal/api/calls"
+	"github.com/kaustavdm/awwdio/internal/normalize"
+	"github.com/kaustavdm/awwdio/internal/store"
+)
+
+// All returns every migration, oldest first. Append new ones at the end.
+func All(cfg *config.Config) []store.Migration {
+	return []store.Migration{
+		{
+			Version: 1,
+			Name:    "normalize-user-subjects",
+			Up:      func(st *store.Store) error { return normalizeUsers(st, cfg.DefaultCountryCode) },
+			Down:    func(st *store.Store) error { return restoreUsers(st, cfg.DefaultCountryCode) },
+		},
+		{
+			Version: 2,
+			Name:    "normalize-call-contacts",
+			Up:      func(st *store.Store) error { return normalizeCalls(st, cfg.DefaultCountryCode) },
+			Down:    restoreCalls,
+		},
+	}
+}
+
+// usersBackup keeps the user records normalizeUsers replaced, for
+// restoreUsers
+const usersBackup = "users_before_normalize"
+
+// normalizeUsers re-keys users recorded before subjects were normalized,
+// merging those that turn out to be the same person. Subjects that cannot
+// be normalized are left alone.
+func normalizeUsers(st *store.Store, defaultCountry string) error {
+	users, err := auth.NewUserCollection(st)
+	if err != nil {
+		return err
+	}
+	backup, err := store.NewCollection[auth.User](st, usersBackup)
+	if err != nil {
+		return err
+	}
+
+	groups := map[string][]auth.User{}
+	for _, u := range users.List() {
+		subject, err := normalize.Subject(u.Subject, defaultCountry)
+		if err != nil {
+			subject = u.Subject
+		}
+		groups[subject] = append(groups[subject], u)
+	}
+
+	for subject, group := range groups {
+		if len(group) == 1 && group[0].Subject == subject {
+			continue
+		}
+		merged := auth.User{Subject: subject}
+		for _, u := range group {
+			if err := backup.Put(u.Subject, u); err != nil {
+				return err
+			}
+			if merged.CreatedAt.IsZero() || u.CreatedAt.Before(merged.CreatedAt) {
+				merged.CreatedAt = u.CreatedAt
+			}
+			if u.LastLoginAt.After(merged.LastLoginAt) {
+				merged.LastLoginAt = u.LastLoginAt
+			}
+			// One disabled record disables the person
+			if u.Disabled() && (!merged.Disabled() || u.DisabledAt.Before(merged.DisabledAt)) {
+				merged.DisabledAt = u.DisabledAt
+			}
+		}
+		for _, u := range group {
+			if err := users.Delete(u.Subject); err != nil {
+				return err
+			}
+		}
+		if err := users.Put(subject, merged); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// restoreUsers puts back the records normalizeUsers replaced, as they
+// were, dropping the merged ones
+func restoreUsers(st *store.Store, defaultCountry string) error {
+	users, err := auth.NewUserCollection(st)
+	if err != nil {
+		return err
+	}
+	backup, err := store.NewCollection[auth.User](st, usersBackup)
+	if err != nil {
+		return err
+	}
+
+	originals := backup.List()
+	for _, u := range originals {
+		if subject, err := normalize.Subject(u.Subject, defaultCountry); err == nil {
+			if err := users.Delete(subject); err != nil {
+				return err
+			}
+		}
+	}
+	for _, u := range originals {
+		if err := users.Put(u.Subject, u); err != nil {
+			return err
+		}
+		if err := backup.Delete(u.Subject); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// callsBackup keeps the contacts of the calls normalizeCalls changed, for
+// restoreCalls
+const callsBackup = "calls_before_normalize"
+
+// callContacts are the contacts of a call as they were stored
+type callContacts struct {
+	ID        string   `json:"id"`
+	Organizer string   `json:"organizer"`
+	Invitees  []string `json:"invitees"`
+}
+
+// normalizeCalls normalizes the organizer and invitees of calls scheduled
+// before contacts were normalized, so they match the subjects users log in
+// with. Invitations keep their tokens, so links already sent still work.
+// Contacts that cannot be normalized are left alone.
+func normalizeCalls(st *store.Store, defaultCountry string) error {
+	stored, err := store.NewCollection[calls.Call](st, "calls")
+	if err != nil {
+		return err
+	}
+	backup, err := store.NewCollection[callContacts](st, callsBackup)
+	if err != nil {
+		return err
+	}
+
+	normalized := func(contact string) string {
+		if subject, err := normalize.Subject(contact, defaultCountry); err == nil {
+			return subject
+		}
+		return contact
+	}
+	for _, call := range stored.List() {
+		original := callContacts{ID: call.ID, Organizer: call.Organizer}
+		call.Organizer = normalized(call.Organizer)
+		changed := call.Organizer != original.Organizer
+		for i, invitee := range call.Invitees {
+			original.Invitees = append(original.Invitees, invitee.Contact)
+			call.Invitees[i].Contact = normalized(invitee.Contact)
+			changed = changed || call.Invitees[i].Contact != invitee.Contact
+		}
+		if !changed {
+			continue
+		}
+		if err := backup.Put(call.ID, original); err != nil {
+			return err
+		}
+		if err := stored.Put(call.ID, call); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// restoreCalls puts back the contacts normalizeCalls replaced, on the
+// calls that still exist
+func restoreCalls(st *store.Store) error {
+	stored, err := store.NewCollection[calls.Call](st, "calls")
+	if err != nil {
+		return err
+	}
+	backup, err := store.NewCollection[callContacts](st, callsBackup)
+	if err != nil {
+		return err
+	}
+
+	for _, original := range backup.List() {
+		if call, ok := stored.Get(original.ID); ok && len(call.Invitees) == len(original.Invitees) {
+			call.Organizer = original.Organizer
+			for i := range call.Invitees {
+				call.Invitees[i].Contact = original.Invitees[i]
+			}
+			if err := stored.Put(call.ID, call); err != nil {
+				return err
+			}
+		}
+		if err := backup.Delete(original.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package migrations
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/kaustavdm/awwdio/config"
+	"github.com/kaustavdm/awwdio/internal/api/auth"
+	"github.com/kaustavdm/awwdio/internal/api/calls"
+	"github.com/kaustavdm/awwdio/internal/store"
+)
+
+func TestNormalizeUserSubjects(t *testing.T) {
+	st, err := store.Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	users, err := auth.NewUserCollection(st)
+	if err != nil {
+		t.Fatal(err)
+	}
+	day := func(d int) time.Time { return time.Date(2025, 1, d, 0, 0, 0, 0, time.UTC) }
+	before := []auth.User{
+		{Subject: " Bob@Example.com", CreatedAt: day(1), LastLoginAt: day(2)},
+		{Subject: "(415) 555-0100", CreatedAt: day(5), LastLoginAt: day(6)},
+		{Subject: "bob@example.com", CreatedAt: day(3), LastLoginAt: day(4), DisabledAt: day(4)},
+		{Subject: "carol@example.com", CreatedAt: day(1), LastLoginAt: day(1)},
+	}
+	for _, u := range before {
+		if err := users.Put(u.Subject, u); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	all := All(&config.Config{DefaultCountryCode: "1"})
+	if _, err := st.MigrateUp(all, 1); err != nil {
+		t.Fatal(err)
+	}
+	want := []auth.User{
+		{Subject: "+14155550100", CreatedAt: day(5), LastLoginAt: day(6)},
+		{Subject: "bob@example.com", CreatedAt: day(1), LastLoginAt: day(4), DisabledAt: day(4)},
+		{Subject: "carol@example.com", CreatedAt: day(1), LastLoginAt: day(1)},
+	}
+	if got := users.List(); !reflect.DeepEqual(got, want) {
+		t.Errorf("after up:\n got %+v\nwant %+v", got, want)
+	}
+	if version, err := st.Version(); err != nil || version != 1 {
+		t.Errorf("version %d (%v), want 1", version, err)
+	}
+
+	if _, err := st.MigrateDown(all, 0); err != nil {
+		t.Fatal(err)
+	}
+	if got := users.List(); !reflect.DeepEqual(got, before) {
+		t.Errorf("after down:\n got %+v\nwant %+v", got, before)
+	}
+	if version, err := st.Version(); err != nil || version != 0 {
+		t.Errorf("version %d (%v), want 0", version, err)
+	}
+}
+
+func TestNormalizeCallContacts(t *testing.T) {
+	st, err := store.Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored, err := store.NewCollection[calls.Call](st, "calls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := []calls.Call{
+		{ID: "a", Organizer: "Alice@Example.com", Invitees: []calls.Invitee{
+			{Contact: "(415) 555-0100", Token: "t1"},
+			{Contact: "not a contact", Token: "t2"},
+		}},
+		{ID: "b", Organizer: "bob@example.com", Invitees: []calls.Invitee{{Contact: "+14155550100", Token: "t3"}}},
+	}
+	for _, c := range before {
+		if err := stored.Put(c.ID, c); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	all := All(&config.Config{DefaultCountryCode: "1"})
+	if _, err := st.MigrateUp(all, 0); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := stored.Get("a")
+	if !got.IsMember("alice@example.com") || !got.IsMember("+14155550100") || !got.IsMember("not a contact") {
+		t.Errorf("after up: %+v", got)
+	}
+	if got.Invitees[0].Token != "t1" {
+		t.Errorf("invitation token changed: %+v", got.Invitees[0])
+	}
+
+	if _, err := st.MigrateDown(all, 1); err != nil {
+		t.Fatal(err)
+	}
+	if got := stored.List(); !reflect.DeepEqual(got, before) {
+		t.Errorf("after down:\n got %+v\nwant %+v", got, before)
+	}
+}
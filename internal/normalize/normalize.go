@@ -0,0 +1,119 @@
+// Package normalize puts email addresses and phone numbers in one canonical
+// form, so that however a user types their contact, they get the same
+// identity.
+package normalize
+
+import (
+	"errors"
+	"net/mail"
+	"regexp"
+	"strings"
+)
+
+var (
+	ErrInvalidEmail = errors.New("invalid email address")
+	ErrInvalidPhone = errors.New("invalid phone number")
+	// ErrNoCountry is returned for national numbers when no default
+	// country code is configured
+	ErrNoCountry = errors.New("phone number has no country code")
+)
+
+var e164Pattern = regexp.MustCompile(`^\+[1-9][0-9]{6,14}$`)
+
+// Countries where the leading 0 of national numbers is part of the
+// subscriber number, not a trunk prefix to drop
+var keepTrunkZero = map[string]bool{
+	"39": true, // Italy
+}
+
+// Contact normalizes an email address or phone number, depending on the
+// OTP channel ("email" or "sms"). defaultCountry is the calling code
+// assumed for phone numbers without one.
+func Contact(channel, contact, defaultCountry string) (string, error) {
+	if channel == "email" {
+		return Email(contact)
+	}
+	return Phone(contact, defaultCountry)
+}
+
+// Subject normalizes a user identity whose channel is not known: an email
+// address if it has an @, a phone number otherwise
+func Subject(subject, defaultCountry string) (string, error) {
+	if strings.Contains(subject, "@") {
+		return Email(subject)
+	}
+	return Phone(subject, defaultCountry)
+}
+
+// Email returns an address trimmed and lowercased. Mailbox names are case
+// sensitive in theory, but no mail provider in use treats them so. Aliases
+// (user+tag) and dots are kept: dropping them is only safe for some
+// providers, and OTPs must reach the address as typed.
+func Email(s string) (string, error) {
+	// A trailing dot makes the domain fully qualified, nothing more
+	s = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(s)), ".")
+	addr, err := mail.ParseAddress(s)
+	if err != nil || addr.Address != s {
+		return "", ErrInvalidEmail
+	}
+	if _, domain, _ := strings.Cut(s, "@"); !strings.Contains(domain, ".") {
+		return "", ErrInvalidEmail
+	}
+	return s, nil
+}
+
+// Phone returns a phone number in E.164 format. Spaces, dashes, dots and
+// parentheses are dropped, a 00 international prefix becomes +, and the
+// "(0)" some write after the country code is removed. Numbers without a
+// country code get defaultCountry (a calling code such as "1" or "+44"),
+// dropping their trunk 0.
+func Phone(s, defaultCountry string) (string, error) {
+	s = strings.TrimSpace(s)
+	international := false
+	switch {
+	case strings.HasPrefix(s, "+"):
+		international = true
+		s = s[1:]
+		// +44 (0)20 ...: the 0 is only dialled nationally
+		if i := strings.Index(s, "(0)"); i >= 0 && i <= 4 {
+			s = s[:i] + s[i+3:]
+		}
+	case strings.HasPrefix(s, "00"):
+		international = true
+		s = s[2:]
+	}
+
+	var digits strings.Builder
+	for _, c := range s {
+		switch {
+		case c >= '0' && c <= '9':
+			digits.WriteRune(c)
+		case c == ' ' || c == '-' || c == '.' || c == '(' || c == ')' || c == '/':
+			// Formatting
+		default:
+			// Letters, extensions and the like
+			return "", ErrInvalidPhone
+		}
+	}
+	number := digits.String()
+
+	if !international {
+		country := strings.TrimPrefix(strings.TrimSpace(defaultCountry), "+")
+		if country == "" {
+			return "", ErrNoCountry
+		}
+		switch {
+		case country == "1" && len(number) == 11 && number[0] == '1':
+			// North American numbers are often written with the 1 prefix
+			number = number[1:]
+		case strings.HasPrefix(number, "0") && !keepTrunkZero[country]:
+			number = number[1:]
+		}
+		number = country + number
+	}
+
+	if !e164Pattern.MatchString("+" + number) {
+		return "", ErrInvalidPhone
+	}
+	return "+" + number, nil
+}
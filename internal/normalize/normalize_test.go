@@ -0,0 +1,91 @@
+package normalize
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestEmail(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+		err  error
+	}{
+		{"alice@example.com", "alice@example.com", nil},
+		{"Alice@Example.com", "alice@example.com", nil},
+		{"  ALICE@EXAMPLE.COM\n", "alice@example.com", nil},
+		{"alice@example.com.", "alice@example.com", nil},
+		{"alice+calls@example.com", "alice+calls@example.com", nil},
+		{"a.lice@gmail.com", "a.lice@gmail.com", nil},
+		{"o'brien@example.ie", "o'brien@example.ie", nil},
+		{"alice@mail.example.co.uk", "alice@mail.example.co.uk", nil},
+		{"", "", ErrInvalidEmail},
+		{"alice", "", ErrInvalidEmail},
+		{"alice@localhost", "", ErrInvalidEmail},
+		{"@example.com", "", ErrInvalidEmail},
+		{"alice@@example.com", "", ErrInvalidEmail},
+		{"Alice <alice@example.com>", "", ErrInvalidEmail},
+		{"alice@example.com, bob@example.com", "", ErrInvalidEmail},
+	}
+	for _, tt := range tests {
+		got, err := Email(tt.in)
+		if got != tt.want || !errors.Is(err, tt.err) {
+			t.Errorf("Email(%q) = %q, %v; want %q, %v", tt.in, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+func TestPhone(t *testing.T) {
+	tests := []struct {
+		in      string
+		country string
+		want    string
+		err     error
+	}{
+		// Already E.164
+		{"+15550000000", "", "+15550000000", nil},
+		{"+447700900123", "1", "+447700900123", nil},
+		// Formatting
+		{"+1 (555) 000-0000", "", "+15550000000", nil},
+		{"+1.555.000.0000", "", "+15550000000", nil},
+		{"  +91 98765 43210 ", "", "+919876543210", nil},
+		{"+49 30/1234567", "", "+49301234567", nil},
+		{"+44 (0)20 7946 0958", "", "+442079460958", nil},
+		// International prefix
+		{"0044 7700 900123", "", "+447700900123", nil},
+		{"00 33 1 23 45 67 89", "", "+33123456789", nil},
+		// National numbers with a default country
+		{"(555) 000-0000", "1", "+15550000000", nil},
+		{"1-555-000-0000", "1", "+15550000000", nil},
+		{"555 000 0000", "+1", "+15550000000", nil},
+		{"07700 900123", "44", "+447700900123", nil},
+		{"020 7946 0958", "44", "+442079460958", nil},
+		{"098765 43210", "91", "+919876543210", nil},
+		{"06 12 34 56 78", "33", "+33612345678", nil},
+		{"06 1234 5678", "39", "+390612345678", nil},
+		// Not normalizable
+		{"555 000 0000", "", "", ErrNoCountry},
+		{"", "1", "", ErrInvalidPhone},
+		{"+1 555 CALL NOW", "", "", ErrInvalidPhone},
+		{"+1 555 000 0000 ext. 12", "", "", ErrInvalidPhone},
+		{"+0 555 000 0000", "", "", ErrInvalidPhone},
+		{"+12345", "", "", ErrInvalidPhone},
+		{"+1234567890123456", "", "", ErrInvalidPhone},
+		{"alice@example.com", "1", "", ErrInvalidPhone},
+	}
+	for _, tt := range tests {
+		got, err := Phone(tt.in, tt.country)
+		if got != tt.want || !errors.Is(err, tt.err) {
+			t.Errorf("Phone(%q, %q) = %q, %v; want %q, %v", tt.in, tt.country, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+func TestContact(t *testing.T) {
+	if got, _ := Contact("email", "Bob@Example.com", "1"); got != "bob@example.com" {
+		t.Errorf("email contact = %q, want %q", got, "bob@example.com")
+	}
+	if got, _ := Contact("sms", "(555) 000-0000", "1"); got != "+15550000000" {
+		t.Errorf("sms contact = %q, want %q", got, "+15550000000")
+	}
+}
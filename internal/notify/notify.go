@@ -0,0 +1,125 @@
+// Package notify delivers email and SMS notifications such as call
+// invitations and reminders.
+package notify
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"sync"
+
+	"github.com/kaustavdm/awwdio/config"
+)
+
+// Message is a notification addressed to an email address or phone number
+type Message struct {
+	To          string
+	Subject     string // Ignored for SMS
+	Body        string
+	Attachments []Attachment // Ignored for SMS
+}
+
+// Attachment is a file attached to an email
+type Attachment struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+}
+
+// Notifier sends a single message
+type Notifier interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// IsEmail reports whether a contact is an email address rather than a phone number
+func IsEmail(contact string) bool {
+	return strings.Contains(contact, "@")
+}
+
+// Router sends email and SMS messages through separate notifiers
+type Router struct {
+	Email Notifier
+	SMS   Notifier
+}
+
+// Send delivers msg through the notifier matching its recipient
+func (r *Router) Send(ctx context.Context, msg Message) error {
+	if IsEmail(msg.To) {
+		return r.Email.Send(ctx, msg)
+	}
+	return r.SMS.Send(ctx, msg)
+}
+
+// New builds a notifier that follows the current configuration. Channels
+// that are not configured log and drop their messages.
+func New(cfg *config.Provider) Notifier {
+	return &configured{config: cfg}
+}
+
+// configured routes each message using the configuration current at send time
+type configured struct {
+	config *config.Provider
+}
+
+func (n *configured) Send(ctx context.Context, msg Message) error {
+	cfg := n.config.Get()
+	router := &Router{Email: Discard{Channel: "email"}, SMS: Discard{Channel: "sms"}}
+	if cfg.SMTPHost != "" {
+		router.Email = NewSMTP(cfg)
+	}
+	if cfg.TwilioMessagingFrom != "" {
+		router.SMS = NewTwilio(cfg)
+	}
+	return router.Send(ctx, msg)
+}
+
+// Discard logs and drops messages for a channel that is not configured
+type Discard struct {
+	Channel string
+}
+
+func (d Discard) Send(ctx context.Context, msg Message) error {
+	slog.Warn("Notification channel not configured, dropping message", "channel", d.Channel, "subject", msg.Subject)
+	return nil
+}
+
+// Capture keeps messages in memory instead of sending them. It is meant
+// for tests and local development.
+type Capture struct {
+	mu       sync.Mutex
+	messages []Message
+}
+
+func (c *Capture) Send(ctx context.Context, msg Message) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.messages = append(c.messages, msg)
+	return nil
+}
+
+// Messages returns a copy of the captured messages
+func (c *Capture) Messages() []Message {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]Message(nil), c.messages...)
+}
+
+// permanentError marks a failure that retrying cannot fix
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent wraps err so that the queue does not retry the message
+func Permanent(err error) error {
+	return &permanentError{err: err}
+}
+
+// IsPermanent reports whether err was wrapped with Permanent
+func IsPermanent(err error) bool {
+	var p *permanentError
+	return errors.As(err, &p)
+}
@@ -0,0 +1,122 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// flaky fails a fixed number of times before delivering to a Capture
+type flaky struct {
+	failures atomic.Int32
+	err      error
+	capture  Capture
+}
+
+func (f *flaky) Send(ctx context.Context, msg Message) error {
+	if f.failures.Add(-1) >= 0 {
+		return f.err
+	}
+	return f.capture.Send(ctx, msg)
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for condition")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestQueueRetries(t *testing.T) {
+	f := &flaky{err: errors.New("temporary failure")}
+	f.failures.Store(2)
+
+	q := newQueue(f, time.Millisecond)
+	defer q.Close()
+	q.Enqueue(Message{To: "alice@example.com", Subject: "hello"})
+
+	waitFor(t, func() bool { return len(f.capture.Messages()) == 1 })
+	if got := f.capture.Messages()[0].Subject; got != "hello" {
+		t.Errorf("subject = %q, want %q", got, "hello")
+	}
+}
+
+func TestQueueGivesUpOnPermanentError(t *testing.T) {
+	f := &flaky{err: Permanent(errors.New("invalid recipient"))}
+	f.failures.Store(1)
+
+	q := newQueue(f, time.Millisecond)
+	defer q.Close()
+	q.Enqueue(Message{To: "alice@example.com"})
+
+	waitFor(t, func() bool { return f.failures.Load() == 0 })
+	time.Sleep(20 * time.Millisecond)
+	if n := len(f.capture.Messages()); n != 0 {
+		t.Errorf("delivered %d messages after a permanent error, want 0", n)
+	}
+}
+
+func TestQueueDrains(t *testing.T) {
+	f := &flaky{err: errors.New("temporary failure")}
+	f.failures.Store(1)
+
+	q := newQueue(f, time.Millisecond)
+	for range 10 {
+		q.Enqueue(Message{To: "alice@example.com"})
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	q.Drain(ctx)
+
+	if n := len(f.capture.Messages()); n != 10 {
+		t.Errorf("delivered %d messages before Drain returned, want 10", n)
+	}
+	q.Enqueue(Message{To: "late@example.com"}) // Dropped, not a panic
+}
+
+func TestRender(t *testing.T) {
+	data := struct {
+		Title, Organizer, Start, JoinURL, ICSURL string
+		Duration                                 int
+	}{
+		Title:     "Weekly sync",
+		Organizer: "alice@example.com",
+		Start:     "Mon, 19 Oct 2026 10:00 UTC",
+		Duration:  30,
+		JoinURL:   "https://awwdio.example.com/call/call-1/setup",
+		ICSURL:    "https://awwdio.example.com/api/calls/invites/t.ics",
+	}
+
+	email, err := Render(TemplateInvitation, "bob@example.com", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if email.Subject != "Invitation: Weekly sync" {
+		t.Errorf("email subject = %q", email.Subject)
+	}
+	if !strings.Contains(email.Body, data.JoinURL) {
+		t.Errorf("email body does not contain join URL: %q", email.Body)
+	}
+
+	sms, err := Render(TemplateInvitation, "+15550000000", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sms.Subject != "" {
+		t.Errorf("sms subject = %q, want none", sms.Subject)
+	}
+	if !strings.Contains(sms.Body, data.ICSURL) {
+		t.Errorf("sms body does not contain calendar URL: %q", sms.Body)
+	}
+
+	if _, err := Render("missing", "bob@example.com", data); err == nil {
+		t.Error("expected error for unknown template")
+	}
+}
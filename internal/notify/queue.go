@@ -0,0 +1,144 @@
+package notify
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+const (
+	// queueSize is how many messages can wait before Enqueue drops them
+	queueSize = 256
+	// queueWorkers is the number of concurrent senders
+	queueWorkers = 4
+	// maxAttempts is how often a message is tried before giving up
+	maxAttempts = 5
+	// sendTimeout bounds a single delivery attempt
+	sendTimeout = 30 * time.Second
+)
+
+// Queue sends messages in the background, retrying failures with
+// exponential backoff
+type Queue struct {
+	notifier Notifier
+	messages chan Message
+	backoff  time.Duration
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu     sync.Mutex
+	closed bool // Set by Drain; messages is closed
+}
+
+// NewQueue starts a queue delivering through notifier
+func NewQueue(notifier Notifier) *Queue {
+	return newQueue(notifier, time.Second)
+}
+
+func newQueue(notifier Notifier, backoff time.Duration) *Queue {
+	ctx, cancel := context.WithCancel(context.Background())
+	q := &Queue{
+		notifier: notifier,
+		messages: make(chan Message, queueSize),
+		backoff:  backoff,
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+	for range queueWorkers {
+		q.wg.Add(1)
+		go q.work()
+	}
+	return q
+}
+
+// Enqueue schedules msg for delivery. It never blocks; when the queue is
+// full or draining the message is dropped and logged.
+func (q *Queue) Enqueue(msg Message) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		slog.Error("Notification queue draining, dropping message", "subject", msg.Subject)
+		return
+	}
+	select {
+	case q.messages <- msg:
+	default:
+		slog.Error("Notification queue full, dropping message", "subject", msg.Subject)
+	}
+}
+
+// Close stops the workers, abandoning messages that are still waiting or
+// being retried
+func (q *Queue) Close() {
+	q.cancel()
+	q.wg.Wait()
+}
+
+// Drain stops accepting messages and waits for the waiting ones to be
+// delivered, retries included. Those left when ctx is done are abandoned
+// and logged.
+func (q *Queue) Drain(ctx context.Context) {
+	q.mu.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.messages)
+	}
+	q.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		slog.Error("Notification queue not drained in time, abandoning messages", "waiting", len(q.messages))
+		q.Close()
+	}
+}
+
+func (q *Queue) work() {
+	defer q.wg.Done()
+	for {
+		select {
+		case <-q.ctx.Done():
+			return
+		case msg, ok := <-q.messages:
+			if !ok {
+				return
+			}
+			q.deliver(msg)
+		}
+	}
+}
+
+// deliver tries to send msg until it succeeds, fails permanently or runs
+// out of attempts
+func (q *Queue) deliver(msg Message) {
+	delay := q.backoff
+	for attempt := 1; ; attempt++ {
+		ctx, cancel := context.WithTimeout(q.ctx, sendTimeout)
+		err := q.notifier.Send(ctx, msg)
+		cancel()
+		if err == nil {
+			slog.Debug("Notification sent", "subject", msg.Subject, "attempt", attempt)
+			return
+		}
+		if IsPermanent(err) || attempt == maxAttempts {
+			slog.Error("Failed to send notification", "error", err, "subject", msg.Subject, "attempts", attempt)
+			return
+		}
+
+		slog.Warn("Notification failed, retrying", "error", err, "attempt", attempt, "retry_in", delay)
+		select {
+		case <-q.ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
@@ -0,0 +1,81 @@
+package notify
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// Template names
+const (
+	TemplateInvitation = "invitation"
+	TemplateReminder   = "reminder"
+)
+
+// messageTemplate is a message template. SMS only uses the sms body, so it should
+// stay short and link to anything else.
+type messageTemplate struct {
+	subject *template.Template
+	email   *template.Template
+	sms     *template.Template
+}
+
+var templates = map[string]messageTemplate{
+	TemplateInvitation: {
+		subject: template.Must(template.New("subject").Parse(`Invitation: {{.Title}}`)),
+		email: template.Must(template.New("email").Parse(`{{.Organizer}} invited you to a call on Awwdio.
+
+{{.Title}}
+{{.Start}} ({{.Duration}} minutes)
+
+Join the call: {{.JoinURL}}
+
+The attached invitation adds the call to your calendar.
+`)),
+		sms: template.Must(template.New("sms").Parse(`{{.Organizer}} invited you to "{{.Title}}" on Awwdio at {{.Start}}. Join: {{.JoinURL}} Calendar: {{.ICSURL}}`)),
+	},
+	TemplateReminder: {
+		subject: template.Must(template.New("subject").Parse(`Starting soon: {{.Title}}`)),
+		email: template.Must(template.New("email").Parse(`Your call "{{.Title}}" starts at {{.Start}}.
+
+Join the call: {{.JoinURL}}
+`)),
+		sms: template.Must(template.New("sms").Parse(`Your Awwdio call "{{.Title}}" starts at {{.Start}}. Join: {{.JoinURL}}`)),
+	},
+}
+
+// Render fills the named template with data and returns a message for to.
+// The email or SMS variant is chosen from the recipient.
+func Render(name, to string, data any) (Message, error) {
+	t, ok := templates[name]
+	if !ok {
+		return Message{}, fmt.Errorf("unknown template: %s", name)
+	}
+
+	msg := Message{To: to}
+	body := t.sms
+	if IsEmail(to) {
+		body = t.email
+		subject, err := execute(t.subject, data)
+		if err != nil {
+			return Message{}, err
+		}
+		msg.Subject = strings.TrimSpace(subject)
+	}
+
+	text, err := execute(body, data)
+	if err != nil {
+		return Message{}, err
+	}
+	msg.Body = text
+	return msg, nil
+}
+
+func execute(t *template.Template, data any) (string, error) {
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to render template %s: %w", t.Name(), err)
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,51 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/kaustavdm/awwdio/config"
+	"github.com/kaustavdm/awwdio/internal/twilioclient"
+	"github.com/twilio/twilio-go/client"
+	openapi "github.com/twilio/twilio-go/rest/api/v2010"
+)
+
+// errInvalidToNumber is the Twilio error code for an invalid recipient number
+const errInvalidToNumber = 21211
+
+// Twilio sends SMS through Twilio Programmable Messaging
+type Twilio struct {
+	config *config.Config
+	from   string
+}
+
+func NewTwilio(cfg *config.Config) *Twilio {
+	return &Twilio{
+		config: cfg,
+		from:   cfg.TwilioMessagingFrom,
+	}
+}
+
+func (t *Twilio) Send(ctx context.Context, msg Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	params := &openapi.CreateMessageParams{}
+	params.SetTo(msg.To)
+	params.SetBody(msg.Body)
+	// The sender is either a Messaging Service or a phone number
+	if strings.HasPrefix(t.from, "MG") {
+		params.SetMessagingServiceSid(t.from)
+	} else {
+		params.SetFrom(t.from)
+	}
+
+	_, err := twilioclient.New(ctx, t.config).Api.CreateMessage(params)
+	var restErr *client.TwilioRestError
+	if errors.As(err, &restErr) && restErr.Code == errInvalidToNumber {
+		return Permanent(err)
+	}
+	return err
+}
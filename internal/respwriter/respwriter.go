@@ -0,0 +1,73 @@
+// Package respwriter wraps http.ResponseWriter to record what a handler
+// wrote, for middleware that reports on responses (metrics, tracing,
+// access logs).
+package respwriter
+
+import "net/http"
+
+// Recorder records the status code and body size of a response
+type Recorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+// Wrap returns a Recorder for w. If w already is one, it is returned as is,
+// so nested middleware share a single wrapper.
+func Wrap(w http.ResponseWriter) *Recorder {
+	if rec, ok := w.(*Recorder); ok {
+		return rec
+	}
+	return &Recorder{ResponseWriter: w}
+}
+
+// Status returns the status code sent. A handler that has written nothing
+// gets 200 from net/http, so that is reported too.
+func (w *Recorder) Status() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+// Written reports whether headers have been sent
+func (w *Recorder) Written() bool {
+	return w.status != 0
+}
+
+// Bytes returns the number of body bytes written
+func (w *Recorder) Bytes() int64 {
+	return w.bytes
+}
+
+func (w *Recorder) WriteHeader(code int) {
+	// Informational responses are followed by the real one
+	if w.status == 0 && code >= 200 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *Recorder) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (w *Recorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Flush sends buffered data to the client, for streaming responses
+func (w *Recorder) Flush() {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
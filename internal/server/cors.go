@@ -0,0 +1,75 @@
+package server
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/kaustavdm/awwdio/config"
+)
+
+// Request headers browsers may send cross-origin, on top of the CORS safelisted ones
+var corsAllowedHeaders = strings.Join([]string{
+	"Authorization",
+	"Content-Type",
+	"X-CSRF-Token",
+	RequestIDHeader,
+	"Traceparent",
+	"Tracestate",
+}, ", ")
+
+const corsAllowedMethods = "GET, POST, PUT, PATCH, DELETE"
+
+// CORS lets the origins in CORS_ALLOWED_ORIGINS call the wrapped handler
+// from a browser. Preflight requests from allowed origins are answered
+// directly; requests from other origins pass through without CORS headers,
+// so browsers keep them from reading responses. The configuration is read
+// per request, so reloads apply.
+func CORS(cfg *config.Provider) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			if origin == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			c := cfg.Get()
+			preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+			// Responses differ by origin, so caches must keep them apart
+			h := w.Header()
+			h.Add("Vary", "Origin")
+			if preflight {
+				h.Add("Vary", "Access-Control-Request-Method")
+				h.Add("Vary", "Access-Control-Request-Headers")
+			}
+
+			wildcard := slices.Contains(c.CORSAllowedOrigins, "*")
+			if !wildcard && !slices.Contains(c.CORSAllowedOrigins, strings.ToLower(origin)) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if wildcard && !c.CORSAllowCredentials {
+				h.Set("Access-Control-Allow-Origin", "*")
+			} else {
+				h.Set("Access-Control-Allow-Origin", origin)
+			}
+			if c.CORSAllowCredentials {
+				h.Set("Access-Control-Allow-Credentials", "true")
+			}
+
+			if !preflight {
+				h.Set("Access-Control-Expose-Headers", RequestIDHeader)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			h.Set("Access-Control-Allow-Methods", corsAllowedMethods)
+			h.Set("Access-Control-Allow-Headers", corsAllowedHeaders)
+			h.Set("Access-Control-Max-Age", strconv.Itoa(c.CORSMaxAge))
+			w.WriteHeader(http.StatusNoContent)
+		})
+	}
+}
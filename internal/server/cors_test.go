@@ -0,0 +1,155 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/kaustavdm/awwdio/config"
+)
+
+func TestCORS(t *testing.T) {
+	tests := []struct {
+		name        string
+		origins     []string
+		credentials bool
+		method      string
+		origin      string
+		preflight   bool
+
+		wantStatus      int
+		wantAllowOrigin string
+		wantCredentials bool
+		wantVary        []string
+		wantNext        bool
+	}{
+		{
+			name:     "same origin",
+			origins:  []string{"https://app.example.com"},
+			method:   http.MethodGet,
+			wantNext: true, wantStatus: http.StatusOK,
+		},
+		{
+			name:    "allowed origin",
+			origins: []string{"https://app.example.com"}, credentials: true,
+			method: http.MethodPost, origin: "https://app.example.com",
+			wantNext: true, wantStatus: http.StatusOK,
+			wantAllowOrigin: "https://app.example.com", wantCredentials: true,
+			wantVary: []string{"Origin"},
+		},
+		{
+			name:    "allowed origin in another case",
+			origins: []string{"https://app.example.com"},
+			method:  http.MethodGet, origin: "https://App.Example.com",
+			wantNext: true, wantStatus: http.StatusOK,
+			wantAllowOrigin: "https://App.Example.com",
+			wantVary:        []string{"Origin"},
+		},
+		{
+			name:    "disallowed origin",
+			origins: []string{"https://app.example.com"}, credentials: true,
+			method: http.MethodPost, origin: "https://evil.example.com",
+			wantNext: true, wantStatus: http.StatusOK,
+			wantVary: []string{"Origin"},
+		},
+		{
+			name:    "wildcard without credentials",
+			origins: []string{"*"},
+			method:  http.MethodGet, origin: "https://any.example.com",
+			wantNext: true, wantStatus: http.StatusOK,
+			wantAllowOrigin: "*",
+			wantVary:        []string{"Origin"},
+		},
+		{
+			name:    "wildcard with credentials echoes the origin",
+			origins: []string{"*"}, credentials: true,
+			method: http.MethodGet, origin: "https://any.example.com",
+			wantNext: true, wantStatus: http.StatusOK,
+			wantAllowOrigin: "https://any.example.com", wantCredentials: true,
+			wantVary: []string{"Origin"},
+		},
+		{
+			name:    "preflight from allowed origin",
+			origins: []string{"https://app.example.com"}, credentials: true,
+			method: http.MethodOptions, origin: "https://app.example.com", preflight: true,
+			wantStatus:      http.StatusNoContent,
+			wantAllowOrigin: "https://app.example.com", wantCredentials: true,
+			wantVary: []string{"Origin", "Access-Control-Request-Method", "Access-Control-Request-Headers"},
+		},
+		{
+			name:    "preflight from disallowed origin",
+			origins: []string{"https://app.example.com"},
+			method:  http.MethodOptions, origin: "https://evil.example.com", preflight: true,
+			wantNext: true, wantStatus: http.StatusOK,
+			wantVary: []string{"Origin", "Access-Control-Request-Method", "Access-Control-Request-Headers"},
+		},
+		{
+			name:    "OPTIONS without request method is not a preflight",
+			origins: []string{"https://app.example.com"},
+			method:  http.MethodOptions, origin: "https://app.example.com",
+			wantNext: true, wantStatus: http.StatusOK,
+			wantAllowOrigin: "https://app.example.com",
+			wantVary:        []string{"Origin"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Static(&config.Config{
+				CORSAllowedOrigins:   tt.origins,
+				CORSAllowCredentials: tt.credentials,
+				CORSMaxAge:           600,
+			})
+			called := false
+			h := CORS(cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				called = true
+			}))
+
+			req := httptest.NewRequest(tt.method, "/api/rooms", nil)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			if tt.preflight {
+				req.Header.Set("Access-Control-Request-Method", http.MethodDelete)
+				req.Header.Set("Access-Control-Request-Headers", "x-csrf-token")
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			res := rec.Header()
+			if called != tt.wantNext {
+				t.Errorf("next called = %v, want %v", called, tt.wantNext)
+			}
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if got := res.Get("Access-Control-Allow-Origin"); got != tt.wantAllowOrigin {
+				t.Errorf("Access-Control-Allow-Origin = %q, want %q", got, tt.wantAllowOrigin)
+			}
+			if got := res.Get("Access-Control-Allow-Credentials") == "true"; got != tt.wantCredentials {
+				t.Errorf("Access-Control-Allow-Credentials = %q", res.Get("Access-Control-Allow-Credentials"))
+			}
+			if got := res.Values("Vary"); !slices.Equal(got, tt.wantVary) {
+				t.Errorf("Vary = %q, want %q", got, tt.wantVary)
+			}
+
+			allowed := tt.wantAllowOrigin != ""
+			if got := res.Get("Access-Control-Expose-Headers"); (got == RequestIDHeader) != (allowed && !tt.preflight) {
+				t.Errorf("Access-Control-Expose-Headers = %q", got)
+			}
+			wantPreflight := allowed && tt.preflight
+			for header, want := range map[string]string{
+				"Access-Control-Allow-Methods": corsAllowedMethods,
+				"Access-Control-Allow-Headers": corsAllowedHeaders,
+				"Access-Control-Max-Age":       "600",
+			} {
+				if !wantPreflight {
+					want = ""
+				}
+				if got := res.Get(header); got != want {
+					t.Errorf("%s = %q, want %q", header, got, want)
+				}
+			}
+		})
+	}
+}
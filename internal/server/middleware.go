@@ -0,0 +1,130 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"github.com/kaustavdm/awwdio/internal/api/apierror"
+	"github.com/kaustavdm/awwdio/internal/logging"
+	"github.com/kaustavdm/awwdio/internal/metrics"
+	"github.com/kaustavdm/awwdio/internal/respwriter"
+	"github.com/kaustavdm/awwdio/internal/tracing"
+)
+
+// RequestIDHeader carries the request ID in both directions
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength bounds request IDs accepted from clients and proxies
+const maxRequestIDLength = 128
+
+// Chain wraps h in middleware, the first being the outermost
+func Chain(h http.Handler, middleware ...func(http.Handler) http.Handler) http.Handler {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		h = middleware[i](h)
+	}
+	return h
+}
+
+// RequestID reuses the X-Request-ID sent by a proxy or client, or assigns
+// a new one, and echoes it in the response. The request context gets the
+// ID and a logger that adds it, and the trace IDs, to every record.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(RequestIDHeader, id)
+
+		logger := slog.Default().With(slog.String("request_id", id))
+		if sc := tracing.SpanFromContext(r.Context()).SpanContext(); sc.IsValid() {
+			logger = logger.With(
+				slog.String("trace_id", sc.TraceID.String()),
+				slog.String("span_id", sc.SpanID.String()),
+			)
+		}
+
+		ctx := logging.WithRequestID(r.Context(), id)
+		ctx = logging.NewContext(ctx, logger)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// validRequestID accepts printable ASCII IDs of reasonable length, so that
+// client supplied values cannot forge log lines
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// AccessLog logs one line per request once it has been served. It must run
+// inside RequestID, and inside metrics.Middleware to report the route.
+func AccessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := respwriter.Wrap(w)
+		next.ServeHTTP(rec, r)
+
+		remoteIP, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			remoteIP = r.RemoteAddr
+		}
+		logging.FromContext(r.Context()).Info("Request",
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.String("route", metrics.Route(r)),
+			slog.Int("status", rec.Status()),
+			slog.Int64("bytes", rec.Bytes()),
+			slog.Duration("duration", time.Since(start)),
+			slog.String("remote_ip", remoteIP),
+			slog.String("user_agent", r.UserAgent()),
+		)
+	})
+}
+
+// Recover turns a panicking handler into a 500 response and logs the panic
+// with its stack, instead of dropping the connection
+func Recover(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := respwriter.Wrap(w)
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+			if v == http.ErrAbortHandler {
+				// Deliberate abort, let net/http close the connection quietly
+				panic(v)
+			}
+
+			logging.FromContext(r.Context()).Error("Handler panicked",
+				slog.Any("panic", v),
+				slog.String("stack", string(debug.Stack())),
+			)
+			if rec.Written() {
+				// Too late for a clean error response
+				return
+			}
+			apierror.Write(rec, r, apierror.Internal("Internal server error"))
+		}()
+		next.ServeHTTP(rec, r)
+	})
+}
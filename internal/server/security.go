@@ -0,0 +1,195 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"strings"
+
+	"github.com/kaustavdm/awwdio/config"
+)
+
+// Hosts the Twilio Video SDK talks to: signaling over WebSockets
+// (global.vss.twilio.com and regional gateways) and insights over HTTPS.
+// Media flows over WebRTC, which CSP does not cover.
+var twilioVideoHosts = []string{
+	"https://*.twilio.com",
+	"wss://*.twilio.com",
+}
+
+// hstsMaxAge is one year, the usual value for HSTS preload lists
+const hstsMaxAge = "max-age=31536000"
+
+type nonceKey struct{}
+
+// CSPNonce returns the script nonce allowed by the request's
+// Content-Security-Policy, or "" outside SecurityHeaders
+func CSPNonce(ctx context.Context) string {
+	nonce, _ := ctx.Value(nonceKey{}).(string)
+	return nonce
+}
+
+// contentSecurityPolicy allows scripts from this origin and those carrying
+// nonce, and lets the Twilio Video SDK reach its servers. Svelte sets inline
+// styles for transitions, so those are allowed.
+func contentSecurityPolicy(nonce string) string {
+	twilio := strings.Join(twilioVideoHosts, " ")
+	return strings.Join([]string{
+		"default-src 'self'",
+		"script-src 'self' 'nonce-" + nonce + "'",
+		"style-src 'self' 'unsafe-inline'",
+		"img-src 'self' data: blob:",
+		"media-src 'self' blob: mediastream:",
+		"font-src 'self' data:",
+		"connect-src 'self' " + twilio,
+		"worker-src 'self' blob:",
+		"object-src 'none'",
+		"base-uri 'self'",
+		"form-action 'self'",
+		"frame-ancestors 'none'",
+	}, "; ")
+}
+
+// SecurityHeaders sets the Content-Security-Policy and other browser
+// security headers on every response. Each request gets a fresh CSP nonce,
+// available to handlers through CSPNonce. HSTS is only sent over HTTPS,
+// directly or behind a proxy serving PUBLIC_URL.
+func SecurityHeaders(cfg *config.Provider) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			nonce := newNonce()
+
+			h := w.Header()
+			h.Set("Content-Security-Policy", contentSecurityPolicy(nonce))
+			h.Set("X-Content-Type-Options", "nosniff")
+			h.Set("X-Frame-Options", "DENY")
+			// Invitation links carry tokens in the path, keep them on this site
+			h.Set("Referrer-Policy", "same-origin")
+			h.Set("Permissions-Policy", "camera=(self), microphone=(self), display-capture=(self), geolocation=(), payment=(), usb=()")
+			h.Set("Cross-Origin-Opener-Policy", "same-origin")
+			if c := cfg.Get(); r.TLS != nil || strings.HasPrefix(c.PublicURL, "https://") {
+				hsts := hstsMaxAge
+				if c.HSTSIncludeSubdomains {
+					hsts += "; includeSubDomains"
+				}
+				h.Set("Strict-Transport-Security", hsts)
+			}
+
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), nonceKey{}, nonce)))
+		})
+	}
+}
+
+// InjectNonce adds a nonce attribute to the <script> tags of an HTML page,
+// such as SvelteKit's bootstrap script, so the CSP lets them run. Only real
+// tags get one: "<script" in attribute values, comments or script bodies is
+// left alone.
+func InjectNonce(html []byte, nonce string) []byte {
+	attr := ` nonce="` + nonce + `"`
+	var b bytes.Buffer
+	b.Grow(len(html) + 4*len(attr))
+
+	for i := 0; i < len(html); {
+		lt := bytes.IndexByte(html[i:], '<')
+		if lt < 0 {
+			b.Write(html[i:])
+			break
+		}
+		b.Write(html[i : i+lt])
+		i += lt
+
+		switch {
+		case bytes.HasPrefix(html[i:], []byte("<!--")):
+			end := skipPast(html, i+len("<!--"), "-->")
+			b.Write(html[i:end])
+			i = end
+		case isScriptTag(html[i:]):
+			end := tagEnd(html, i)
+			b.WriteString("<script")
+			b.WriteString(attr)
+			b.Write(html[i+len("<script") : end])
+			// The body is raw text up to the closing tag
+			closing := indexFold(html[end:], "</script")
+			if closing < 0 {
+				closing = len(html) - end
+			}
+			b.Write(html[end : end+closing])
+			i = end + closing
+		case i+1 < len(html) && isTagStart(html[i+1]):
+			end := tagEnd(html, i)
+			b.Write(html[i:end])
+			i = end
+		default:
+			// A "<" in text
+			b.WriteByte('<')
+			i++
+		}
+	}
+	return b.Bytes()
+}
+
+// isScriptTag reports whether s starts with a <script start tag, in any case
+func isScriptTag(s []byte) bool {
+	const tag = "<script"
+	if len(s) <= len(tag) || !bytes.EqualFold(s[:len(tag)], []byte(tag)) {
+		return false
+	}
+	switch s[len(tag)] {
+	case ' ', '\t', '\n', '\r', '\f', '/', '>':
+		return true
+	}
+	return false
+}
+
+// isTagStart reports whether c can follow "<" at the start of a tag
+func isTagStart(c byte) bool {
+	return c == '/' || c == '!' || c == '?' || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
+
+// tagEnd returns the index just past the tag starting at start, skipping
+// quoted attribute values
+func tagEnd(html []byte, start int) int {
+	var quote byte
+	for i := start + 1; i < len(html); i++ {
+		c := html[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '>':
+			return i + 1
+		}
+	}
+	return len(html)
+}
+
+// skipPast returns the index just past the first end at or after from, or
+// len(html)
+func skipPast(html []byte, from int, end string) int {
+	n := bytes.Index(html[from:], []byte(end))
+	if n < 0 {
+		return len(html)
+	}
+	return from + n + len(end)
+}
+
+// indexFold is bytes.Index for an ASCII substr, ignoring case
+func indexFold(s []byte, substr string) int {
+	for i := 0; i+len(substr) <= len(s); i++ {
+		if bytes.EqualFold(s[i:i+len(substr)], []byte(substr)) {
+			return i
+		}
+	}
+	return -1
+}
+
+func newNonce() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return base64.StdEncoding.EncodeToString(b)
+}
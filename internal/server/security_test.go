@@ -0,0 +1,131 @@
+package server
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/kaustavdm/awwdio/config"
+)
+
+func TestInjectNonce(t *testing.T) {
+	const n = ` nonce="abc"`
+	tests := []struct {
+		name, html, want string
+	}{
+		{
+			name: "inline and external scripts",
+			html: `<head><script>start()</script><script type="module" src="/_app/x.js"></script></head>`,
+			want: `<head><script` + n + `>start()</script><script` + n + ` type="module" src="/_app/x.js"></script></head>`,
+		},
+		{
+			name: "uppercase and self-closing",
+			html: `<SCRIPT>a()</SCRIPT><script/>`,
+			want: `<script` + n + `>a()</SCRIPT><script` + n + `/>`,
+		},
+		{
+			name: "attribute values",
+			html: `<div title="<script>" data-x='a > <script'></div><script>b()</script>`,
+			want: `<div title="<script>" data-x='a > <script'></div><script` + n + `>b()</script>`,
+		},
+		{
+			name: "script bodies",
+			html: `<script>document.write("<script>x()</" + "script>")</script><p>after</p>`,
+			want: `<script` + n + `>document.write("<script>x()</" + "script>")</script><p>after</p>`,
+		},
+		{
+			name: "comments",
+			html: `<!-- <script>old()</script> --><script>new()</script>`,
+			want: `<!-- <script>old()</script> --><script` + n + `>new()</script>`,
+		},
+		{
+			name: "similar tags and text",
+			html: `<scripts></scripts><noscript>off</noscript> 1 < 2 <script>c()</script>`,
+			want: `<scripts></scripts><noscript>off</noscript> 1 < 2 <script` + n + `>c()</script>`,
+		},
+		{
+			name: "unterminated",
+			html: `<p title="<script`,
+			want: `<p title="<script`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(InjectNonce([]byte(tt.html), "abc")); got != tt.want {
+				t.Errorf("got  %s\nwant %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSecurityHeaders(t *testing.T) {
+	tests := []struct {
+		name       string
+		publicURL  string
+		tls        bool
+		subdomains bool
+		wantHSTS   string
+	}{
+		{name: "plain HTTP"},
+		{name: "HTTP behind an HTTP public URL", publicURL: "http://localhost:8080"},
+		{name: "TLS", tls: true, wantHSTS: hstsMaxAge},
+		{name: "behind an HTTPS proxy", publicURL: "https://awwdio.example.com", wantHSTS: hstsMaxAge},
+		{name: "subdomains", tls: true, subdomains: true, wantHSTS: hstsMaxAge + "; includeSubDomains"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Static(&config.Config{PublicURL: tt.publicURL, HSTSIncludeSubdomains: tt.subdomains})
+			var page string
+			h := SecurityHeaders(cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				page = string(InjectNonce([]byte(`<script>boot()</script>`), CSPNonce(r.Context())))
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.tls {
+				req.TLS = &tls.ConnectionState{}
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			res := rec.Header()
+
+			csp := res.Get("Content-Security-Policy")
+			m := regexp.MustCompile(`script-src 'self' 'nonce-([A-Za-z0-9+/=]+)'`).FindStringSubmatch(csp)
+			if m == nil {
+				t.Fatalf("CSP has no script nonce: %s", csp)
+			}
+			if want := `<script nonce="` + m[1] + `">boot()</script>`; page != want {
+				t.Errorf("page = %s, want %s", page, want)
+			}
+			for _, directive := range []string{"default-src 'self'", "object-src 'none'", "frame-ancestors 'none'",
+				"connect-src 'self' https://*.twilio.com wss://*.twilio.com"} {
+				if !strings.Contains(csp, directive) {
+					t.Errorf("CSP lacks %q: %s", directive, csp)
+				}
+			}
+
+			for header, want := range map[string]string{
+				"X-Content-Type-Options":     "nosniff",
+				"X-Frame-Options":            "DENY",
+				"Referrer-Policy":            "same-origin",
+				"Cross-Origin-Opener-Policy": "same-origin",
+				"Strict-Transport-Security":  tt.wantHSTS,
+			} {
+				if got := res.Get(header); got != want {
+					t.Errorf("%s = %q, want %q", header, got, want)
+				}
+			}
+		})
+	}
+
+	// Every request gets its own nonce
+	h := SecurityHeaders(config.Static(&config.Config{}))(http.NotFoundHandler())
+	first, second := httptest.NewRecorder(), httptest.NewRecorder()
+	h.ServeHTTP(first, httptest.NewRequest(http.MethodGet, "/", nil))
+	h.ServeHTTP(second, httptest.NewRequest(http.MethodGet, "/", nil))
+	if first.Header().Get("Content-Security-Policy") == second.Header().Get("Content-Security-Policy") {
+		t.Error("two requests got the same CSP nonce")
+	}
+}
@@ -0,0 +1,136 @@
+// Package server provides the listeners the HTTP server runs on.
+package server
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/kaustavdm/awwdio/config"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// certCheckInterval limits how often certificate files are checked for changes
+const certCheckInterval = 10 * time.Second
+
+// TLS holds the TLS setup for the main listener
+type TLS struct {
+	// Config is nil when TLS is disabled
+	Config *tls.Config
+	// wrapHTTP wraps the redirect listener's handler, to answer ACME HTTP-01 challenges
+	wrapHTTP func(http.Handler) http.Handler
+}
+
+// NewTLS sets up TLS from certificate files or ACME, as configured
+func NewTLS(cfg *config.Config) (*TLS, error) {
+	switch {
+	case len(cfg.ACMEDomains) > 0:
+		m := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(cfg.ACMEDomains...),
+			Cache:      autocert.DirCache(cfg.ACMECacheDir),
+			Email:      cfg.ACMEEmail,
+		}
+		slog.Info("TLS enabled with ACME", slog.Any("domains", cfg.ACMEDomains), slog.String("cache", cfg.ACMECacheDir))
+		return &TLS{Config: m.TLSConfig(), wrapHTTP: m.HTTPHandler}, nil
+
+	case cfg.TLSCertFile != "":
+		r := &certReloader{certFile: cfg.TLSCertFile, keyFile: cfg.TLSKeyFile}
+		if err := r.load(); err != nil {
+			return nil, err
+		}
+		slog.Info("TLS enabled with certificate files", slog.String("cert", cfg.TLSCertFile))
+		return &TLS{
+			Config:   &tls.Config{MinVersion: tls.VersionTLS12, GetCertificate: r.getCertificate},
+			wrapHTTP: func(h http.Handler) http.Handler { return h },
+		}, nil
+
+	default:
+		return &TLS{}, nil
+	}
+}
+
+// Enabled reports whether the main listener should serve TLS
+func (t *TLS) Enabled() bool {
+	return t.Config != nil
+}
+
+// RedirectServer returns a plain HTTP server that redirects every request
+// to HTTPS on httpsPort, and answers ACME challenges when ACME is in use
+func (t *TLS) RedirectServer(addr, httpsPort string) *http.Server {
+	redirect := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.Host)
+		if err != nil {
+			host = r.Host
+		}
+		if httpsPort != "443" {
+			host = net.JoinHostPort(host, httpsPort)
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+	})
+
+	return &http.Server{
+		Addr:         addr,
+		Handler:      t.wrapHTTP(redirect),
+		ReadTimeout:  5 * time.Second,
+		WriteTimeout: 5 * time.Second,
+		IdleTimeout:  30 * time.Second,
+	}
+}
+
+// certReloader serves a certificate from files, picking up renewals
+// without a restart
+type certReloader struct {
+	certFile string
+	keyFile  string
+
+	mu          sync.Mutex
+	cert        *tls.Certificate
+	modTime     time.Time
+	lastChecked time.Time
+}
+
+func (r *certReloader) load() error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+	r.cert = &cert
+	r.modTime = r.latestModTime()
+	return nil
+}
+
+// latestModTime returns the newer modification time of the two files
+func (r *certReloader) latestModTime() time.Time {
+	var latest time.Time
+	for _, f := range []string{r.certFile, r.keyFile} {
+		if info, err := os.Stat(f); err == nil && info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest
+}
+
+func (r *certReloader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if now := time.Now(); now.Sub(r.lastChecked) > certCheckInterval {
+		r.lastChecked = now
+		if !r.latestModTime().Equal(r.modTime) {
+			// Keep serving the old certificate if the new files are broken,
+			// e.g. when only one of them has been replaced so far
+			if err := r.load(); err != nil {
+				slog.Error("Failed to reload TLS certificate", slog.String("error", err.Error()))
+			} else {
+				slog.Info("TLS certificate reloaded", slog.String("cert", r.certFile))
+			}
+		}
+	}
+	return r.cert, nil
+}
@@ -0,0 +1,219 @@
+// Package static serves the embedded frontend from memory, with
+// precompressed variants, content-hash ETags and cache headers suited to
+// SvelteKit's hashed assets.
+package static
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Embedded files have no modification time, so no Last-Modified is sent
+var noModTime time.Time
+
+// minGzipSize is the smallest file worth compressing
+const minGzipSize = 1024
+
+// Cache-Control values
+const (
+	// SvelteKit puts a content hash in every file name under _app/immutable,
+	// so those never change
+	cacheImmutable = "public, max-age=31536000, immutable"
+	// Everything else must be revalidated, cheaply thanks to ETags
+	cacheRevalidate = "no-cache"
+)
+
+// file is one embedded file with its encodings
+type file struct {
+	contentType string
+	etag        string // of the identity encoding
+	identity    []byte
+	gzip        []byte // nil when not worth it
+	brotli      []byte // only from the build, Go has no brotli encoder
+}
+
+// Files holds the contents of a file system in memory
+type Files struct {
+	files map[string]*file
+}
+
+// Load reads every file in fsys. Precompressed siblings written by the build
+// (name.br, name.gz) are used as encodings of name; other compressible files
+// are gzipped here.
+func Load(fsys fs.FS) (*Files, error) {
+	raw := map[string][]byte{}
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+		raw[name] = data
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	files := &Files{files: map[string]*file{}}
+	for name, data := range raw {
+		if base, ok := variantOf(name); ok {
+			if _, exists := raw[base]; exists {
+				continue
+			}
+		}
+
+		sum := sha256.Sum256(data)
+		f := &file{
+			contentType: contentType(name, data),
+			etag:        `"` + hex.EncodeToString(sum[:16]) + `"`,
+			identity:    data,
+			gzip:        raw[name+".gz"],
+			brotli:      raw[name+".br"],
+		}
+		if f.gzip == nil && compressible(f.contentType) && len(data) >= minGzipSize {
+			f.gzip = gzipBytes(data)
+		}
+		files.files[name] = f
+	}
+	return files, nil
+}
+
+// variantOf returns the file a precompressed variant encodes
+func variantOf(name string) (string, bool) {
+	if base, ok := strings.CutSuffix(name, ".gz"); ok {
+		return base, true
+	}
+	return strings.CutSuffix(name, ".br")
+}
+
+func contentType(name string, data []byte) string {
+	if ct := mime.TypeByExtension(path.Ext(name)); ct != "" {
+		return ct
+	}
+	return http.DetectContentType(data)
+}
+
+func compressible(contentType string) bool {
+	ct, _, _ := strings.Cut(contentType, ";")
+	switch {
+	case strings.HasPrefix(ct, "text/"),
+		strings.HasSuffix(ct, "javascript"),
+		strings.HasSuffix(ct, "json"),
+		strings.HasSuffix(ct, "xml"),
+		ct == "image/svg+xml",
+		ct == "application/wasm":
+		return true
+	}
+	return false
+}
+
+// gzipBytes compresses data, or returns nil if that does not make it smaller
+func gzipBytes(data []byte) []byte {
+	var buf bytes.Buffer
+	zw, _ := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	zw.Write(data)
+	zw.Close()
+	if buf.Len() >= len(data) {
+		return nil
+	}
+	return buf.Bytes()
+}
+
+// Bytes returns the contents of a file
+func (s *Files) Bytes(name string) ([]byte, bool) {
+	f, ok := s.files[name]
+	if !ok {
+		return nil, false
+	}
+	return f.identity, true
+}
+
+// ServeHTTP serves the file named by the request path, relative to the root
+// of the file system. Missing files get a 404.
+func (s *Files) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+	if !s.Serve(w, r, name) {
+		http.NotFound(w, r)
+	}
+}
+
+// Serve writes the named file in the best encoding the client accepts,
+// answering conditional and range requests. It reports false, having
+// written nothing, if there is no such file.
+func (s *Files) Serve(w http.ResponseWriter, r *http.Request, name string) bool {
+	f, ok := s.files[name]
+	if !ok {
+		return false
+	}
+
+	h := w.Header()
+	h.Set("Content-Type", f.contentType)
+	if strings.HasPrefix(name, "_app/immutable/") {
+		h.Set("Cache-Control", cacheImmutable)
+	} else {
+		h.Set("Cache-Control", cacheRevalidate)
+	}
+
+	data, etag := f.identity, f.etag
+	if f.gzip != nil || f.brotli != nil {
+		h.Add("Vary", "Accept-Encoding")
+		accept := r.Header.Get("Accept-Encoding")
+		switch {
+		case f.brotli != nil && accepts(accept, "br"):
+			h.Set("Content-Encoding", "br")
+			data, etag = f.brotli, withSuffix(f.etag, "br")
+		case f.gzip != nil && accepts(accept, "gzip"):
+			h.Set("Content-Encoding", "gzip")
+			data, etag = f.gzip, withSuffix(f.etag, "gz")
+		}
+	}
+	// Each encoding is a different representation, with its own ETag
+	h.Set("ETag", etag)
+
+	// ServeContent handles If-None-Match, ranges and HEAD
+	http.ServeContent(w, r, name, noModTime, bytes.NewReader(data))
+	return true
+}
+
+// withSuffix derives the ETag of an encoded variant
+func withSuffix(etag, suffix string) string {
+	return strings.TrimSuffix(etag, `"`) + "-" + suffix + `"`
+}
+
+// accepts reports whether an Accept-Encoding header allows coding. An
+// explicit entry for the coding wins over "*".
+func accepts(header, coding string) bool {
+	explicit, wildcard := -1.0, -1.0
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		name = strings.TrimSpace(name)
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+				q = parsed
+			}
+		}
+		switch {
+		case strings.EqualFold(name, coding):
+			explicit = q
+		case name == "*":
+			wildcard = q
+		}
+	}
+	if explicit >= 0 {
+		return explicit > 0
+	}
+	return wildcard > 0
+}
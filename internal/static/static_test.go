@@ -0,0 +1,178 @@
+package static
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestAccepts(t *testing.T) {
+	tests := []struct {
+		header, coding string
+		want           bool
+	}{
+		{"", "gzip", false},
+		{"gzip", "gzip", true},
+		{"GZIP", "gzip", true},
+		{"deflate, gzip, br", "br", true},
+		{"deflate", "gzip", false},
+		{"gzip;q=0.5", "gzip", true},
+		{"gzip; q=0", "gzip", false},
+		{"gzip;q=0.0, br", "gzip", false},
+		{"*", "br", true},
+		{"*;q=0", "gzip", false},
+		{"*;q=0, gzip", "gzip", true},
+		{"gzip;q=0, *", "gzip", false},
+		{"identity", "gzip", false},
+	}
+	for _, tt := range tests {
+		if got := accepts(tt.header, tt.coding); got != tt.want {
+			t.Errorf("accepts(%q, %q) = %v, want %v", tt.header, tt.coding, got, tt.want)
+		}
+	}
+}
+
+func TestServe(t *testing.T) {
+	script := []byte(strings.Repeat("console.log('hello');\n", 100))
+	files, err := Load(fstest.MapFS{
+		"index.html":                  {Data: []byte("<!doctype html><p>hi</p>")},
+		"_app/immutable/entry.js":     {Data: script},
+		"_app/version.json":           {Data: []byte(`{"version":"1"}`)},
+		"app.css":                     {Data: []byte("body{}")},
+		"app.css.gz":                  {Data: []byte("gzipped css")},
+		"app.css.br":                  {Data: []byte("brotli css")},
+		"favicon.png":                 {Data: bytes.Repeat([]byte{0x89, 'P', 'N', 'G'}, 512)},
+		"_app/immutable/orphan.js.gz": {Data: []byte("no base")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	etag := func(name string) string {
+		return files.files[name].etag
+	}
+
+	tests := []struct {
+		name           string
+		path           string
+		acceptEncoding string
+		ifNoneMatch    string
+
+		wantStatus   int
+		wantEncoding string
+		wantBody     string
+		wantETag     string
+		wantCache    string
+		wantVary     bool
+		gunzip       bool // compare wantBody with the decompressed body
+	}{
+		{
+			name: "small file", path: "/index.html", acceptEncoding: "gzip, br",
+			wantStatus: http.StatusOK, wantBody: "<!doctype html><p>hi</p>",
+			wantETag: etag("index.html"), wantCache: cacheRevalidate,
+		},
+		{
+			name: "precompressed brotli preferred", path: "/app.css", acceptEncoding: "gzip, br",
+			wantStatus: http.StatusOK, wantEncoding: "br", wantBody: "brotli css",
+			wantETag: withSuffix(etag("app.css"), "br"), wantCache: cacheRevalidate, wantVary: true,
+		},
+		{
+			name: "brotli refused", path: "/app.css", acceptEncoding: "br;q=0, *",
+			wantStatus: http.StatusOK, wantEncoding: "gzip", wantBody: "gzipped css",
+			wantETag: withSuffix(etag("app.css"), "gz"), wantCache: cacheRevalidate, wantVary: true,
+		},
+		{
+			name: "no encoding accepted", path: "/app.css",
+			wantStatus: http.StatusOK, wantBody: "body{}",
+			wantETag: etag("app.css"), wantCache: cacheRevalidate, wantVary: true,
+		},
+		{
+			name: "gzipped on load", path: "/_app/immutable/entry.js", acceptEncoding: "gzip",
+			wantStatus: http.StatusOK, wantEncoding: "gzip", wantBody: string(script),
+			wantETag: withSuffix(etag("_app/immutable/entry.js"), "gz"), wantCache: cacheImmutable, wantVary: true,
+			gunzip: true,
+		},
+		{
+			name: "not compressible", path: "/favicon.png", acceptEncoding: "gzip",
+			wantStatus: http.StatusOK, wantBody: strings.Repeat("\x89PNG", 512),
+			wantETag: etag("favicon.png"), wantCache: cacheRevalidate,
+		},
+		{
+			name: "outside immutable", path: "/_app/version.json",
+			wantStatus: http.StatusOK, wantBody: `{"version":"1"}`,
+			wantETag: etag("_app/version.json"), wantCache: cacheRevalidate,
+		},
+		{
+			name: "matching ETag", path: "/app.css", acceptEncoding: "gzip", ifNoneMatch: withSuffix(etag("app.css"), "gz"),
+			// net/http drops Content-Encoding from 304 responses
+			wantStatus: http.StatusNotModified,
+			wantETag:   withSuffix(etag("app.css"), "gz"), wantCache: cacheRevalidate, wantVary: true,
+		},
+		{
+			name: "ETag of another encoding", path: "/app.css", acceptEncoding: "gzip", ifNoneMatch: etag("app.css"),
+			wantStatus: http.StatusOK, wantEncoding: "gzip", wantBody: "gzipped css",
+			wantETag: withSuffix(etag("app.css"), "gz"), wantCache: cacheRevalidate, wantVary: true,
+		},
+		{
+			name: "variant with its base", path: "/app.css.gz",
+			wantStatus: http.StatusNotFound, wantBody: "404 page not found\n",
+		},
+		{
+			name: "variant without a base", path: "/_app/immutable/orphan.js.gz",
+			wantStatus: http.StatusOK, wantBody: "no base",
+			wantETag: etag("_app/immutable/orphan.js.gz"), wantCache: cacheImmutable,
+		},
+		{
+			name: "missing", path: "/nope.js",
+			wantStatus: http.StatusNotFound, wantBody: "404 page not found\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			}
+			if tt.ifNoneMatch != "" {
+				req.Header.Set("If-None-Match", tt.ifNoneMatch)
+			}
+			rec := httptest.NewRecorder()
+			files.ServeHTTP(rec, req)
+			res := rec.Header()
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if got := res.Get("Content-Encoding"); got != tt.wantEncoding {
+				t.Errorf("Content-Encoding = %q, want %q", got, tt.wantEncoding)
+			}
+			if got := res.Get("ETag"); got != tt.wantETag {
+				t.Errorf("ETag = %q, want %q", got, tt.wantETag)
+			}
+			if got := res.Get("Cache-Control"); got != tt.wantCache {
+				t.Errorf("Cache-Control = %q, want %q", got, tt.wantCache)
+			}
+			if got := res.Get("Vary") == "Accept-Encoding"; got != tt.wantVary {
+				t.Errorf("Vary = %q", res.Get("Vary"))
+			}
+
+			body := rec.Body.Bytes()
+			if tt.gunzip {
+				zr, err := gzip.NewReader(bytes.NewReader(body))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if body, err = io.ReadAll(zr); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if string(body) != tt.wantBody {
+				t.Errorf("body = %.40q, want %.40q", body, tt.wantBody)
+			}
+		})
+	}
+}
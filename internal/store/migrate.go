@@ -0,0 +1,116 @@
+package store
+
+import (
+	"fmt"
+	"time"
+)
+
+// Migration changes how data is stored. Down undoes Up. Versions start at
+// 1 and increase by one.
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(st *Store) error
+	Down    func(st *Store) error
+}
+
+// appliedMigration is a record of the schema_migrations collection
+type appliedMigration struct {
+	Version   int       `json:"version"`
+	Name      string    `json:"name"`
+	AppliedAt time.Time `json:"applied_at"`
+}
+
+func (s *Store) migrations() (*Collection[appliedMigration], error) {
+	return NewCollection[appliedMigration](s, "schema_migrations")
+}
+
+func migrationKey(version int) string {
+	return fmt.Sprintf("%06d", version)
+}
+
+// Version returns the version of the last migration applied, 0 if none
+func (s *Store) Version() (int, error) {
+	applied, err := s.migrations()
+	if err != nil {
+		return 0, err
+	}
+	version := 0
+	for _, m := range applied.List() {
+		version = max(version, m.Version)
+	}
+	return version, nil
+}
+
+// Pending returns the migrations not applied yet, in order
+func (s *Store) Pending(migrations []Migration) ([]Migration, error) {
+	version, err := s.Version()
+	if err != nil {
+		return nil, err
+	}
+	var pending []Migration
+	for _, m := range migrations {
+		if m.Version > version {
+			pending = append(pending, m)
+		}
+	}
+	return pending, nil
+}
+
+// MigrateUp applies the pending migrations up to version target, or all of
+// them if target is 0. It returns those applied, and stops at the first
+// failure.
+func (s *Store) MigrateUp(migrations []Migration, target int) ([]Migration, error) {
+	applied, err := s.migrations()
+	if err != nil {
+		return nil, err
+	}
+	pending, err := s.Pending(migrations)
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for _, m := range pending {
+		if target > 0 && m.Version > target {
+			break
+		}
+		if err := m.Up(s); err != nil {
+			return done, fmt.Errorf("migration %d (%s): %w", m.Version, m.Name, err)
+		}
+		record := appliedMigration{Version: m.Version, Name: m.Name, AppliedAt: time.Now().UTC()}
+		if err := applied.Put(migrationKey(m.Version), record); err != nil {
+			return done, err
+		}
+		done = append(done, m)
+	}
+	return done, nil
+}
+
+// MigrateDown undoes the applied migrations above version target, newest
+// first. It returns those undone, and stops at the first failure.
+func (s *Store) MigrateDown(migrations []Migration, target int) ([]Migration, error) {
+	applied, err := s.migrations()
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for i := len(migrations) - 1; i >= 0; i-- {
+		m := migrations[i]
+		if m.Version <= target {
+			break
+		}
+		if _, ok := applied.Get(migrationKey(m.Version)); !ok {
+			continue
+		}
+		if err := m.Down(s); err != nil {
+			return done, fmt.Errorf("migration %d (%s): %w", m.Version, m.Name, err)
+		}
+		if err := applied.Delete(migrationKey(m.Version)); err != nil {
+			return done, err
+		}
+		done = append(done, m)
+	}
+	return done, nil
+}
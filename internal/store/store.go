@@ -0,0 +1,270 @@
+// Package store provides a small persistence layer built on JSON files.
+//
+// Each Collection is a map of string keys to values, loaded fully into memory
+// and written back to <dir>/<name>.json on every change. This keeps the binary
+// free of database drivers while being plenty for the data volumes of a
+// single Awwdio deployment. An empty directory keeps everything in memory.
+//
+// A collection reloads its file when another process, such as the admin
+// CLI, has replaced it, so both can work on the store of a running server.
+// Writes hold a lock on <name>.json.lock across reload and save, so
+// concurrent processes do not overwrite each other's changes.
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/kaustavdm/awwdio/internal/filelock"
+)
+
+// refreshInterval is how often reads check whether another process
+// changed a collection file. Writes always check.
+const refreshInterval = time.Second
+
+// Store is a directory holding JSON collections
+type Store struct {
+	dir         string
+	mu          sync.Mutex
+	collections map[string]any // Open collections by name
+}
+
+// Open prepares a store rooted at dir, creating the directory if needed.
+// An empty dir returns an in-memory store.
+func Open(dir string) (*Store, error) {
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return nil, fmt.Errorf("failed to create data directory: %w", err)
+		}
+	}
+	return &Store{dir: dir, collections: make(map[string]any)}, nil
+}
+
+// Dir returns the data directory, or an empty string for in-memory stores
+func (s *Store) Dir() string {
+	return s.dir
+}
+
+// Collection is a keyed set of values of type T
+type Collection[T any] struct {
+	mu      sync.RWMutex
+	path    string
+	lock    *filelock.File // Held by writers across processes
+	checked atomic.Int64   // When reads last checked the file, in Unix nanoseconds
+	modTime time.Time      // Of the file as last read or written, with size
+	size    int64
+	items   map[string]T
+}
+
+// NewCollection loads the named collection from the store. Opening a
+// collection again returns the same one, so that its readers see each
+// other's writes at once.
+func NewCollection[T any](s *Store, name string) (*Collection[T], error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if open, ok := s.collections[name]; ok {
+		c, ok := open.(*Collection[T])
+		if !ok {
+			return nil, fmt.Errorf("collection %s is already open with another type", name)
+		}
+		return c, nil
+	}
+
+	c := &Collection[T]{items: make(map[string]T)}
+	if s.dir == "" {
+		s.collections[name] = c
+		return c, nil
+	}
+
+	c.path = filepath.Join(s.dir, name+".json")
+	lock, err := filelock.Open(c.path + ".lock")
+	if err != nil {
+		return nil, err
+	}
+	c.lock = lock
+	if err := c.load(true); err != nil {
+		return nil, err
+	}
+	s.collections[name] = c
+	return c, nil
+}
+
+// load reads the collection file, unless force is false and it seems
+// unchanged since it was last read or written. Callers must hold the write
+// lock, or own c.
+func (c *Collection[T]) load(force bool) error {
+	info, err := os.Stat(c.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read collection %s: %w", c.path, err)
+	}
+	if !force && c.unchanged(info) {
+		return nil
+	}
+
+	data, err := os.ReadFile(c.path)
+	if err != nil {
+		return fmt.Errorf("failed to read collection %s: %w", c.path, err)
+	}
+	items := make(map[string]T)
+	if err := json.Unmarshal(data, &items); err != nil {
+		return fmt.Errorf("failed to decode collection %s: %w", c.path, err)
+	}
+	c.items, c.modTime, c.size = items, info.ModTime(), info.Size()
+	return nil
+}
+
+// unchanged reports whether info is of the file as last read or written.
+// Sizes tell apart writes within the resolution of modification times.
+func (c *Collection[T]) unchanged(info os.FileInfo) bool {
+	return info.ModTime().Equal(c.modTime) && info.Size() == c.size
+}
+
+// refresh reloads the collection if another process changed its file,
+// checking at most once per refreshInterval. Failures are not returned:
+// the values in memory are still usable.
+func (c *Collection[T]) refresh() {
+	if c.path == "" {
+		return
+	}
+	now := time.Now().UnixNano()
+	last := c.checked.Load()
+	if now-last < int64(refreshInterval) || !c.checked.CompareAndSwap(last, now) {
+		return
+	}
+	c.mu.RLock()
+	info, err := os.Stat(c.path)
+	current := err == nil && c.unchanged(info)
+	c.mu.RUnlock()
+	if current {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.load(false)
+}
+
+// Get returns the value stored under id
+func (c *Collection[T]) Get(id string) (T, bool) {
+	c.refresh()
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	v, ok := c.items[id]
+	return v, ok
+}
+
+// Put stores v under id, replacing any existing value
+func (c *Collection[T]) Put(id string, v T) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.lock.Lock(); err != nil {
+		return err
+	}
+	defer c.lock.Unlock()
+	if err := c.reload(); err != nil {
+		return err
+	}
+	c.items[id] = v
+	return c.save()
+}
+
+// Update atomically reads, modifies and writes the value stored under id.
+// fn receives the current value and whether it exists. Returning an error
+// from fn leaves the collection unchanged.
+func (c *Collection[T]) Update(id string, fn func(v T, exists bool) (T, error)) (T, error) {
+	var zero T
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.lock.Lock(); err != nil {
+		return zero, err
+	}
+	defer c.lock.Unlock()
+	if err := c.reload(); err != nil {
+		return zero, err
+	}
+	current, ok := c.items[id]
+	next, err := fn(current, ok)
+	if err != nil {
+		return current, err
+	}
+	c.items[id] = next
+	return next, c.save()
+}
+
+// Delete removes the value stored under id
+func (c *Collection[T]) Delete(id string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.lock.Lock(); err != nil {
+		return err
+	}
+	defer c.lock.Unlock()
+	if err := c.reload(); err != nil {
+		return err
+	}
+	if _, ok := c.items[id]; !ok {
+		return nil
+	}
+	delete(c.items, id)
+	return c.save()
+}
+
+// List returns all values ordered by key
+func (c *Collection[T]) List() []T {
+	c.refresh()
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	keys := make([]string, 0, len(c.items))
+	for k := range c.items {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	values := make([]T, 0, len(keys))
+	for _, k := range keys {
+		values = append(values, c.items[k])
+	}
+	return values
+}
+
+// save writes the collection to disk. Callers must hold the write lock.
+func (c *Collection[T]) save() error {
+	if c.path == "" {
+		return nil
+	}
+	data, err := json.Marshal(c.items)
+	if err != nil {
+		return fmt.Errorf("failed to encode collection: %w", err)
+	}
+	// Write to a temporary file and rename so a crash never leaves a truncated file
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write collection: %w", err)
+	}
+	if err := os.Rename(tmp, c.path); err != nil {
+		return fmt.Errorf("failed to replace collection: %w", err)
+	}
+	if info, err := os.Stat(c.path); err == nil {
+		c.modTime, c.size = info.ModTime(), info.Size()
+	}
+	return nil
+}
+
+// reload picks up changes made by other processes before a write, so that
+// they are not overwritten. Callers must hold the write lock and the file
+// lock. The file is always read: writes of the same size within the
+// resolution of modification times look unchanged.
+func (c *Collection[T]) reload() error {
+	if c.path == "" {
+		return nil
+	}
+	return c.load(true)
+}
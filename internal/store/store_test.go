@@ -0,0 +1,71 @@
+package store
+
+import (
+	"sync"
+	"testing"
+)
+
+// Stores on the same directory, as in separate processes, do not lose
+// each other's updates
+func TestConcurrentUpdates(t *testing.T) {
+	dir := t.TempDir()
+	var wg sync.WaitGroup
+	for range 2 {
+		st, err := Open(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		counters, err := NewCollection[int](st, "counters")
+		if err != nil {
+			t.Fatal(err)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 100 {
+				if _, err := counters.Update("n", func(n int, _ bool) (int, error) { return n + 1, nil }); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	st, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	counters, err := NewCollection[int](st, "counters")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := counters.Get("n"); n != 200 {
+		t.Errorf("got %d, want 200", n)
+	}
+}
+
+// Opening a collection again shares it, so writes are seen at once
+func TestCollectionShared(t *testing.T) {
+	st, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := NewCollection[string](st, "names")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := NewCollection[string](st, "names")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Put("x", "alice"); err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := b.Get("x"); !ok || v != "alice" {
+		t.Errorf("got %q, %v, want alice", v, ok)
+	}
+	if _, err := NewCollection[int](st, "names"); err == nil {
+		t.Error("opened a collection with another type")
+	}
+}
@@ -0,0 +1,279 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// queueSize bounds spans waiting for export. Spans are dropped when the
+	// collector cannot keep up, rather than slowing down requests.
+	queueSize = 2048
+	// batchSize is the most spans sent in one request
+	batchSize = 512
+	// flushInterval is how often queued spans are sent
+	flushInterval = 5 * time.Second
+	// exportTimeout bounds a single export request
+	exportTimeout = 10 * time.Second
+)
+
+// Options configures the OTLP exporter
+type Options struct {
+	// Endpoint is the OTLP/HTTP base URL, e.g. http://localhost:4318.
+	// "/v1/traces" is appended unless the URL already ends with it.
+	Endpoint string
+	// Headers are sent with every export, e.g. for collector authentication
+	Headers map[string]string
+	// ServiceName is reported as the service.name resource attribute
+	ServiceName string
+}
+
+var exporterPtr atomic.Pointer[exporter]
+
+func currentExporter() *exporter {
+	return exporterPtr.Load()
+}
+
+// Setup starts exporting sampled spans with OTLP/HTTP JSON. The returned
+// function flushes queued spans and stops the exporter.
+func Setup(opts Options) func(context.Context) {
+	url := strings.TrimSuffix(opts.Endpoint, "/")
+	if !strings.HasSuffix(url, "/v1/traces") {
+		url += "/v1/traces"
+	}
+	e := &exporter{
+		url:         url,
+		headers:     opts.Headers,
+		serviceName: opts.ServiceName,
+		client:      &http.Client{Timeout: exportTimeout},
+		queue:       make(chan finishedSpan, queueSize),
+		done:        make(chan struct{}),
+	}
+	exporterPtr.Store(e)
+	go e.run()
+
+	slog.Info("Tracing enabled", slog.String("endpoint", url))
+	return func(ctx context.Context) {
+		exporterPtr.CompareAndSwap(e, nil)
+		e.close()
+		select {
+		case <-e.done:
+		case <-ctx.Done():
+		}
+	}
+}
+
+// finishedSpan is an ended span with its end time
+type finishedSpan struct {
+	span *Span
+	end  time.Time
+}
+
+type exporter struct {
+	url         string
+	headers     map[string]string
+	serviceName string
+	client      *http.Client
+
+	// mu guards closed, so no span is sent on the queue after it is closed
+	mu      sync.Mutex
+	closed  bool
+	queue   chan finishedSpan
+	done    chan struct{}
+	dropped atomic.Int64
+}
+
+// enqueue queues an ended span for export. Spans ending after shutdown are
+// dropped.
+func (e *exporter) enqueue(s *Span, end time.Time) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.closed {
+		return
+	}
+	select {
+	case e.queue <- finishedSpan{span: s, end: end}:
+	default:
+		e.dropped.Add(1)
+	}
+}
+
+// close stops accepting spans, letting run send the rest and return
+func (e *exporter) close() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if !e.closed {
+		e.closed = true
+		close(e.queue)
+	}
+}
+
+// run sends queued spans in batches until the queue is closed
+func (e *exporter) run() {
+	defer close(e.done)
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	var batch []finishedSpan
+	for {
+		select {
+		case fs, ok := <-e.queue:
+			if !ok {
+				e.export(batch)
+				return
+			}
+			batch = append(batch, fs)
+			if len(batch) < batchSize {
+				continue
+			}
+		case <-ticker.C:
+		}
+		e.export(batch)
+		batch = batch[:0]
+	}
+}
+
+func (e *exporter) export(batch []finishedSpan) {
+	if n := e.dropped.Swap(0); n > 0 {
+		slog.Warn("Dropped spans, export queue full", slog.Int64("count", n))
+	}
+	if len(batch) == 0 {
+		return
+	}
+
+	body, err := json.Marshal(e.request(batch))
+	if err != nil {
+		slog.Error("Failed to encode spans", slog.String("error", err.Error()))
+		return
+	}
+	req, err := http.NewRequest(http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		slog.Error("Failed to build span export request", slog.String("error", err.Error()))
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range e.headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		slog.Warn("Failed to export spans", slog.String("error", err.Error()), slog.Int("spans", len(batch)))
+		return
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		slog.Warn("Failed to export spans", slog.Int("status", resp.StatusCode), slog.Int("spans", len(batch)))
+	}
+}
+
+// The types below are the OTLP JSON encoding of ExportTraceServiceRequest.
+// IDs are hex strings and 64-bit integers are decimal strings.
+
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              Kind           `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string         `json:"key"`
+	Value map[string]any `json:"value"`
+}
+
+func (e *exporter) request(batch []finishedSpan) otlpRequest {
+	spans := make([]otlpSpan, 0, len(batch))
+	for _, fs := range batch {
+		s := fs.span
+		s.mu.Lock()
+		span := otlpSpan{
+			TraceID:           s.sc.TraceID.String(),
+			SpanID:            s.sc.SpanID.String(),
+			Name:              s.name,
+			Kind:              s.kind,
+			StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(fs.end.UnixNano(), 10),
+			Status:            otlpStatus{Code: s.status, Message: s.statusMsg},
+		}
+		if s.parent != (SpanID{}) {
+			span.ParentSpanID = s.parent.String()
+		}
+		for k, v := range s.attributes {
+			span.Attributes = append(span.Attributes, keyValue(k, v))
+		}
+		s.mu.Unlock()
+		spans = append(spans, span)
+	}
+
+	return otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource: otlpResource{Attributes: []otlpKeyValue{keyValue("service.name", e.serviceName)}},
+		ScopeSpans: []otlpScopeSpans{{
+			Scope: otlpScope{Name: "github.com/kaustavdm/awwdio"},
+			Spans: spans,
+		}},
+	}}}
+}
+
+// keyValue encodes an attribute as an OTLP AnyValue
+func keyValue(key string, v any) otlpKeyValue {
+	var value map[string]any
+	switch v := v.(type) {
+	case string:
+		value = map[string]any{"stringValue": v}
+	case bool:
+		value = map[string]any{"boolValue": v}
+	case int:
+		value = map[string]any{"intValue": strconv.Itoa(v)}
+	case int64:
+		value = map[string]any{"intValue": strconv.FormatInt(v, 10)}
+	case float64:
+		value = map[string]any{"doubleValue": v}
+	default:
+		value = map[string]any{"stringValue": fmt.Sprint(v)}
+	}
+	return otlpKeyValue{Key: key, Value: value}
+}
@@ -0,0 +1,159 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// received is the part of an OTLP JSON export request the tests look at
+type received struct {
+	ResourceSpans []struct {
+		Resource struct {
+			Attributes []receivedKeyValue `json:"attributes"`
+		} `json:"resource"`
+		ScopeSpans []struct {
+			Spans []struct {
+				TraceID           string             `json:"traceId"`
+				SpanID            string             `json:"spanId"`
+				ParentSpanID      string             `json:"parentSpanId"`
+				Name              string             `json:"name"`
+				Kind              int                `json:"kind"`
+				StartTimeUnixNano string             `json:"startTimeUnixNano"`
+				EndTimeUnixNano   string             `json:"endTimeUnixNano"`
+				Attributes        []receivedKeyValue `json:"attributes"`
+				Status            struct {
+					Code    int    `json:"code"`
+					Message string `json:"message"`
+				} `json:"status"`
+			} `json:"spans"`
+		} `json:"scopeSpans"`
+	} `json:"resourceSpans"`
+}
+
+type receivedKeyValue struct {
+	Key   string         `json:"key"`
+	Value map[string]any `json:"value"`
+}
+
+func TestExport(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		requests []received
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/v1/traces" {
+			t.Errorf("export to %s %s", r.Method, r.URL.Path)
+		}
+		if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("Content-Type = %q", ct)
+		}
+		if auth := r.Header.Get("Authorization"); auth != "Bearer collector" {
+			t.Errorf("Authorization = %q", auth)
+		}
+		var req received
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decoding export: %v", err)
+		}
+		mu.Lock()
+		requests = append(requests, req)
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	shutdown := Setup(Options{
+		Endpoint:    srv.URL + "/",
+		Headers:     map[string]string{"Authorization": "Bearer collector"},
+		ServiceName: "awwdio-test",
+	})
+
+	ctx, parent := Start(context.Background(), "GET /rooms", KindServer)
+	_, child := Start(ctx, "twilio", KindClient)
+	child.SetAttribute("http.response.status_code", 502)
+	child.SetAttribute("retry", true)
+	child.SetAttribute("ratio", 0.5)
+	child.SetAttribute("peer", "video.twilio.com")
+	child.SetError(errors.New("bad gateway"))
+	child.End()
+	child.End()
+	parent.End()
+
+	_, unsampled := startWithParent(context.Background(), "ignored", KindServer, SpanContext{
+		TraceID: TraceID{1}, SpanID: SpanID{1}, Sampled: false,
+	})
+	unsampled.End()
+
+	stop, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	shutdown(stop)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(requests) != 1 || len(requests[0].ResourceSpans) != 1 {
+		t.Fatalf("got %d export requests, want 1: %+v", len(requests), requests)
+	}
+	rs := requests[0].ResourceSpans[0]
+	if len(rs.Resource.Attributes) != 1 || rs.Resource.Attributes[0].Key != "service.name" ||
+		rs.Resource.Attributes[0].Value["stringValue"] != "awwdio-test" {
+		t.Errorf("resource attributes = %+v", rs.Resource.Attributes)
+	}
+	spans := rs.ScopeSpans[0].Spans
+	if len(spans) != 2 {
+		t.Fatalf("got %d spans, want 2: %+v", len(spans), spans)
+	}
+
+	c, p := spans[0], spans[1]
+	if c.Name != "twilio" || p.Name != "GET /rooms" {
+		t.Fatalf("span names = %q, %q", c.Name, p.Name)
+	}
+	if c.TraceID != parent.SpanContext().TraceID.String() || c.TraceID != p.TraceID {
+		t.Errorf("trace IDs = %s, %s, want %s", c.TraceID, p.TraceID, parent.SpanContext().TraceID)
+	}
+	if c.SpanID != child.SpanContext().SpanID.String() || c.ParentSpanID != p.SpanID || p.ParentSpanID != "" {
+		t.Errorf("child %s parent %s, root parent %q", c.SpanID, c.ParentSpanID, p.ParentSpanID)
+	}
+	if c.Kind != int(KindClient) || p.Kind != int(KindServer) {
+		t.Errorf("kinds = %d, %d", c.Kind, p.Kind)
+	}
+	if c.Status.Code != statusError || c.Status.Message != "bad gateway" || p.Status.Code != statusUnset {
+		t.Errorf("statuses = %+v, %+v", c.Status, p.Status)
+	}
+	start, err1 := strconv.ParseInt(c.StartTimeUnixNano, 10, 64)
+	end, err2 := strconv.ParseInt(c.EndTimeUnixNano, 10, 64)
+	if err1 != nil || err2 != nil || start == 0 || end < start {
+		t.Errorf("times = %s..%s", c.StartTimeUnixNano, c.EndTimeUnixNano)
+	}
+
+	attrs := map[string]map[string]any{}
+	for _, kv := range c.Attributes {
+		attrs[kv.Key] = kv.Value
+	}
+	wantAttrs := map[string]map[string]any{
+		"http.response.status_code": {"intValue": "502"},
+		"retry":                     {"boolValue": true},
+		"ratio":                     {"doubleValue": 0.5},
+		"peer":                      {"stringValue": "video.twilio.com"},
+	}
+	for k, want := range wantAttrs {
+		got := attrs[k]
+		if len(got) != 1 {
+			t.Errorf("attribute %s = %v, want %v", k, got, want)
+			continue
+		}
+		for typ, v := range want {
+			if got[typ] != v {
+				t.Errorf("attribute %s = %v, want %v", k, got, want)
+			}
+		}
+	}
+
+	if currentExporter() != nil {
+		t.Error("exporter still set after shutdown")
+	}
+}
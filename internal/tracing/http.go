@@ -0,0 +1,48 @@
+package tracing
+
+import (
+	"net/http"
+
+	"github.com/kaustavdm/awwdio/internal/metrics"
+	"github.com/kaustavdm/awwdio/internal/respwriter"
+)
+
+// Middleware starts a server span for every request, continuing the trace
+// of the caller when it sends a traceparent header. It must run inside
+// metrics.Middleware so that spans are named after the matched route.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parent, _ := Extract(r.Header)
+		ctx, span := startWithParent(r.Context(), r.Method, KindServer, parent)
+		defer span.End()
+
+		r = r.WithContext(ctx)
+		rec := respwriter.Wrap(w)
+		next.ServeHTTP(rec, r)
+
+		status := rec.Status()
+
+		name := r.Method
+		if route := metrics.Route(r); route != "" {
+			name += " " + route
+			span.SetAttribute("http.route", route)
+		}
+		span.SetName(name)
+		span.SetAttribute("http.request.method", r.Method)
+		span.SetAttribute("url.path", r.URL.Path)
+		span.SetAttribute("http.response.status_code", status)
+		if ua := r.UserAgent(); ua != "" {
+			span.SetAttribute("user_agent.original", ua)
+		}
+		if status >= 500 {
+			span.SetError(errStatus(status))
+		}
+	})
+}
+
+// errStatus describes a failed HTTP response
+type errStatus int
+
+func (e errStatus) Error() string {
+	return http.StatusText(int(e))
+}
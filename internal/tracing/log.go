@@ -0,0 +1,35 @@
+package tracing
+
+import (
+	"context"
+	"log/slog"
+)
+
+// LogHandler adds trace_id and span_id to records logged with a context
+// that carries a span, e.g. slog.InfoContext(r.Context(), ...)
+func LogHandler(h slog.Handler) slog.Handler {
+	return logHandler{h}
+}
+
+type logHandler struct {
+	slog.Handler
+}
+
+func (h logHandler) Handle(ctx context.Context, rec slog.Record) error {
+	if s := SpanFromContext(ctx); s != nil {
+		rec = rec.Clone()
+		rec.AddAttrs(
+			slog.String("trace_id", s.sc.TraceID.String()),
+			slog.String("span_id", s.sc.SpanID.String()),
+		)
+	}
+	return h.Handler.Handle(ctx, rec)
+}
+
+func (h logHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return logHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h logHandler) WithGroup(name string) slog.Handler {
+	return logHandler{h.Handler.WithGroup(name)}
+}
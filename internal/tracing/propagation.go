@@ -0,0 +1,56 @@
+package tracing
+
+import (
+	"context"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+// traceparentHeader is the W3C Trace Context header
+const traceparentHeader = "traceparent"
+
+// Extract reads the parent span from a W3C traceparent header
+func Extract(h http.Header) (SpanContext, bool) {
+	// version-traceid-spanid-flags, e.g.
+	// 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01
+	parts := strings.Split(strings.TrimSpace(h.Get(traceparentHeader)), "-")
+	var version [1]byte
+	if len(parts) < 4 || !decodeHex(version[:], parts[0]) || version[0] == 0xff {
+		return SpanContext{}, false
+	}
+	// Version 00 has exactly four fields; later versions may append more
+	if parts[0] == "00" && len(parts) != 4 {
+		return SpanContext{}, false
+	}
+
+	var sc SpanContext
+	var flags [1]byte
+	if !decodeHex(sc.TraceID[:], parts[1]) || !decodeHex(sc.SpanID[:], parts[2]) || !decodeHex(flags[:], parts[3]) {
+		return SpanContext{}, false
+	}
+	sc.Sampled = flags[0]&0x01 == 1
+	return sc, sc.IsValid()
+}
+
+// Inject writes the span in ctx as a traceparent header
+func Inject(ctx context.Context, h http.Header) {
+	s := SpanFromContext(ctx)
+	if s == nil {
+		return
+	}
+	flags := "00"
+	if s.sc.Sampled {
+		flags = "01"
+	}
+	h.Set(traceparentHeader, "00-"+s.sc.TraceID.String()+"-"+s.sc.SpanID.String()+"-"+flags)
+}
+
+// decodeHex decodes lowercase hex of exactly len(dst) bytes
+func decodeHex(dst []byte, s string) bool {
+	if len(s) != 2*len(dst) || strings.ToLower(s) != s {
+		return false
+	}
+	_, err := hex.Decode(dst, []byte(s))
+	return err == nil
+}
@@ -0,0 +1,92 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+func TestExtract(t *testing.T) {
+	const (
+		traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+		spanID  = "00f067aa0ba902b7"
+	)
+	tests := []struct {
+		name        string
+		header      string
+		wantOK      bool
+		wantSampled bool
+	}{
+		{"sampled", "00-" + traceID + "-" + spanID + "-01", true, true},
+		{"not sampled", "00-" + traceID + "-" + spanID + "-00", true, false},
+		{"other flags ignored", "00-" + traceID + "-" + spanID + "-03", true, true},
+		{"surrounding whitespace", "  00-" + traceID + "-" + spanID + "-01 ", true, true},
+		{"future version with extra fields", "cc-" + traceID + "-" + spanID + "-01-what-the-future", true, true},
+		{"version 00 with extra fields", "00-" + traceID + "-" + spanID + "-01-extra", false, false},
+		{"version ff", "ff-" + traceID + "-" + spanID + "-01", false, false},
+		{"version not hex", "0g-" + traceID + "-" + spanID + "-01", false, false},
+		{"version too long", "000-" + traceID + "-" + spanID + "-01", false, false},
+		{"uppercase trace ID", "00-4BF92F3577B34DA6A3CE929D0E0E4736-" + spanID + "-01", false, false},
+		{"uppercase flags", "00-" + traceID + "-" + spanID + "-0A", false, false},
+		{"all-zero trace ID", "00-00000000000000000000000000000000-" + spanID + "-01", false, false},
+		{"all-zero span ID", "00-" + traceID + "-0000000000000000-01", false, false},
+		{"short trace ID", "00-4bf92f3577b34da6a3ce929d0e0e47-" + spanID + "-01", false, false},
+		{"missing flags", "00-" + traceID + "-" + spanID, false, false},
+		{"empty", "", false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := http.Header{}
+			h.Set("Traceparent", tt.header)
+			sc, ok := Extract(h)
+			if ok != tt.wantOK {
+				t.Fatalf("Extract(%q) ok = %v, want %v", tt.header, ok, tt.wantOK)
+			}
+			if !ok {
+				return
+			}
+			if sc.TraceID.String() != traceID || sc.SpanID.String() != spanID {
+				t.Errorf("Extract(%q) = %s-%s", tt.header, sc.TraceID, sc.SpanID)
+			}
+			if sc.Sampled != tt.wantSampled {
+				t.Errorf("Extract(%q) sampled = %v, want %v", tt.header, sc.Sampled, tt.wantSampled)
+			}
+		})
+	}
+}
+
+func TestInject(t *testing.T) {
+	tests := []struct {
+		name    string
+		sampled bool
+		flags   string
+	}{
+		{"sampled", true, "01"},
+		{"not sampled", false, "00"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parent := SpanContext{Sampled: tt.sampled}
+			parent.TraceID[0], parent.SpanID[0] = 1, 2
+			ctx, span := startWithParent(context.Background(), "test", KindClient, parent)
+
+			h := http.Header{}
+			Inject(ctx, h)
+			want := "00-" + parent.TraceID.String() + "-" + span.SpanContext().SpanID.String() + "-" + tt.flags
+			if got := h.Get("traceparent"); got != want {
+				t.Fatalf("traceparent = %q, want %q", got, want)
+			}
+
+			sc, ok := Extract(h)
+			if !ok || sc != span.SpanContext() {
+				t.Errorf("Extract(Inject()) = %v, %v, want %v", sc, ok, span.SpanContext())
+			}
+		})
+	}
+
+	h := http.Header{}
+	Inject(context.Background(), h)
+	if got := h.Get("traceparent"); got != "" {
+		t.Errorf("traceparent without a span = %q, want none", got)
+	}
+}
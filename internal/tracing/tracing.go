@@ -0,0 +1,168 @@
+// Package tracing records spans compatible with OpenTelemetry and exports
+// them over OTLP/HTTP. Like the metrics package, it implements just what the
+// server needs (W3C trace context propagation, server and client spans and
+// a batching exporter) to stay free of the OpenTelemetry SDK.
+//
+// Tracing is a no-op until Setup is called with an endpoint: spans still
+// carry trace and span IDs, so logs can be correlated with upstream
+// traces, but nothing is recorded or sent.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// Kind is the OpenTelemetry span kind
+type Kind int
+
+const (
+	KindInternal Kind = 1
+	KindServer   Kind = 2
+	KindClient   Kind = 3
+)
+
+// Status codes of a span
+const (
+	statusUnset = 0
+	statusError = 2
+)
+
+type (
+	TraceID [16]byte
+	SpanID  [8]byte
+)
+
+func (id TraceID) String() string { return hex.EncodeToString(id[:]) }
+func (id SpanID) String() string  { return hex.EncodeToString(id[:]) }
+
+// SpanContext identifies a span within a trace
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Sampled bool
+}
+
+// IsValid reports whether both IDs are set
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != TraceID{} && sc.SpanID != SpanID{}
+}
+
+// Span is a timed operation. All methods are safe on a nil span.
+type Span struct {
+	sc     SpanContext
+	parent SpanID
+	kind   Kind
+	start  time.Time
+
+	mu         sync.Mutex
+	name       string
+	attributes map[string]any
+	status     int
+	statusMsg  string
+	ended      bool
+}
+
+type spanKey struct{}
+
+// SpanFromContext returns the span in ctx, or nil
+func SpanFromContext(ctx context.Context) *Span {
+	s, _ := ctx.Value(spanKey{}).(*Span)
+	return s
+}
+
+// Start starts a span as a child of the span in ctx, if any, and returns a
+// context carrying the new span
+func Start(ctx context.Context, name string, kind Kind) (context.Context, *Span) {
+	var parent SpanContext
+	if p := SpanFromContext(ctx); p != nil {
+		parent = p.sc
+	}
+	return startWithParent(ctx, name, kind, parent)
+}
+
+// startWithParent starts a span under a possibly remote parent. A new trace
+// is started when parent is not valid.
+func startWithParent(ctx context.Context, name string, kind Kind, parent SpanContext) (context.Context, *Span) {
+	s := &Span{
+		kind:  kind,
+		start: time.Now(),
+		name:  name,
+	}
+	if parent.IsValid() {
+		s.sc.TraceID = parent.TraceID
+		s.parent = parent.SpanID
+		s.sc.Sampled = parent.Sampled
+	} else {
+		rand.Read(s.sc.TraceID[:])
+		s.sc.Sampled = true
+	}
+	rand.Read(s.sc.SpanID[:])
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+// SpanContext returns the span's identifiers
+func (s *Span) SpanContext() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.sc
+}
+
+// SetName replaces the span name, e.g. once the matched route is known
+func (s *Span) SetName(name string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.name = name
+}
+
+// SetAttribute records a string, bool, integer or float attribute
+func (s *Span) SetAttribute(key string, value any) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.attributes == nil {
+		s.attributes = map[string]any{}
+	}
+	s.attributes[key] = value
+}
+
+// SetError marks the span as failed
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status = statusError
+	s.statusMsg = err.Error()
+}
+
+// End finishes the span and hands it to the exporter, if one is set up
+// and the trace is sampled. Only the first call has an effect.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	end := time.Now()
+
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.mu.Unlock()
+
+	if e := currentExporter(); e != nil && s.sc.Sampled {
+		e.enqueue(s, end)
+	}
+}
@@ -1,15 +1,30 @@
 package main
 
 import (
+	"context"
 	"embed"
+	"flag"
+	"fmt"
+	"io"
 	"io/fs"
 	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/kaustavdm/awwdio/config"
 	"github.com/kaustavdm/awwdio/internal/api"
+	"github.com/kaustavdm/awwdio/internal/api/middleware"
+	"github.com/kaustavdm/awwdio/internal/logging"
+	"github.com/kaustavdm/awwdio/internal/metrics"
+	"github.com/kaustavdm/awwdio/internal/migrations"
+	httpserver "github.com/kaustavdm/awwdio/internal/server"
+	"github.com/kaustavdm/awwdio/internal/static"
+	"github.com/kaustavdm/awwdio/internal/store"
+	"github.com/kaustavdm/awwdio/internal/tracing"
 )
 
 //go:embed web/build/*
@@ -18,16 +33,20 @@ var buildFS embed.FS
 //go:embed web/static/*
 var staticFS embed.FS
 
-// init is run before main(), so it is the ideal place to set up logging
-// Note that we do not need to call init() in main() as it is automatically called by Go
-func init() {
+// setupLogging sends logs to out. Quiet logging, for CLI commands, drops
+// Info records unless DEBUG is set.
+func setupLogging(out io.Writer, quiet bool) {
 	// 0. Set up logging
-	// Check if JSON_LOGGER and DEBUG environment variables are set
+	// Check if JSON_LOGGER, DEBUG and LOG_UNREDACTED environment variables are set
 	_, jsonLogger := os.LookupEnv("JSON_LOGGER")
 	_, debug := os.LookupEnv("DEBUG")
+	_, unredacted := os.LookupEnv("LOG_UNREDACTED")
 
-	// Default to log level Info
+	// Default to log level Info, or Warn when quiet
 	var level slog.Level
+	if quiet {
+		level = slog.LevelWarn
+	}
 
 	// If DEBUG is set, set the log level to Debug
 	if debug {
@@ -35,104 +54,282 @@ func init() {
 	}
 
 	// If JSON_LOGGER is set, use JSON logging
+	var handler slog.Handler
 	if jsonLogger {
-		jsonh := slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
+		handler = slog.NewJSONHandler(out, &slog.HandlerOptions{
 			Level: level,
 		})
-		slog.SetDefault(slog.New(jsonh))
 	} else {
 		// Otherwise, use text logging
-		texth := slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		handler = slog.NewTextHandler(out, &slog.HandlerOptions{
 			Level: level,
 		})
-		slog.SetDefault(slog.New(texth))
 	}
 
-	slog.Info("Logger initialized", slog.Bool("json", jsonLogger), slog.Bool("debug", debug))
+	// Mask contact details and tokens, unless LOG_UNREDACTED is set for local development
+	if !unredacted {
+		handler = logging.Redact(handler)
+	}
+	slog.SetDefault(slog.New(tracing.LogHandler(handler)))
+
+	slog.Info("Logger initialized", slog.Bool("json", jsonLogger), slog.Bool("debug", debug), slog.Bool("redacted", !unredacted))
+	if unredacted {
+		slog.Warn("LOG_UNREDACTED is set, logs contain contact details and tokens")
+	}
 }
 
 func main() {
+	os.Exit(run(os.Args[1:]))
+}
+
+// serve runs the server until it is interrupted. It returns an error if the
+// server cannot start or fails.
+func serve(args []string) error {
+	// 0.a. Parse command line flags
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	configPath := flags.String("config", os.Getenv("CONFIG_FILE"), "path to a JSON, YAML or TOML config file")
+	check := flags.Bool("check-config", false, "same as the config check command")
+	flags.Parse(args)
+
+	// -check-config predates the config check command
+	if *check {
+		return checkConfig(*configPath)
+	}
+
+	// 0.b. Shut down gracefully on Ctrl-C or SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	// 1. Load configuration
-	cfg, err := config.LoadConfig()
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		return fmt.Errorf("invalid configuration:\n%w", err)
+	}
+
+	// 1.a. Export traces if an OTLP endpoint is configured
+	if cfg.OTLPEndpoint != "" {
+		shutdownTracing := tracing.Setup(tracing.Options{
+			Endpoint:    cfg.OTLPEndpoint,
+			Headers:     cfg.OTLPHeaders,
+			ServiceName: cfg.ServiceName,
+		})
+		defer func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			shutdownTracing(ctx)
+		}()
+	}
+
+	// 1.b. Open the data store
+	st, err := store.Open(cfg.DataDir)
+	if err != nil {
+		return fmt.Errorf("failed to open data store: %w", err)
+	}
+	if cfg.DataDir == "" {
+		slog.Warn("DATA_DIR not set, data will not survive restarts")
+	}
+	if pending, err := st.Pending(migrations.All(cfg)); err != nil {
+		return fmt.Errorf("failed to read store version: %w", err)
+	} else if len(pending) > 0 {
+		slog.Warn("Store migrations pending, run awwdio migrate up", slog.Int("pending", len(pending)))
+	}
+
+	// 1.c. Publish the configuration and reload it on SIGHUP or file change
+	cfgProvider := config.NewProvider(cfg, *configPath)
+	go cfgProvider.Watch(ctx)
+
+	// 2. Set up the API
+	apiServer, err := api.New(ctx, cfgProvider, st)
 	if err != nil {
-		slog.Error("Failed to load configuration", slog.String("error", err.Error()))
-		return
+		return fmt.Errorf("failed to set up API: %w", err)
+	}
+
+	// 2.a. Expose metrics on a separate listener, only if METRICS_PORT is
+	// set, so they are never public on the main port
+	if cfg.MetricsPort == "" {
+		slog.Info("METRICS_PORT not set, metrics are not served")
+	} else {
+		metricsMux := http.NewServeMux()
+		metricsMux.Handle("GET /metrics", metrics.Handler())
+		metricsServer := &http.Server{
+			Addr:              ":" + cfg.MetricsPort,
+			Handler:           metricsMux,
+			ReadHeaderTimeout: 5 * time.Second,
+		}
+		go func() {
+			slog.Info("Metrics listener starting", slog.String("port", cfg.MetricsPort))
+			if err := metricsServer.ListenAndServe(); err != nil {
+				slog.Error("Metrics listener failed", slog.Any("error", err))
+			}
+		}()
 	}
 
-	// 2. Create HTTP mux (router)
+	// 3. Load the frontend into memory, with compressed variants and ETags:
+	// the SvelteKit build output from web/build and root level files such
+	// as /favicon.ico from web/static
+	buildFs, err := fs.Sub(buildFS, "web/build")
+	if err != nil {
+		return fmt.Errorf("failed to create sub filesystem for web/build: %w", err)
+	}
+	buildFiles, err := static.Load(buildFs)
+	if err != nil {
+		return fmt.Errorf("failed to load web/build: %w", err)
+	}
+	staticFs, err := fs.Sub(staticFS, "web/static")
+	if err != nil {
+		return fmt.Errorf("failed to create sub filesystem for web/static: %w", err)
+	}
+	staticFiles, err := static.Load(staticFs)
+	if err != nil {
+		return fmt.Errorf("failed to load web/static: %w", err)
+	}
+	mux := newMux(cfgProvider, apiServer, buildFiles, staticFiles)
+
+	// 4. Set up the middleware chain, outermost first. Metrics and tracing
+	// see every request; requests get an ID and a logger before being
+	// logged, every response gets security headers, and panics are
+	// recovered closest to the handlers.
+	handler := httpserver.Chain(metrics.Routes("", mux),
+		metrics.Middleware,
+		tracing.Middleware,
+		httpserver.RequestID,
+		httpserver.SecurityHeaders(cfgProvider),
+		httpserver.AccessLog,
+		httpserver.Recover,
+	)
+
+	// 4.a. Set up the server
+	server := &http.Server{
+		Addr:         ":" + cfg.Port,
+		Handler:      handler,
+		ReadTimeout:  5 * time.Second,
+		WriteTimeout: 10 * time.Second,
+		IdleTimeout:  120 * time.Second,
+	}
+
+	// 4.b. Set up TLS from certificate files or ACME, if configured
+	tlsSetup, err := httpserver.NewTLS(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to set up TLS: %w", err)
+	}
+	server.TLSConfig = tlsSetup.Config
+
+	// 4.c. Redirect plain HTTP to HTTPS. This listener also answers ACME HTTP-01 challenges.
+	if cfg.HTTPRedirectPort != "" {
+		redirectServer := tlsSetup.RedirectServer(":"+cfg.HTTPRedirectPort, cfg.Port)
+		go func() {
+			slog.Info("HTTP redirect listener starting", slog.String("port", cfg.HTTPRedirectPort))
+			if err := redirectServer.ListenAndServe(); err != nil {
+				slog.Error("HTTP redirect listener failed", slog.Any("error", err))
+			}
+		}()
+	}
+
+	slog.Info("Server starting", slog.String("port", cfg.Port), slog.Bool("tls", tlsSetup.Enabled()))
+
+	// 5. Start the server
+	serverErr := make(chan error, 1)
+	go func() {
+		if tlsSetup.Enabled() {
+			// Certificates come from TLSConfig, so no files are passed here
+			serverErr <- server.ListenAndServeTLS("", "")
+		} else {
+			serverErr <- server.ListenAndServe()
+		}
+	}()
+	select {
+	case err := <-serverErr:
+		return fmt.Errorf("server failed: %w", err)
+	case <-ctx.Done():
+	}
+
+	// 6. Finish the requests in flight, stop the background workers and
+	// send the notifications still queued
+	slog.Info("Shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		slog.Error("Failed to stop server", slog.Any("error", err))
+	}
+	apiServer.Shutdown(shutdownCtx)
+	slog.Info("Server stopped")
+	return nil
+}
+
+// newMux routes requests to the API, the frontend assets and, for page
+// navigations, the SPA
+func newMux(cfgProvider *config.Provider, apiServer *api.API, buildFiles, staticFiles *static.Files) *http.ServeMux {
+	// 1. Create HTTP mux (router)
 	// This will act as the main router for the web server
 	mux := http.NewServeMux()
 
-	// 2.a. Set up API routes
-	apiServer := api.New(cfg)
+	// 2. Set up API routes
 	apiMux := http.NewServeMux()
 	apiServer.Register(apiMux)
-	mux.Handle("/api/", http.StripPrefix("/api", apiMux))
+	// Browsers on other origins need CORS; cookie sessions need CSRF tokens.
+	// Unknown API paths get JSON errors, not the SPA.
+	apiHandler := metrics.Routes("/api", middleware.RequireCSRF(cfgProvider)(middleware.JSONNotFound(apiMux)))
+	mux.Handle("/api/", httpserver.CORS(cfgProvider)(http.StripPrefix("/api", apiHandler)))
 
-	// 3. Serve static files from the "web/build" directory (SvelteKit build output)
-	// 3.a. Create sub filesystem for build files
-	buildFs, err := fs.Sub(buildFS, "web/build")
-	if err != nil {
-		slog.Error("Failed to create sub filesystem for build files", slog.String("directory", "web/build"), slog.String("error", err.Error()))
-		return
-	}
-	// 3.b. Create file server handler for build assets
-	buildFileServer := http.FileServer(http.FS(buildFs))
-	mux.Handle("GET /static/", http.StripPrefix("/static/", buildFileServer))
+	// 3. Serve build assets
+	mux.Handle("GET /static/", http.StripPrefix("/static", buildFiles))
 
-	// 3.b.1. Serve SvelteKit's _app directory (contains JS, CSS, and other assets)
-	mux.Handle("GET /_app/", buildFileServer)
+	// 3.a. Serve SvelteKit's _app directory (contains JS, CSS, and other assets).
+	// Files under _app/immutable are cached for good.
+	mux.Handle("GET /_app/", buildFiles)
 
-	// 3.c. Serve specific files from web/static/ at root level
+	// 3.b. Serve specific files from web/static/ at root level
 	// These are served directly at root (e.g., /favicon.ico, /robots.txt)
-	mux.HandleFunc("GET /favicon.ico", func(w http.ResponseWriter, r *http.Request) {
-		data, err := staticFS.ReadFile("web/static/favicon.ico")
-		if err != nil {
-			http.NotFound(w, r)
-			return
-		}
-		w.Header().Set("Content-Type", "image/x-icon")
-		w.Write(data)
-	})
+	mux.Handle("GET /favicon.ico", staticFiles)
+	mux.Handle("GET /robots.txt", staticFiles)
 
-	mux.HandleFunc("GET /robots.txt", func(w http.ResponseWriter, r *http.Request) {
-		data, err := staticFS.ReadFile("web/static/robots.txt")
-		if err != nil {
+	// 4. Serve SPA (Single Page Application) - catch-all route for client-side routing
+	// This must be last so it doesn't override other routes
+	indexHTML, ok := buildFiles.Bytes("index.html")
+	if !ok {
+		slog.Error("Frontend not found, web/build/index.html is missing")
+	}
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		// Serve index.html for page navigations to client-side routes only.
+		// Anything else, such as a missing asset or a bot probing for
+		// /wp-login.php, gets a real 404.
+		if !isClientRoute(r.URL.Path) || !acceptsHTML(r) {
 			http.NotFound(w, r)
 			return
 		}
-		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
-		w.Write(data)
-	})
-
-	// 3.e. Serve SPA (Single Page Application) - catch-all route for client-side routing
-	// This must be last so it doesn't override other routes
-	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		// Serve index.html for all routes not matched above
-		// This enables client-side routing in SvelteKit
-		data, err := buildFS.ReadFile("web/build/index.html")
-		if err != nil {
+		if indexHTML == nil {
 			http.Error(w, "Frontend not found", http.StatusNotFound)
-			slog.Error("Failed to read index.html", slog.String("error", err.Error()))
 			return
 		}
+		// Let the inline bootstrap scripts run under the CSP. The nonce
+		// makes every response different, so there is no ETag to revalidate.
 		w.Header().Set("Content-Type", "text/html; charset=utf-8")
-		w.Write(data)
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Write(httpserver.InjectNonce(indexHTML, httpserver.CSPNonce(r.Context())))
 	})
 
-	// 4. Set up the server
-	server := &http.Server{
-		Addr:         ":" + cfg.Port,
-		Handler:      mux,
-		ReadTimeout:  5 * time.Second,
-		WriteTimeout: 10 * time.Second,
-		IdleTimeout:  120 * time.Second,
-	}
+	return mux
+}
 
-	slog.Info("Server starting", slog.String("port", cfg.Port))
+// shutdownTimeout bounds how long shutting down may take
+const shutdownTimeout = 30 * time.Second
 
-	// 5. Start the server
-	if err := server.ListenAndServe(); err != nil {
-		slog.Error("Server failed to start", slog.Any("error", err))
+// clientRoutes are the paths of the SvelteKit pages in web/src/routes, with
+// a trailing slash for those taking parameters
+var clientRoutes = []string{"/", "/login", "/call/"}
+
+// isClientRoute reports whether path belongs to a page of the frontend
+func isClientRoute(path string) bool {
+	for _, route := range clientRoutes {
+		if path == route || (strings.HasSuffix(route, "/") && route != "/" && strings.HasPrefix(path, route)) {
+			return true
+		}
 	}
+	return false
+}
+
+// acceptsHTML reports whether r is a browser navigation rather than a
+// script or asset request
+func acceptsHTML(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), "text/html")
 }
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/kaustavdm/awwdio/config"
+	"github.com/kaustavdm/awwdio/internal/api"
+	"github.com/kaustavdm/awwdio/internal/api/apierror"
+	httpserver "github.com/kaustavdm/awwdio/internal/server"
+	"github.com/kaustavdm/awwdio/internal/static"
+	"github.com/kaustavdm/awwdio/internal/store"
+)
+
+func TestIsClientRoute(t *testing.T) {
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"/", true},
+		{"/login", true},
+		{"/call/standup", true},
+		{"/call/", true},
+		{"/login/extra", false},
+		{"/call", false},
+		{"/calls/x", false},
+		{"/wp-login.php", false},
+		{"/_app/missing.js", false},
+	}
+	for _, tt := range tests {
+		if got := isClientRoute(tt.path); got != tt.want {
+			t.Errorf("isClientRoute(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestRoutes(t *testing.T) {
+	st, err := store.Open("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.Static(&config.Config{JWTSecret: "0123456789abcdef0123456789abcdef"})
+	ctx, cancel := context.WithCancel(context.Background())
+	apiServer, err := api.New(ctx, cfg, st)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		cancel()
+		apiServer.Shutdown(context.Background())
+	})
+
+	load := func(fsys fstest.MapFS) *static.Files {
+		files, err := static.Load(fsys)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return files
+	}
+	buildFiles := load(fstest.MapFS{
+		"index.html":              {Data: []byte("<!doctype html><script>start()</script>")},
+		"_app/immutable/entry.js": {Data: []byte("start()")},
+	})
+	staticFiles := load(fstest.MapFS{"robots.txt": {Data: []byte("User-agent: *")}})
+	handler := httpserver.SecurityHeaders(cfg)(newMux(cfg, apiServer, buildFiles, staticFiles))
+
+	const (
+		html     = "text/html,application/xhtml+xml,*/*;q=0.8"
+		anything = "*/*"
+	)
+	tests := []struct {
+		name, method, path, accept string
+
+		wantStatus int
+		wantType   string
+		wantBody   string
+	}{
+		{"unknown API path", "GET", "/api/typo", html, http.StatusNotFound, "application/json", ""},
+		{"unknown API method", "DELETE", "/api/auth/logout", anything, http.StatusMethodNotAllowed, "application/json", ""},
+		{"asset", "GET", "/_app/immutable/entry.js", anything, http.StatusOK, "text/javascript; charset=utf-8", "start()"},
+		{"missing asset", "GET", "/_app/missing.js", html, http.StatusNotFound, "text/plain; charset=utf-8", "404 page not found\n"},
+		{"root file", "GET", "/robots.txt", anything, http.StatusOK, "text/plain; charset=utf-8", "User-agent: *"},
+		{"probe", "GET", "/wp-login.php", html, http.StatusNotFound, "text/plain; charset=utf-8", "404 page not found\n"},
+		{"page", "GET", "/call/x", html, http.StatusOK, "text/html; charset=utf-8", `<!doctype html><script nonce="NONCE">start()</script>`},
+		{"home page", "GET", "/", html, http.StatusOK, "text/html; charset=utf-8", `<!doctype html><script nonce="NONCE">start()</script>`},
+		{"page fetched by a script", "GET", "/call/x", anything, http.StatusNotFound, "text/plain; charset=utf-8", "404 page not found\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			req.Header.Set("Accept", tt.accept)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if got := rec.Header().Get("Content-Type"); got != tt.wantType {
+				t.Errorf("Content-Type = %q, want %q", got, tt.wantType)
+			}
+			if tt.wantType == "application/json" {
+				var body apierror.Error
+				if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || body.Code == "" {
+					t.Errorf("body = %s, want a JSON error", rec.Body)
+				}
+				return
+			}
+			// Pages carry the nonce of their CSP
+			nonce := regexp.MustCompile(`'nonce-([^']+)'`).FindStringSubmatch(rec.Header().Get("Content-Security-Policy"))
+			if want := strings.ReplaceAll(tt.wantBody, "NONCE", nonce[1]); rec.Body.String() != want {
+				t.Errorf("body = %q, want %q", rec.Body, want)
+			}
+			if strings.HasPrefix(tt.wantType, "text/html") && rec.Header().Get("Cache-Control") != "no-cache" {
+				t.Errorf("Cache-Control = %q, want no-cache", rec.Header().Get("Cache-Control"))
+			}
+		})
+	}
+}
+
+func TestRunServeFailures(t *testing.T) {
+	logger := slog.Default()
+	t.Cleanup(func() { slog.SetDefault(logger) })
+
+	// Take a port so that the server cannot listen on it
+	ln, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+
+	dir := t.TempDir()
+	valid := filepath.Join(dir, "awwdio.yaml")
+	err = os.WriteFile(valid, []byte(`twilio_account_sid: AC1
+twilio_api_key: SK1
+twilio_api_secret: secret
+twilio_verify_service_sid: VA1
+jwt_secret: 0123456789abcdef0123456789abcdef
+port: "`+port+`"
+`), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	missing := filepath.Join(dir, "missing.yaml")
+
+	tests := []struct {
+		name string
+		args []string
+		want int
+	}{
+		{"missing config file", []string{"-config", missing}, 1},
+		{"serve with missing config file", []string{"serve", "-config", missing}, 1},
+		{"port in use", []string{"-config", valid}, 1},
+		{"check of missing config file", []string{"-check-config", "-config", missing}, 1},
+		{"check of valid config file", []string{"-check-config", "-config", valid}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := run(tt.args); got != tt.want {
+				t.Errorf("run(%q) = %d, want %d", tt.args, got, tt.want)
+			}
+		})
+	}
+}
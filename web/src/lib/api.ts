@@ -1,6 +1,7 @@
 import { browser } from '$app/environment';
 import { goto } from '$app/navigation';
 import { authStore } from './stores/auth';
+import { csrfHeaders } from './csrf';
 
 export interface ApiError {
 	error: string;
@@ -14,7 +15,8 @@ export interface ApiResponse<T> {
 
 /**
  * Authenticated fetch wrapper that:
- * - Adds Authorization: Bearer token header
+ * - Adds Authorization: Bearer token header (bearer mode)
+ * - Sends the session cookie and CSRF header (cookie mode)
  * - Handles 401 responses by redirecting to login
  * - Returns typed response data
  */
@@ -24,8 +26,10 @@ export async function apiFetch<T>(
 ): Promise<ApiResponse<T>> {
 	const token = authStore.getToken();
 
+	const method = (options.method || 'GET').toUpperCase();
 	const headers: HeadersInit = {
 		'Content-Type': 'application/json',
+		...(method !== 'GET' && method !== 'HEAD' ? csrfHeaders() : {}),
 		...(options.headers || {})
 	};
 
@@ -36,7 +40,8 @@ export async function apiFetch<T>(
 	try {
 		const response = await fetch(url, {
 			...options,
-			headers
+			headers,
+			credentials: 'same-origin'
 		});
 
 		// Handle 401 - redirect to login
@@ -0,0 +1,14 @@
+import { browser } from '$app/environment';
+
+/**
+ * Headers for state-changing requests. When the server uses cookie
+ * sessions it sets a readable `awwdio_csrf` cookie that must be echoed in
+ * `X-CSRF-Token`; in bearer mode there is no such cookie and nothing is added.
+ */
+export function csrfHeaders(): Record<string, string> {
+	if (!browser) {
+		return {};
+	}
+	const match = document.cookie.match(/(?:^|;\s*)awwdio_csrf=([^;]+)/);
+	return match ? { 'X-CSRF-Token': decodeURIComponent(match[1]) } : {};
+}
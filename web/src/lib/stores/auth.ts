@@ -1,11 +1,12 @@
 import { writable } from 'svelte/store';
 import { browser } from '$app/environment';
+import { csrfHeaders } from '../csrf';
 
 export interface User {
 	channel: 'email' | 'sms';
 	contact: string; // Email address or phone number
 	displayName?: string;
-	token?: string;
+	token?: string; // Absent when the server keeps the session in a cookie
 }
 
 function createAuthStore() {
@@ -40,6 +41,8 @@ function createAuthStore() {
 			if (browser) {
 				localStorage.removeItem('user');
 				localStorage.removeItem('token');
+				// Clears the session cookie, if the server uses one
+				fetch('/api/auth/logout', { method: 'POST', headers: csrfHeaders() }).catch(() => {});
 			}
 		},
 		updateDisplayName: (displayName: string) => {
@@ -14,7 +14,7 @@ const config = {
 			pages: resolve(__dirname, 'build'),
 			assets: resolve(__dirname, 'build'),
 			fallback: 'index.html',
-			precompress: false,
+			precompress: true,
 			strict: true
 		})
 	}